@@ -0,0 +1,59 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// AccessLogger logs the HTTP requests served by a service as consistently keyed log entries.
+type AccessLogger struct {
+	logger log.Logger
+}
+
+// AccessLog returns an access logger logging the HTTP requests to the specified logger.
+func AccessLog(logger log.Logger) *AccessLogger {
+	return &AccessLogger{logger: logger}
+}
+
+// Log logs a request of the specified method and path that was served with the specified status
+// in the specified duration writing the specified number of bytes, using the 'method', 'path',
+// 'status', 'duration' and 'bytes' keys followed by the specified extra keyvals.
+// The requests are logged with the error level if the status is 5xx, so they're written to stderr,
+// with the warn level if it's 4xx, and with the info level otherwise.
+// It calls the logger directly, so the caller is resolved just like using the level loggers.
+func (a *AccessLogger) Log(method, path string, status int, dur time.Duration, bytes int, extra ...interface{}) error {
+	var lvl level.Value
+
+	switch {
+	case status >= 500:
+		lvl = level.ErrorValue()
+	case status >= 400:
+		lvl = level.WarnValue()
+	default:
+		lvl = level.InfoValue()
+	}
+
+	keyvals := make([]interface{}, 0, 12+len(extra))
+	keyvals = append(keyvals, level.Key(), lvl, "method", method, "path", path, "status", status,
+		"duration", dur, "bytes", bytes)
+
+	return a.logger.Log(append(keyvals, extra...)...)
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccessLog(t *testing.T) {
+	logger, logs := CaptureLogger(WithConfig(&Config{Level: "info"}))
+
+	access := AccessLog(logger)
+
+	access.Log("GET", "/users", 200, 1500*time.Millisecond, 512, "request_id", "abc")
+	access.Log("POST", "/users", 404, time.Millisecond, 0)
+	access.Log("DELETE", "/users", 500, 2*time.Second, 64)
+	expectedCaller := callerLine(-1)
+
+	out, errs := logs.OutputLines(), logs.ErrorLines()
+
+	if len(out) != 2 || len(errs) != 1 {
+		t.Fatalf("expected 2 stdout entries and 1 stderr entry, but found %v and %v", out, errs)
+	}
+
+	for _, c := range []struct {
+		line     map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{out[0], map[string]interface{}{"level": "info", "method": "GET", "path": "/users", "status": float64(200),
+			"duration": "1.5s", "bytes": float64(512), "request_id": "abc"}},
+		{out[1], map[string]interface{}{"level": "warn", "method": "POST", "status": float64(404), "bytes": float64(0)}},
+		{errs[0], map[string]interface{}{"level": "error", "method": "DELETE", "status": float64(500), "duration": "2s",
+			"caller": expectedCaller}},
+	} {
+		for k, v := range c.expected {
+			if c.line[k] != v {
+				t.Errorf("expected key '%v' to be %v, but found %v", k, v, c.line)
+			}
+		}
+	}
+}
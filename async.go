@@ -0,0 +1,194 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// DefaultBufferSize is the default number of log entries an async logger can hold before
+// they're written.
+const DefaultBufferSize = 1024
+
+var (
+	// ErrBufferFull is returned when writing to an async logger configured to drop
+	// log entries when its buffer is full.
+	ErrBufferFull = errors.New("logging buffer is full, log entry dropped")
+	// ErrClosed is returned when writing to a closed logger.
+	ErrClosed = errors.New("logging writer is closed")
+)
+
+// this is implemented by writers that hold log entries before they're written.
+type flusher interface {
+	Flush() error
+}
+
+// this is either a log entry to be written or, if flushed is set,
+// a request to be notified once all the previous entries are written.
+type asyncEntry struct {
+	data    []byte
+	label   string
+	flushed chan struct{}
+}
+
+// this is a writer that queues the written log entries in a buffered
+// channel and writes them in order to the underlying writer on a
+// background goroutine, so writing never waits for the underlying writer
+// unless the buffer is full and it's not configured to drop entries.
+type asyncWriter struct {
+	w            io.Writer
+	entries      chan asyncEntry
+	dropOnFull   bool
+	onError      func(error)
+	dropCounters map[string]metrics.Counter
+	done         chan struct{}
+	mtx          sync.RWMutex
+	closed       bool
+}
+
+// returns a new async writer for the specified writer, it starts the background
+// goroutine which keeps running until the returned writer is closed.
+// The errors of writing to the underlying writer are passed to onError if not nil, and the
+// entries dropped by timing out are counted by the drop counters of their level labels.
+func newAsyncWriter(w io.Writer, bufferSize int, dropOnFull bool, onError func(error),
+	dropCounters map[string]metrics.Counter) *asyncWriter {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	a := &asyncWriter{
+		w:            w,
+		entries:      make(chan asyncEntry, bufferSize),
+		dropOnFull:   dropOnFull,
+		onError:      onError,
+		dropCounters: dropCounters,
+		done:         make(chan struct{}),
+	}
+
+	go a.run()
+
+	return a
+}
+
+// writes the queued entries until the entries channel is closed.
+func (a *asyncWriter) run() {
+	defer close(a.done)
+
+	for e := range a.entries {
+		if e.flushed != nil {
+			close(e.flushed)
+			continue
+		}
+
+		// the caller is long gone at this point, so the error handler is all we've got.
+		_, err := a.w.Write(e.data)
+
+		if errors.Is(err, ErrWriteTimeout) {
+			if c := a.dropCounters[e.label]; c != nil {
+				c.Add(1)
+			}
+		}
+
+		if err != nil && a.onError != nil {
+			a.onError(err)
+		}
+	}
+}
+
+// queues the specified entry, if it can be dropped and the buffer is full it's dropped.
+func (a *asyncWriter) enqueue(e asyncEntry, canDrop bool) error {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+
+	if a.closed {
+		return ErrClosed
+	}
+
+	if canDrop {
+		select {
+		case a.entries <- e:
+			return nil
+		default:
+			return ErrBufferFull
+		}
+	}
+
+	a.entries <- e
+	return nil
+}
+
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	return a.write(p, noLevelLabel)
+}
+
+// queues a copy of the specified entry labeled by the specified level label.
+func (a *asyncWriter) write(p []byte, label string) (int, error) {
+	// the caller may reuse the slice once we return, so we keep a copy.
+	data := make([]byte, len(p))
+	copy(data, p)
+
+	if err := a.enqueue(asyncEntry{data: data, label: label}, a.dropOnFull); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// this is a writer that queues the written log entries to an async writer labeled
+// by the level label of the appender it's used by, so their drops are counted by it.
+type asyncLabelWriter struct {
+	a     *asyncWriter
+	label string
+}
+
+func (w asyncLabelWriter) Write(p []byte) (int, error) {
+	return w.a.write(p, w.label)
+}
+
+// Flush blocks until all the log entries written so far are written to the underlying writer.
+func (a *asyncWriter) Flush() error {
+	flushed := make(chan struct{})
+
+	if err := a.enqueue(asyncEntry{flushed: flushed}, false); err != nil {
+		return err
+	}
+
+	<-flushed
+	return nil
+}
+
+// Close writes all the pending log entries and stops the background goroutine,
+// it doesn't close the underlying writer.
+func (a *asyncWriter) Close() error {
+	a.mtx.Lock()
+
+	if a.closed {
+		a.mtx.Unlock()
+		return nil
+	}
+
+	a.closed = true
+	close(a.entries)
+	a.mtx.Unlock()
+
+	<-a.done
+	return nil
+}
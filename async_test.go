@@ -0,0 +1,131 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// this is a writer that blocks on each write until it's released,
+// it signals the started channel on the first write.
+type blockingWriter struct {
+	bytes.Buffer
+	started  chan struct{}
+	release  chan struct{}
+	doneOnce sync.Once
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.doneOnce.Do(func() { close(w.started) })
+	<-w.release
+	return w.Buffer.Write(p)
+}
+
+func TestAsyncFlush(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := CreateSyncLogger(loggerName, nil, &Config{Level: "debug", Format: "json", Async: true, BufferSize: 16},
+		&bufOut, &bufErr)
+
+	var expectedOut, expectedErr [][]int
+
+	for i := 0; i < 1000; i++ {
+		if i%10 == 0 {
+			level.Error(logger).Log(fmt.Sprintf("key_%v%v", i, 1), fmt.Sprintf("val_%v%v", i, 1))
+			expectedErr = append(expectedErr, []int{i, 1})
+		} else {
+			level.Info(logger).Log(fmt.Sprintf("key_%v%v", i, 0), fmt.Sprintf("val_%v%v", i, 0))
+			expectedOut = append(expectedOut, []int{i, 0})
+		}
+	}
+
+	if err := logger.Flush(); err != nil {
+		t.Fatalf("failed to flush logger, %v", err.Error())
+	}
+
+	if err := validateLogs(bufOut.String(), expectedOut); err != nil {
+		t.Errorf("failed to validate out writer, %v", err.Error())
+	}
+
+	if err := validateLogs(bufErr.String(), expectedErr); err != nil {
+		t.Errorf("failed to validate err writer, %v", err.Error())
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Errorf("failed to close logger, %v", err.Error())
+	}
+
+	if err := level.Info(logger).Log("key", "val"); err != ErrClosed {
+		t.Errorf("expected error '%v' after closing, but found '%v'", ErrClosed, err)
+	}
+}
+
+func TestAsyncDropOnFull(t *testing.T) {
+	for _, dropOnFull := range []bool{true, false} {
+		var bufErr bytes.Buffer
+
+		out := newBlockingWriter()
+		logger := CreateSyncLogger(loggerName, nil, &Config{Level: "debug", Format: "json", Async: true, BufferSize: 1,
+			DropOnFull: dropOnFull}, out, &bufErr)
+
+		// the first entry is taken by the background writer and blocks it, the second one fills the buffer.
+		level.Info(logger).Log("key_00", "val_00")
+		<-out.started
+		level.Info(logger).Log("key_01", "val_01")
+
+		done := make(chan error)
+
+		go func() {
+			done <- level.Info(logger).Log("key_02", "val_02")
+		}()
+
+		expected := [][]int{{0, 0}, {0, 1}, {0, 2}}
+
+		if dropOnFull {
+			if err := <-done; err != ErrBufferFull {
+				t.Errorf("expected error '%v' on a full buffer, but found '%v'", ErrBufferFull, err)
+			}
+
+			expected = expected[:2]
+		}
+
+		close(out.release)
+
+		if !dropOnFull {
+			if err := <-done; err != nil {
+				t.Errorf("expected no error on a full buffer, but found '%v'", err)
+			}
+		}
+
+		if err := logger.Close(); err != nil {
+			t.Errorf("failed to close logger, %v", err.Error())
+		}
+
+		if err := validateLogs(out.String(), expected); err != nil {
+			t.Errorf("failed to validate out writer with drop on full '%v', %v", dropOnFull, err.Error())
+		}
+	}
+}
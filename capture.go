@@ -0,0 +1,136 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// this is a single log entry written to a capture logger.
+type capturedEntry struct {
+	data   []byte
+	stderr bool
+}
+
+// CapturedLogs holds the log entries written by a capture logger in memory,
+// it's meant to be used by tests asserting on the emitted log entries.
+type CapturedLogs struct {
+	mtx     sync.Mutex
+	entries []capturedEntry
+}
+
+// this is a writer that appends each write to the captured logs as a log entry.
+type captureWriter struct {
+	logs   *CapturedLogs
+	stderr bool
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.logs.mtx.Lock()
+	defer w.logs.mtx.Unlock()
+
+	// loggers may reuse their buffers so keep a copy.
+	w.logs.entries = append(w.logs.entries, capturedEntry{data: append([]byte(nil), p...), stderr: w.stderr})
+	return len(p), nil
+}
+
+// returns the parsed log entries that match the specified filter in the order they were written.
+func (c *CapturedLogs) lines(match func(capturedEntry) bool) []map[string]interface{} {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	lines := make([]map[string]interface{}, 0, len(c.entries))
+
+	for _, e := range c.entries {
+		if !match(e) {
+			continue
+		}
+
+		for _, data := range bytes.Split(e.data, []byte("\n")) {
+			if len(bytes.TrimSpace(data)) == 0 {
+				continue
+			}
+
+			line := make(map[string]interface{})
+
+			// capture loggers always write JSON, so this should never fail.
+			if err := json.Unmarshal(data, &line); err == nil {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	return lines
+}
+
+// Lines returns all the captured log entries parsed as key-value maps in the order they were written.
+func (c *CapturedLogs) Lines() []map[string]interface{} {
+	return c.lines(func(capturedEntry) bool { return true })
+}
+
+// OutputLines returns the captured log entries that would have been written to stdout.
+func (c *CapturedLogs) OutputLines() []map[string]interface{} {
+	return c.lines(func(e capturedEntry) bool { return !e.stderr })
+}
+
+// ErrorLines returns the captured log entries that would have been written to stderr.
+func (c *CapturedLogs) ErrorLines() []map[string]interface{} {
+	return c.lines(func(e capturedEntry) bool { return e.stderr })
+}
+
+// Contains checks if any of the captured log entries has the specified key
+// with a value that is formatted as the specified value.
+func (c *CapturedLogs) Contains(key, value string) bool {
+	for _, line := range c.Lines() {
+		if v, ok := line[key]; ok && fmt.Sprint(v) == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reset discards all the captured log entries.
+func (c *CapturedLogs) Reset() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.entries = nil
+}
+
+// CaptureLogger returns an instrumented logger that writes its log entries in memory
+// instead of stdout and stderr, along with the captured logs to assert on.
+// The logger is configured by the specified options just like NewLogger except
+// that the output format is always single-line JSON and the writers can't be changed.
+func CaptureLogger(opts ...Option) (Logger, *CapturedLogs) {
+	logs := &CapturedLogs{}
+
+	opts = append(opts, func(o *options) {
+		// copy the configuration so the caller's one is left intact.
+		config := withJSONFormats(o.config)
+		// the entries are parsed line by line, so they can't be indented.
+		config.Pretty = false
+		o.config = config
+		o.out = &captureWriter{logs: logs}
+		o.err = &captureWriter{logs: logs, stderr: true}
+	})
+
+	return NewLogger(opts...), logs
+}
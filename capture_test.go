@@ -0,0 +1,89 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestCaptureLogger(t *testing.T) {
+	config := &Config{Level: "debug", Format: FormatLogfmt}
+
+	logger, logs := CaptureLogger(WithName(loggerName), WithConfig(config))
+
+	level.Debug(logger).Log("msg", "debug message")
+	level.Info(logger).Log("msg", "info message", "count", 3)
+	level.Warn(logger).Log("msg", "warn message")
+	level.Error(logger).Log("msg", "error message")
+
+	if config.Format != FormatLogfmt {
+		t.Errorf("expected the configuration format to be left intact, but found '%v'", config.Format)
+	}
+
+	for _, c := range []struct {
+		lines    []map[string]interface{}
+		messages []string
+	}{
+		{logs.Lines(), []string{"debug message", "info message", "warn message", "error message"}},
+		{logs.OutputLines(), []string{"debug message", "info message", "warn message"}},
+		{logs.ErrorLines(), []string{"error message"}},
+	} {
+		if len(c.lines) != len(c.messages) {
+			t.Errorf("expected %v captured lines, but found %v", len(c.messages), c.lines)
+			continue
+		}
+
+		for i, line := range c.lines {
+			if line["msg"] != c.messages[i] || line["logger"] != loggerName {
+				t.Errorf("expected line with message '%v', but found %v", c.messages[i], line)
+			}
+		}
+	}
+
+	if errorLines := logs.ErrorLines(); len(errorLines) > 0 && errorLines[0]["caller"] == nil {
+		t.Errorf("expected error lines to have a caller, but found %v", errorLines[0])
+	}
+
+	for _, c := range []struct {
+		key, value string
+		expected   bool
+	}{
+		{"msg", "warn message", true},
+		{"count", "3", true},
+		{"level", "error", true},
+		{"msg", "trace message", false},
+		{"missing", "", false},
+	} {
+		if found := logs.Contains(c.key, c.value); found != c.expected {
+			t.Errorf("expected contains (%v, %v) to be %v, but found %v", c.key, c.value, c.expected, found)
+		}
+	}
+
+	logs.Reset()
+
+	if lines := logs.Lines(); len(lines) != 0 {
+		t.Errorf("expected no captured lines after reset, but found %v", lines)
+	}
+
+	level.Info(logger).Log("msg", "after reset")
+
+	if !logs.Contains("msg", "after reset") {
+		t.Errorf("expected the logger to keep capturing after reset, but found %v", logs.Lines())
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultCompressionFlushInterval is the default interval the compressed log entries are flushed at.
+const DefaultCompressionFlushInterval = time.Second
+
+// this is the gzip compression of the file and network sinks.
+type compression struct {
+	level         int
+	flushInterval time.Duration
+}
+
+// this is a writer that compresses the log entries using gzip, and flushes them periodically
+// so they're available promptly without waiting for the compressor to fill its buffer.
+// Closing it finalizes the gzip stream without closing the underlying writer.
+type gzipWriter struct {
+	mtx   sync.Mutex
+	gz    *gzip.Writer
+	dirty bool
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// returns a new gzip writer of the specified compression writing to the specified writer, it returns
+// an error if the compression level isn't valid. If the flush interval is positive, it starts the
+// background goroutine flushing the written entries which keeps running until the writer is closed.
+func newGzipWriter(w io.Writer, c *compression) (*gzipWriter, error) {
+	gz, err := gzip.NewWriterLevel(w, c.level)
+
+	if err != nil {
+		return nil, err
+	}
+
+	g := &gzipWriter{gz: gz, done: make(chan struct{})}
+
+	if c.flushInterval > 0 {
+		g.wg.Add(1)
+		go g.run(c.flushInterval)
+	}
+
+	return g, nil
+}
+
+// flushes the written entries at the specified interval until the writer is closed.
+func (g *gzipWriter) run(interval time.Duration) {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// the errors are sticky, so they're returned by the next write.
+			g.Flush()
+		case <-g.done:
+			return
+		}
+	}
+}
+
+func (g *gzipWriter) Write(p []byte) (int, error) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	g.dirty = true
+	return g.gz.Write(p)
+}
+
+// Flush writes the compressed entries to the underlying writer if any was written since the last flush.
+func (g *gzipWriter) Flush() error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	if !g.dirty {
+		return nil
+	}
+
+	g.dirty = false
+	return g.gz.Flush()
+}
+
+// Close stops flushing periodically and finalizes the gzip stream.
+func (g *gzipWriter) Close() error {
+	close(g.done)
+	g.wg.Wait()
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	return g.gz.Close()
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvFormat is the name suffix of the environment variable carrying the logging output format.
+	EnvFormat = "LOG_FORMAT"
+	// EnvLevel is the name suffix of the environment variable carrying the logging severity level.
+	EnvLevel = "LOG_LEVEL"
+)
+
+// ErrInvalidConfig is the error wrapped by all the errors returned by Config.Validate.
+var ErrInvalidConfig = errors.New("invalid logging configuration")
+
+// Validate checks that the configuration is well-formed, it returns an error
+// wrapping ErrInvalidConfig and naming the offending field and value if not.
+// Empty values are valid since they're replaced by the defaults.
+func (c *Config) Validate() error {
+	if f := strings.TrimSpace(c.Format); f != "" && !isValidFormat(f) {
+		return fmt.Errorf("%w, unsupported Format '%v'", ErrInvalidConfig, c.Format)
+	}
+
+	if f := strings.TrimSpace(c.OutputFormat); f != "" && !isValidFormat(f) {
+		return fmt.Errorf("%w, unsupported OutputFormat '%v'", ErrInvalidConfig, c.OutputFormat)
+	}
+
+	if f := strings.TrimSpace(c.ErrorFormat); f != "" && !isValidFormat(f) {
+		return fmt.Errorf("%w, unsupported ErrorFormat '%v'", ErrInvalidConfig, c.ErrorFormat)
+	}
+
+	if l := strings.TrimSpace(c.Level); l != "" {
+		if _, ok := lookupLevel(l); !ok {
+			return fmt.Errorf("%w, unsupported Level '%v'", ErrInvalidConfig, c.Level)
+		}
+	}
+
+	if l := strings.TrimSpace(c.ErrorLevel); l != "" {
+		if _, ok := lookupLevel(l); !ok {
+			return fmt.Errorf("%w, unsupported ErrorLevel '%v'", ErrInvalidConfig, c.ErrorLevel)
+		}
+	}
+
+	if f := strings.TrimSpace(c.TimestampFormat); f != "" && !isValidTimestampFormat(f) {
+		return fmt.Errorf("%w, unsupported TimestampFormat '%v'", ErrInvalidConfig, c.TimestampFormat)
+	}
+
+	for _, l := range c.StderrLevels {
+		if r, ok := lookupLevel(l); !ok || r == rankNone {
+			return fmt.Errorf("%w, unsupported StderrLevels value '%v'", ErrInvalidConfig, l)
+		}
+	}
+
+	return nil
+}
+
+// ConfigFromEnv returns a new configuration loaded from the environment variables
+// <PREFIX>_LOG_FORMAT and <PREFIX>_LOG_LEVEL, the prefix is upper-cased and if it's empty
+// the variables are LOG_FORMAT and LOG_LEVEL. Unset or empty variables fall back to the defaults.
+func ConfigFromEnv(prefix string) *Config {
+	c := Configuration()
+
+	if v := getEnv(prefix, EnvFormat); v != "" {
+		c.Format = v
+	}
+
+	if v := getEnv(prefix, EnvLevel); v != "" {
+		c.Level = v
+	}
+
+	return c
+}
+
+// these are the environment variables detected by AutoConfig, each set by one of the platforms.
+const (
+	envLambdaFunction   = "AWS_LAMBDA_FUNCTION_NAME"
+	envLambdaLevel      = "AWS_LAMBDA_LOG_LEVEL"
+	envCloudRunService  = "K_SERVICE"
+	envCloudFunction    = "FUNCTION_TARGET"
+	envAppEngineService = "GAE_SERVICE"
+	envKubernetesHost   = "KUBERNETES_SERVICE_HOST"
+)
+
+// AutoConfig returns a new configuration tuned for the platform detected from the environment
+// variables, falling back to Configuration() if it's none of the known ones:
+//   - AWS Lambda: single-line JSON with the 'timestamp' key the Lambda JSON logs use, at the
+//     level set by AWS_LAMBDA_LOG_LEVEL if any.
+//   - Google Cloud Run, Cloud Functions and App Engine: the 'gcp' format.
+//   - Kubernetes: JSON, which is what the cluster log collectors parse.
+//
+// The LOG_FORMAT and LOG_LEVEL environment variables override the detected defaults
+// just like they do for ConfigFromEnv with an empty prefix.
+func AutoConfig() *Config {
+	c := Configuration()
+
+	switch {
+	case os.Getenv(envLambdaFunction) != "":
+		c.Format, c.TimestampKey = FormatJSON, "timestamp"
+
+		if v := getEnv("", envLambdaLevel); v != "" {
+			c.Level = v
+		}
+	case os.Getenv(envCloudRunService) != "", os.Getenv(envCloudFunction) != "", os.Getenv(envAppEngineService) != "":
+		c.Format = FormatGCP
+	case os.Getenv(envKubernetesHost) != "":
+		c.Format = FormatJSON
+	}
+
+	if v := getEnv("", EnvFormat); v != "" {
+		c.Format = v
+	}
+
+	if v := getEnv("", EnvLevel); v != "" {
+		c.Level = v
+	}
+
+	return c
+}
+
+// LoadConfig returns a new configuration loaded from the specified JSON or YAML file, the
+// file is parsed based on its extension which can be '.json', '.yaml' or '.yml'.
+// The missing or empty fields fall back to the defaults and the loaded configuration
+// is validated, so an error is returned if it's invalid or the file can't be parsed.
+func LoadConfig(path string) (*Config, error) {
+	var unmarshal func([]byte, interface{}) error
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		unmarshal = json.Unmarshal
+	case ".yaml", ".yml":
+		unmarshal = yaml.Unmarshal
+	default:
+		return nil, fmt.Errorf("%w, unsupported file extension '%v'", ErrInvalidConfig, ext)
+	}
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+
+	if err := unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%w, failed to parse '%v', %v", ErrInvalidConfig, path, err.Error())
+	}
+
+	setDefaults(c)
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// sets the empty fields of the specified configuration to their default values.
+func setDefaults(c *Config) {
+	d := Configuration()
+
+	for _, f := range []struct {
+		value    *string
+		fallback string
+	}{
+		{&c.Format, d.Format},
+		{&c.Level, d.Level},
+		{&c.TimestampKey, d.TimestampKey},
+		{&c.TimestampFormat, d.TimestampFormat},
+		{&c.NameKey, d.NameKey},
+	} {
+		if strings.TrimSpace(*f.value) == "" {
+			*f.value = f.fallback
+		}
+	}
+
+	if c.CallerDepth <= 0 {
+		c.CallerDepth = d.CallerDepth
+	}
+}
+
+// returns the trimmed & lower-cased value of the environment variable
+// with the specified name prefixed by the specified prefix.
+func getEnv(prefix, name string) string {
+	if prefix = strings.ToUpper(strings.TrimSpace(prefix)); prefix != "" {
+		name = prefix + "_" + name
+	}
+
+	return strings.ToLower(strings.TrimSpace(os.Getenv(name)))
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, c := range []*Config{
+		Configuration(),
+		{},
+		{Format: " ", Level: " "},
+		{Format: "json", Level: "none"},
+		{Format: "logfmt", Level: "error"},
+		{Format: "JSON", Level: " Warn "},
+		{Format: "LogFmt", Level: "DEBUG"},
+		{Format: "json", Level: "trace"},
+		{Format: "ECS", Level: "info"},
+		{Format: "gcp", Level: "info"},
+		{Format: "emf", Level: "info"},
+		{Format: "proto", Level: "info"},
+		{Format: "msgpack", Level: "info"},
+		{Format: "console", Level: "info"},
+		{OutputFormat: "json", ErrorFormat: "Logfmt"},
+		{Level: "info", ErrorLevel: " None "},
+	} {
+		if err := c.Validate(); err != nil {
+			t.Errorf("expected config (%v, %v) to be valid, but found %v", c.Format, c.Level, err.Error())
+		}
+	}
+
+	for _, c := range []struct {
+		config *Config
+		field  string
+	}{
+		{&Config{Format: "jsn", Level: "info"}, "Format"},
+		{&Config{Format: "json", Level: "infi"}, "Level"},
+		{&Config{Level: "all"}, "Level"},
+		{&Config{OutputFormat: "text"}, "OutputFormat"},
+		{&Config{ErrorFormat: "text"}, "ErrorFormat"},
+		{&Config{ErrorLevel: "fatl"}, "ErrorLevel"},
+	} {
+		err := c.config.Validate()
+
+		if err == nil {
+			t.Errorf("expected config (%v, %v) to be invalid, but found no error", c.config.Format, c.config.Level)
+			continue
+		}
+
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected error to wrap ErrInvalidConfig, but found %v", err.Error())
+		}
+
+		if !strings.Contains(err.Error(), c.field) {
+			t.Errorf("expected error to name the field '%v', but found %v", c.field, err.Error())
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("MYAPP_LOG_FORMAT", " LogFmt ")
+	t.Setenv("MYAPP_LOG_LEVEL", "DEBUG")
+	t.Setenv("OTHER_LOG_FORMAT", "")
+	t.Setenv("LOG_LEVEL", "error")
+
+	for _, c := range []struct {
+		prefix        string
+		format, level string
+	}{
+		{"MYAPP", FormatLogfmt, "debug"},
+		{"myapp", FormatLogfmt, "debug"},
+		{"OTHER", DefaultFormat, DefaultLevel},
+		{"MISSING", DefaultFormat, DefaultLevel},
+		{"", DefaultFormat, "error"},
+	} {
+		config := ConfigFromEnv(c.prefix)
+
+		if config.Format != c.format || config.Level != c.level {
+			t.Errorf("expected configuration ('%v', '%v') for prefix '%v', but found ('%v', '%v')",
+				c.format, c.level, c.prefix, config.Format, config.Level)
+		}
+	}
+}
+
+func TestAutoConfig(t *testing.T) {
+	for _, c := range []struct {
+		platform      string
+		env           map[string]string
+		format, level string
+		timestampKey  string
+	}{
+		{"none", nil, FormatJSON, DefaultLevel, DefaultTimestampKey},
+		{"lambda", map[string]string{"AWS_LAMBDA_FUNCTION_NAME": "handler"}, FormatJSON, DefaultLevel, "timestamp"},
+		{"lambda", map[string]string{"AWS_LAMBDA_FUNCTION_NAME": "handler", "AWS_LAMBDA_LOG_LEVEL": "DEBUG"},
+			FormatJSON, "debug", "timestamp"},
+		{"cloud run", map[string]string{"K_SERVICE": "api"}, FormatGCP, DefaultLevel, DefaultTimestampKey},
+		{"cloud functions", map[string]string{"FUNCTION_TARGET": "Handle"}, FormatGCP, DefaultLevel, DefaultTimestampKey},
+		{"app engine", map[string]string{"GAE_SERVICE": "default"}, FormatGCP, DefaultLevel, DefaultTimestampKey},
+		{"kubernetes", map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1"}, FormatJSON, DefaultLevel, DefaultTimestampKey},
+		{"kubernetes", map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1", "LOG_FORMAT": "Logfmt", "LOG_LEVEL": "warn"},
+			FormatLogfmt, "warn", DefaultTimestampKey},
+	} {
+		// the variables of the other platforms might be set by the environment running the tests.
+		for _, name := range []string{"AWS_LAMBDA_FUNCTION_NAME", "AWS_LAMBDA_LOG_LEVEL", "K_SERVICE", "FUNCTION_TARGET",
+			"GAE_SERVICE", "KUBERNETES_SERVICE_HOST", "LOG_FORMAT", "LOG_LEVEL"} {
+			t.Setenv(name, c.env[name])
+		}
+
+		config := AutoConfig()
+
+		if config.Format != c.format || config.Level != c.level || config.TimestampKey != c.timestampKey {
+			t.Errorf("expected configuration ('%v', '%v', '%v') on %v with %v, but found ('%v', '%v', '%v')",
+				c.format, c.level, c.timestampKey, c.platform, c.env, config.Format, config.Level, config.TimestampKey)
+		}
+
+		if err := config.Validate(); err != nil {
+			t.Errorf("expected configuration on %v to be valid, but found %v", c.platform, err.Error())
+		}
+	}
+}
+
+func TestValidateStderrLevels(t *testing.T) {
+	for _, levels := range [][]string{nil, {}, {"error"}, {" Warn ", "ERROR"}} {
+		if err := (&Config{StderrLevels: levels}).Validate(); err != nil {
+			t.Errorf("expected stderr levels %v to be valid, but found %v", levels, err.Error())
+		}
+	}
+
+	for _, levels := range [][]string{{"none"}, {"error", "eror"}} {
+		if err := (&Config{StderrLevels: levels}).Validate(); err == nil || !strings.Contains(err.Error(), "StderrLevels") {
+			t.Errorf("expected stderr levels %v to be invalid, but found %v", levels, err)
+		}
+	}
+}
+
+func TestValidateTimestampFormat(t *testing.T) {
+	for _, f := range []string{"", "rfc3339", "RFC3339Nano", " unixmilli ", "unixnano"} {
+		if err := (&Config{TimestampFormat: f}).Validate(); err != nil {
+			t.Errorf("expected timestamp format '%v' to be valid, but found %v", f, err.Error())
+		}
+	}
+
+	err := (&Config{TimestampFormat: "unix"}).Validate()
+
+	if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), "TimestampFormat") {
+		t.Errorf("expected timestamp format 'unix' to be invalid, but found %v", err)
+	}
+}
+
+// writes the specified content to a temporary file of the specified name and returns its path.
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file, %v", err.Error())
+	}
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	include := false
+
+	for _, c := range []struct {
+		name, content string
+		expected      *Config
+	}{
+		{"logging.yaml", "format: logfmt\nlevel: debug\ncaller_depth: 6\nstderr_levels: [warn, error]\ninclude_caller: false\n",
+			&Config{Format: "logfmt", Level: "debug", CallerDepth: 6, StderrLevels: []string{"warn", "error"},
+				TimestampKey: "ts", TimestampFormat: "rfc3339nano", NameKey: "logger", IncludeCaller: &include}},
+		{"logging.json", `{"format": "ecs", "level": "warn", "timestamp_key": "time", "async": true}`,
+			&Config{Format: "ecs", Level: "warn", CallerDepth: DefaultCallerDepth, Async: true,
+				TimestampKey: "time", TimestampFormat: "rfc3339nano", NameKey: "logger"}},
+		{"logging.yml", "level: error\n",
+			&Config{Format: "json", Level: "error", CallerDepth: DefaultCallerDepth,
+				TimestampKey: "ts", TimestampFormat: "rfc3339nano", NameKey: "logger"}},
+	} {
+		config, err := LoadConfig(writeConfigFile(t, c.name, c.content))
+
+		if err != nil {
+			t.Errorf("failed to load config file '%v', %v", c.name, err.Error())
+			continue
+		}
+
+		if !reflect.DeepEqual(config, c.expected) {
+			t.Errorf("expected config %+v from file '%v', but found %+v", c.expected, c.name, config)
+		}
+	}
+
+	for _, c := range []struct {
+		name, content string
+	}{
+		{"logging.yaml", "format: [json\n"},
+		{"logging.json", `{"format": "json",}`},
+		{"logging.yaml", "format: text\n"},
+		{"logging.toml", "format = 'json'\n"},
+	} {
+		if _, err := LoadConfig(writeConfigFile(t, c.name, c.content)); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected config file '%v' with content '%v' to be invalid, but found %v", c.name, c.content, err)
+		}
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("expected an error loading a missing config file, but found none")
+	}
+}
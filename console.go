@@ -0,0 +1,168 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// these are the ANSI escape codes used to colorize the 'console' format.
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiDim     = "\x1b[2m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiBlue    = "\x1b[34m"
+	ansiMagenta = "\x1b[35m"
+)
+
+// these are the colors of each severity level.
+var levelColors = map[string]string{
+	levelNames[rankError]: ansiRed,
+	levelNames[rankWarn]:  ansiYellow,
+	levelNames[rankInfo]:  ansiGreen,
+	levelNames[rankDebug]: ansiBlue,
+	levelNames[rankTrace]: ansiMagenta,
+}
+
+// checks if the specified writer is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// checks if the entries written to the specified writer should be colorized,
+// they're never colorized if the NO_COLOR environment variable is set.
+func isColorEnabled(config *Config, w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	return config.ForceColor || isTerminal(w)
+}
+
+// this is a logger that writes each log entry as a human-friendly line starting with
+// the timestamp, the level and the message followed by the rest of the key-value pairs.
+type consoleLogger struct {
+	w            io.Writer
+	timestampKey string
+	color        bool
+}
+
+// returns a factory that creates console loggers that resolve the timestamp using the specified key.
+func createConsoleLoggerFactory(timestampKey string, color bool) func(io.Writer) log.Logger {
+	return func(w io.Writer) log.Logger {
+		return &consoleLogger{w: w, timestampKey: timestampKey, color: color}
+	}
+}
+
+func (l *consoleLogger) Log(keyvals ...interface{}) error {
+	var ts, lvl, msg interface{}
+	var rest bytes.Buffer
+
+	for i := 0; i < len(keyvals)-1; i += 2 {
+		k, v := keyvals[i], keyvals[i+1]
+
+		switch {
+		case k == l.timestampKey && ts == nil:
+			ts = v
+		case k == level.Key() && lvl == nil:
+			lvl = v
+		case (k == "msg" || k == "message") && msg == nil:
+			msg = v
+		default:
+			rest.WriteByte(' ')
+			rest.WriteString(l.paint(ansiDim, formatConsoleValue(k)+"="))
+			rest.WriteString(formatConsoleValue(v))
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if ts != nil {
+		buf.WriteString(l.paint(ansiDim, fmt.Sprint(ts)))
+		buf.WriteByte(' ')
+	}
+
+	if lvl != nil {
+		name := fmt.Sprint(lvl)
+		buf.WriteString(l.paint(levelColors[name], fmt.Sprintf("%-5v", strings.ToUpper(name))))
+		buf.WriteByte(' ')
+	}
+
+	// the rest of the pairs are separated by a leading space which isn't needed without a message.
+	if msg != nil {
+		buf.WriteString(l.paint(ansiBold, fmt.Sprint(msg)))
+		buf.Write(rest.Bytes())
+	} else {
+		buf.Write(bytes.TrimLeft(rest.Bytes(), " "))
+	}
+
+	buf.WriteByte('\n')
+
+	_, err := l.w.Write(buf.Bytes())
+	return err
+}
+
+// wraps the specified string with the specified color if colors are enabled.
+func (l *consoleLogger) paint(color, s string) string {
+	if !l.color || color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// returns the specified value formatted, quoted if it has spaces or special characters.
+func formatConsoleValue(v interface{}) string {
+	var s string
+
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case error:
+		// the nil pointer errors are written as null just like a nil value.
+		if isNilPointer(x) {
+			return "null"
+		}
+		s = x.Error()
+	default:
+		s = fmt.Sprint(x)
+	}
+
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// this matches a console line of an info entry.
+var consoleLinePattern = regexp.MustCompile(`^\S+ INFO  connected logger=fake host=db port=5432 note="two words"\n$`)
+
+func TestConsoleFormat(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+
+	var bufOut, bufErr bytes.Buffer
+
+	logger := CreateSyncLogger(loggerName, nil, &Config{Format: FormatConsole}, &bufOut, &bufErr)
+
+	level.Info(logger).Log("msg", "connected", "host", "db", "port", 5432, "note", "two words")
+	level.Error(logger).Log("msg", "failed", "err", errors.New("refused"))
+
+	if strings.Contains(bufOut.String()+bufErr.String(), "\x1b[") {
+		t.Errorf("expected no ANSI codes when not writing to a terminal, but found '%q'", bufOut.String()+bufErr.String())
+	}
+
+	if !consoleLinePattern.MatchString(bufOut.String()) {
+		t.Errorf("expected a console info line, but found '%v'", bufOut.String())
+	}
+
+	if errLine := bufErr.String(); !strings.Contains(errLine, " ERROR failed ") || !strings.Contains(errLine, "err=refused") ||
+		!strings.Contains(errLine, "caller=console_test.go:") {
+		t.Errorf("expected a console error line, but found '%v'", errLine)
+	}
+
+	bufErr.Reset()
+	level.Error(logger).Log("msg", "failed", "err", (*stackError)(nil))
+
+	if errLine := bufErr.String(); !strings.Contains(errLine, "err=null") {
+		t.Errorf("expected a nil pointer error to be written as null, but found '%v'", errLine)
+	}
+}
+
+func TestConsoleColor(t *testing.T) {
+	for _, c := range []struct {
+		noColor  string
+		expected bool
+	}{
+		{"", true},
+		{"1", false},
+	} {
+		t.Setenv("NO_COLOR", c.noColor)
+
+		var bufOut, bufErr bytes.Buffer
+
+		logger := CreateSyncLogger(loggerName, nil, &Config{Format: FormatConsole, ForceColor: true}, &bufOut, &bufErr)
+
+		level.Info(logger).Log("msg", "connected")
+		level.Error(logger).Log("msg", "failed")
+
+		for name, logs := range map[string]string{"out": bufOut.String(), "err": bufErr.String()} {
+			if colored := strings.Contains(logs, "\x1b["); colored != c.expected {
+				t.Errorf("expected %v entry colored to be %v with NO_COLOR '%v', but found '%q'", name, c.expected, c.noColor, logs)
+			}
+		}
+
+		if c.expected {
+			if !strings.Contains(bufOut.String(), ansiGreen+"INFO "+ansiReset) || !strings.Contains(bufOut.String(), ansiBold+"connected"+ansiReset) {
+				t.Errorf("expected a green info level and a bold message, but found '%q'", bufOut.String())
+			}
+
+			if !strings.Contains(bufErr.String(), ansiRed+"ERROR"+ansiReset) {
+				t.Errorf("expected a red error level, but found '%q'", bufErr.String())
+			}
+		}
+	}
+}
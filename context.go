@@ -0,0 +1,66 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+)
+
+// ContextExtractor returns the log fields carried by a context as key-value pairs.
+type ContextExtractor func(context.Context) []interface{}
+
+var (
+	// these are the registered context extractors.
+	contextExtractors []ContextExtractor
+	// and this guards them.
+	contextExtractorsMtx sync.RWMutex
+)
+
+// RegisterContextExtractor registers the specified extractor to be used by WithContext,
+// this is usually done once during initialization, e.g. to extract request IDs.
+func RegisterContextExtractor(extractor ContextExtractor) {
+	if extractor == nil {
+		return
+	}
+
+	contextExtractorsMtx.Lock()
+	defer contextExtractorsMtx.Unlock()
+
+	contextExtractors = append(contextExtractors, extractor)
+}
+
+// WithContext returns a logger that adds the fields extracted from the specified
+// context by all the registered extractors to every log entry.
+func WithContext(ctx context.Context, logger log.Logger) log.Logger {
+	if ctx == nil {
+		return logger
+	}
+
+	contextExtractorsMtx.RLock()
+	defer contextExtractorsMtx.RUnlock()
+
+	var keyvals []interface{}
+
+	for _, extract := range contextExtractors {
+		keyvals = append(keyvals, extract(ctx)...)
+	}
+
+	return log.With(logger, keyvals...)
+}
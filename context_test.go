@@ -0,0 +1,64 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+type requestIDKey struct{}
+
+func init() {
+	RegisterContextExtractor(func(ctx context.Context) []interface{} {
+		if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+			return []interface{}{"request_id", id}
+		}
+		return nil
+	})
+}
+
+func TestWithContext(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := CreateSyncLogger(loggerName, nil, &Config{Level: "debug", Format: "json"}, &bufOut, &bufErr)
+
+	level.Info(WithContext(context.WithValue(context.Background(), requestIDKey{}, "abc"), logger)).Log("key", "val")
+	level.Info(WithContext(context.Background(), logger)).Log("key", "val")
+
+	dec := json.NewDecoder(&bufOut)
+
+	for _, expected := range []interface{}{"abc", nil} {
+		record := make(map[string]interface{})
+
+		if err := dec.Decode(&record); err != nil {
+			t.Fatalf("failed to parse log entry, %v", err.Error())
+		}
+
+		if id := record["request_id"]; id != expected {
+			t.Errorf("expected request id '%v', but found '%v'", expected, id)
+		}
+
+		if v := record["key"]; v != "val" {
+			t.Errorf("expected key-value (key, val), but found (key, %v)", v)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// RepeatedKey is the key of the number of times a deduplicated log entry was repeated.
+const RepeatedKey = "repeated"
+
+// this is a log entry held by a deduplicator until it's no longer repeated.
+type dedupEntry struct {
+	next    log.Logger
+	source  *struct{}
+	keyvals []interface{}
+	count   int
+	timer   *time.Timer
+}
+
+// this collapses identical consecutive log entries written to any of its loggers
+// within a time window into a single entry with the number of repetitions,
+// the entry is written once the window closes or a different entry arrives.
+type deduplicator struct {
+	window       time.Duration
+	timestampKey string
+	onError      func(error)
+	mtx          sync.Mutex
+	pending      *dedupEntry
+}
+
+// returns a new deduplicator collapsing the entries within the specified window ignoring
+// their timestamps, the errors of writing the entries after the window closes are
+// passed to onError if not nil.
+func newDeduplicator(window time.Duration, timestampKey string, onError func(error)) *deduplicator {
+	return &deduplicator{window: window, timestampKey: timestampKey, onError: onError}
+}
+
+// returns a logger deduplicating the entries written by the specified logger, it's used
+// below the appenders contexts so the entries are compared with their values resolved.
+// The loggers aren't always comparable, e.g. tee loggers, so each wrapped logger is
+// identified by its own token instead.
+func (d *deduplicator) wrap(next log.Logger) log.Logger {
+	source := new(struct{})
+
+	return log.LoggerFunc(func(keyvals ...interface{}) error {
+		return d.log(next, source, keyvals)
+	})
+}
+
+func (d *deduplicator) log(next log.Logger, source *struct{}, keyvals []interface{}) error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if p := d.pending; p != nil && p.source == source && d.equal(p.keyvals, keyvals) {
+		p.count++
+		return nil
+	}
+
+	// a different entry arrived, so the pending one isn't repeated anymore.
+	err := d.emit()
+
+	e := &dedupEntry{next: next, source: source, keyvals: append([]interface{}(nil), keyvals...), count: 1}
+	e.timer = time.AfterFunc(d.window, func() {
+		d.mtx.Lock()
+		defer d.mtx.Unlock()
+
+		// the entry may have been written already by the time the window closes.
+		if d.pending == e {
+			if err := d.emit(); err != nil && d.onError != nil {
+				d.onError(err)
+			}
+		}
+	})
+
+	d.pending = e
+
+	return err
+}
+
+// writes the pending entry if any adding the number of repetitions if it was repeated,
+// it must be called while holding the lock.
+func (d *deduplicator) emit() error {
+	p := d.pending
+
+	if p == nil {
+		return nil
+	}
+
+	d.pending = nil
+	p.timer.Stop()
+
+	if p.count > 1 {
+		return p.next.Log(append(p.keyvals, RepeatedKey, p.count)...)
+	}
+
+	return p.next.Log(p.keyvals...)
+}
+
+// checks if the specified log entries are identical except for their timestamps.
+func (d *deduplicator) equal(a, b []interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := 0; i < len(a)-1; i += 2 {
+		// the keys aren't always comparable, e.g. slices, so they're compared as they're written.
+		k := keyString(a[i])
+
+		if k != keyString(b[i]) {
+			return false
+		}
+
+		if k == d.timestampKey {
+			continue
+		}
+
+		if !reflect.DeepEqual(a[i+1], b[i+1]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Flush writes the pending entry right away.
+func (d *deduplicator) Flush() error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	return d.emit()
+}
+
+// Close writes the pending entry right away.
+func (d *deduplicator) Close() error {
+	return d.Flush()
+}
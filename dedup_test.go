@@ -0,0 +1,128 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestDedup(t *testing.T) {
+	logger, logs := CaptureLogger(WithDedup(time.Hour))
+
+	for i := 0; i < 5; i++ {
+		level.Error(logger).Log("msg", "connection refused")
+	}
+
+	if lines := logs.Lines(); len(lines) != 0 {
+		t.Errorf("expected repeated entries to be held, but found %v", lines)
+	}
+
+	// a different entry writes the repeated one.
+	level.Info(logger).Log("msg", "connected")
+
+	lines := logs.Lines()
+
+	if len(lines) != 1 || lines[0]["msg"] != "connection refused" || lines[0][RepeatedKey] != 5.0 {
+		t.Errorf("expected a single entry repeated 5 times, but found %v", lines)
+	}
+
+	if err := logger.Flush(); err != nil {
+		t.Errorf("failed to flush logger, %v", err.Error())
+	}
+
+	lines = logs.Lines()
+
+	if len(lines) != 2 || lines[1]["msg"] != "connected" || lines[1][RepeatedKey] != nil {
+		t.Errorf("expected the single entry to be written on flush as is, but found %v", lines)
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	logger, logs := CaptureLogger(WithDedup(20 * time.Millisecond))
+
+	for i := 0; i < 5; i++ {
+		level.Info(logger).Log("msg", "repeated")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for len(logs.Lines()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	lines := logs.Lines()
+
+	if len(lines) != 1 || lines[0][RepeatedKey] != 5.0 {
+		t.Errorf("expected a single entry repeated 5 times once the window closes, but found %v", lines)
+	}
+
+	// entries with different values or from different levels aren't collapsed.
+	level.Info(logger).Log("msg", "first")
+	level.Info(logger).Log("msg", "second")
+	level.Warn(logger).Log("msg", "second")
+
+	if err := logger.Close(); err != nil {
+		t.Errorf("failed to close logger, %v", err.Error())
+	}
+
+	if lines := logs.Lines(); len(lines) != 4 {
+		t.Errorf("expected different entries to be written as they are, but found %v", lines)
+	}
+}
+
+func TestDedupErrorsToStdoutToo(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Level: "debug", Format: "json", ErrorsToStdoutToo: true}),
+		WithOutputWriter(&bufOut), WithErrorWriter(&bufErr), WithDedup(time.Hour))
+
+	// the error entries are written by an uncomparable tee logger.
+	for i := 0; i < 3; i++ {
+		logger.Error("msg", "connection refused")
+	}
+
+	if err := logger.Flush(); err != nil {
+		t.Fatalf("failed to flush logger, %v", err.Error())
+	}
+
+	for name, buf := range map[string]*bytes.Buffer{"out": &bufOut, "err": &bufErr} {
+		if s := buf.String(); strings.Count(s, "\n") != 1 || !strings.Contains(s, `"repeated":3`) {
+			t.Errorf("expected a single entry repeated 3 times written to %v, but found '%v'", name, s)
+		}
+	}
+}
+
+func TestDedupUncomparableKeys(t *testing.T) {
+	logger, logs := CaptureLogger(WithDedup(time.Hour))
+
+	for i := 0; i < 3; i++ {
+		level.Info(logger).Log([]string{"a", "b"}, "val", map[string]int{"c": 1}, "val")
+	}
+
+	if err := logger.Flush(); err != nil {
+		t.Fatalf("failed to flush logger, %v", err.Error())
+	}
+
+	if lines := logs.Lines(); len(lines) != 1 || lines[0][RepeatedKey] != 3.0 {
+		t.Errorf("expected a single entry repeated 3 times, but found %v", lines)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+var (
+	// this is the logger used by the package-level log functions,
+	// it discards everything until it's set.
+	defaultLogger = log.NewNopLogger()
+	// and this guards it.
+	defaultLoggerMtx sync.RWMutex
+)
+
+// SetDefault sets the logger used by the package-level log functions, if nil
+// the log entries are discarded which is also the case until it's first set.
+// Since the package-level log functions add a stack frame, the caller depth of
+// the loggers of this package should be increased by one to resolve the right caller.
+func SetDefault(logger log.Logger) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+
+	defaultLoggerMtx.Lock()
+	defer defaultLoggerMtx.Unlock()
+
+	defaultLogger = logger
+}
+
+// Default returns the logger used by the package-level log functions.
+func Default() log.Logger {
+	defaultLoggerMtx.RLock()
+	defer defaultLoggerMtx.RUnlock()
+
+	return defaultLogger
+}
+
+// TraceLog logs the specified keyvals with the trace severity level using the default logger,
+// it isn't named Trace since Trace returns a trace logger of any logger like go-kit's level.Debug does.
+func TraceLog(keyvals ...interface{}) error {
+	return Trace(Default()).Log(keyvals...)
+}
+
+// Debug logs the specified keyvals with the debug severity level using the default logger.
+func Debug(keyvals ...interface{}) error {
+	return level.Debug(Default()).Log(keyvals...)
+}
+
+// Info logs the specified keyvals with the info severity level using the default logger.
+func Info(keyvals ...interface{}) error {
+	return level.Info(Default()).Log(keyvals...)
+}
+
+// Warn logs the specified keyvals with the warn severity level using the default logger.
+func Warn(keyvals ...interface{}) error {
+	return level.Warn(Default()).Log(keyvals...)
+}
+
+// Error logs the specified keyvals with the error severity level using the default logger.
+func Error(keyvals ...interface{}) error {
+	return level.Error(Default()).Log(keyvals...)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDefaultLogger(t *testing.T) {
+	defer SetDefault(nil)
+
+	// the default logger discards everything until it's set.
+	if err := Error("msg", "discarded"); err != nil {
+		t.Errorf("failed to log to the nop default logger, %v", err.Error())
+	}
+
+	logger, logs := CaptureLogger(WithName(loggerName), WithConfig(&Config{Level: "trace", CallerDepth: DefaultCallerDepth + 1}))
+
+	SetDefault(logger)
+
+	if Default() != logger {
+		t.Errorf("expected the default logger to be the one set, but found %v", Default())
+	}
+
+	TraceLog("msg", "trace")
+	Debug("msg", "debug")
+	Info("msg", "info")
+	Warn("msg", "warn")
+	Error("msg", "error")
+
+	for _, c := range []struct {
+		lines  []map[string]interface{}
+		levels []string
+	}{
+		{logs.OutputLines(), []string{"trace", "debug", "info", "warn"}},
+		{logs.ErrorLines(), []string{"error"}},
+	} {
+		if len(c.lines) != len(c.levels) {
+			t.Errorf("expected %v entries, but found %v", len(c.levels), c.lines)
+			continue
+		}
+
+		for i, line := range c.lines {
+			if line["level"] != c.levels[i] || line["msg"] != c.levels[i] {
+				t.Errorf("expected %v entry, but found %v", c.levels[i], line)
+			}
+		}
+	}
+
+	if lines := logs.ErrorLines(); len(lines) == 1 {
+		if caller, _ := lines[0]["caller"].(string); !strings.HasPrefix(caller, "default_test.go:") {
+			t.Errorf("expected the caller to be this file, but found '%v'", caller)
+		}
+	}
+
+	SetDefault(nil)
+	Info("msg", "discarded")
+
+	if logs.Contains("msg", "discarded") {
+		t.Errorf("expected entries to be discarded after resetting the default logger, but found %v", logs.Lines())
+	}
+}
+
+func TestDefaultLoggerConcurrency(t *testing.T) {
+	defer SetDefault(nil)
+
+	logger, logs := CaptureLogger()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			SetDefault(logger)
+		}()
+
+		go func() {
+			defer wg.Done()
+			Info("msg", "concurrent")
+		}()
+	}
+
+	wg.Wait()
+
+	for _, line := range logs.Lines() {
+		if line["msg"] != "concurrent" {
+			t.Errorf("expected concurrent entries only, but found %v", line)
+		}
+	}
+}
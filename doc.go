@@ -19,7 +19,7 @@ Package logging provides custom gokit logger implementation(s).
 
 Brief
 
-This library provides custom gokit logger implementation(s), currently it provides JSON formatted stdout & stderr sync. implementation.
+This library provides custom gokit logger implementation(s), currently it provides JSON or logfmt formatted stdout & stderr sync. implementation.
 
 Usage
 
@@ -33,8 +33,17 @@ Then, import the package:
 
 Example
 
-  logger := logging.CreateStdSyncLogger("mylogger", logging.DefaultLoggingConfiguration())
-  logger.Log("key", "value")
+  logger := logging.CreateStdSyncLogger("mylogger", nil, logging.Configuration())
+  level.Info(logger).Log("key", "value")
+
+Or, using options:
+
+  logger := logging.NewLogger(
+    logging.WithName("mylogger"),
+    logging.WithConfig(logging.Configuration()),
+    logging.WithOutputWriter(os.Stdout),
+    logging.WithErrorWriter(os.Stderr),
+  )
 
 */
 package logging
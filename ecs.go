@@ -0,0 +1,114 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// ECSVersion is the version of the Elastic Common Schema the 'ecs' format conforms to.
+const ECSVersion = "1.6.0"
+
+// this is a logger that rewrites the log entries keys to match
+// the Elastic Common Schema before passing them to a JSON logger.
+type ecsLogger struct {
+	next         log.Logger
+	timestampKey string
+	nameKey      string
+}
+
+// returns a factory that creates ECS loggers that resolve
+// the timestamp and logger name using the specified keys.
+func createECSLoggerFactory(timestampKey, nameKey string) func(io.Writer) log.Logger {
+	return func(w io.Writer) log.Logger {
+		return &ecsLogger{next: log.NewJSONLogger(w), timestampKey: timestampKey, nameKey: nameKey}
+	}
+}
+
+func (l *ecsLogger) Log(keyvals ...interface{}) error {
+	entry := make([]interface{}, 0, len(keyvals)+4)
+	entry = append(entry, "ecs.version", ECSVersion)
+
+	// these are the nested 'log' fields, they're only added if there are any.
+	logFields := make(map[string]interface{})
+
+	for i := 0; i < len(keyvals)-1; i += 2 {
+		k, v := keyvals[i], keyvals[i+1]
+
+		switch k {
+		case l.timestampKey:
+			entry = append(entry, "@timestamp", v)
+		case level.Key():
+			entry = append(entry, "log.level", v)
+		case "msg", "message":
+			entry = append(entry, "message", v)
+		case l.nameKey:
+			logFields["logger"] = v
+		case CallerKey:
+			logFields["origin"] = getECSOrigin(fmt.Sprint(v))
+		case "err", "error":
+			entry = append(entry, "error", map[string]interface{}{"message": getECSString(v)})
+		default:
+			entry = append(entry, k, v)
+		}
+	}
+
+	if len(logFields) > 0 {
+		entry = append(entry, "log", logFields)
+	}
+
+	return l.next.Log(entry...)
+}
+
+// returns the ECS 'log.origin' fields of the specified 'file:line' caller.
+func getECSOrigin(caller string) map[string]interface{} {
+	file := map[string]interface{}{"name": caller}
+
+	if i := strings.LastIndex(caller, ":"); i >= 0 {
+		if line, err := strconv.Atoi(caller[i+1:]); err == nil {
+			file["name"], file["line"] = caller[:i], line
+		}
+	}
+
+	return map[string]interface{}{"file": file}
+}
+
+// returns the specified value as a string if it's an error or a fmt.Stringer,
+// since nested values are marshaled as they are, the nil pointer errors are
+// returned as nil and the stringers as "NULL" like go-kit does.
+func getECSString(v interface{}) interface{} {
+	switch x := v.(type) {
+	case error:
+		if isNilPointer(x) {
+			return nil
+		}
+		return x.Error()
+	case fmt.Stringer:
+		if isNilPointer(x) {
+			return "NULL"
+		}
+		return x.String()
+	default:
+		return v
+	}
+}
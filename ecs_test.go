@@ -0,0 +1,132 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestECSFormat(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Format: FormatECS}),
+		WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))
+
+	level.Error(logger).Log("msg", "failed to connect", "err", errors.New("connection refused"), "attempt", 3)
+
+	if bufOut.Len() > 0 {
+		t.Errorf("expected error entries to be written to err, but found '%v' in out", bufOut.String())
+	}
+
+	var entry struct {
+		Timestamp  string `json:"@timestamp"`
+		LogLevel   string `json:"log.level"`
+		Message    string `json:"message"`
+		ECSVersion string `json:"ecs.version"`
+		Attempt    int    `json:"attempt"`
+		Log        struct {
+			Logger string `json:"logger"`
+			Origin struct {
+				File struct {
+					Name string `json:"name"`
+					Line int    `json:"line"`
+				} `json:"file"`
+			} `json:"origin"`
+		} `json:"log"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(bufErr.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to parse ECS entry '%v', %v", bufErr.String(), err.Error())
+	}
+
+	if _, err := time.Parse(time.RFC3339Nano, entry.Timestamp); err != nil {
+		t.Errorf("failed to parse ECS timestamp '%v', %v", entry.Timestamp, err.Error())
+	}
+
+	if entry.LogLevel != "error" || entry.Message != "failed to connect" || entry.ECSVersion != ECSVersion ||
+		entry.Attempt != 3 || entry.Log.Logger != loggerName || entry.Error.Message != "connection refused" {
+		t.Errorf("expected entry to have the ECS fields, but found %v", bufErr.String())
+	}
+
+	if entry.Log.Origin.File.Name != "ecs_test.go" || entry.Log.Origin.File.Line <= 0 {
+		t.Errorf("expected entry origin to be this file, but found %v:%v", entry.Log.Origin.File.Name, entry.Log.Origin.File.Line)
+	}
+
+	record := make(map[string]interface{})
+	json.Unmarshal(bufErr.Bytes(), &record)
+
+	for _, k := range []string{"ts", "level", "msg", "err", "caller", "logger"} {
+		if _, ok := record[k]; ok {
+			t.Errorf("expected key '%v' to be rewritten, but found %v", k, bufErr.String())
+		}
+	}
+}
+
+func TestECSNilError(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Format: FormatECS}),
+		WithOutputWriter(&buf), WithErrorWriter(&buf))
+
+	level.Error(logger).Log("msg", "failed", "err", (*stackError)(nil))
+	level.Error(logger).Log("msg", "failed", "error", (*url.URL)(nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 ECS entries, but found '%v'", buf.String())
+	}
+
+	for i, expected := range []interface{}{nil, "NULL"} {
+		var entry struct {
+			Error map[string]interface{} `json:"error"`
+		}
+
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatalf("failed to parse ECS entry '%v', %v", lines[i], err.Error())
+		}
+
+		if v, ok := entry.Error["message"]; !ok || v != expected {
+			t.Errorf("expected the error message to be %v, but found %v", expected, lines[i])
+		}
+	}
+}
+
+func TestECSOrigin(t *testing.T) {
+	for caller, expected := range map[string]string{
+		"main.go:12":   `{"file":{"line":12,"name":"main.go"}}`,
+		"main.go":      `{"file":{"name":"main.go"}}`,
+		"main.go:line": `{"file":{"name":"main.go:line"}}`,
+	} {
+		origin, _ := json.Marshal(getECSOrigin(caller))
+
+		if strings.TrimSpace(string(origin)) != expected {
+			t.Errorf("expected origin of '%v' to be %v, but found %v", caller, expected, string(origin))
+		}
+	}
+}
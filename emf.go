@@ -0,0 +1,154 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// EMFDefaultNamespace is the CloudWatch metrics namespace of the metrics logged
+// in the 'emf' format by loggers having no name.
+const EMFDefaultNamespace = "aws-embedded-metrics"
+
+// MetricValue is a log entry value that is extracted as a CloudWatch metric by the
+// 'emf' format, other formats log only its value.
+type MetricValue struct {
+	Name  string
+	Value float64
+	Unit  string
+}
+
+// Metric returns a log entry value that is extracted by the 'emf' format as a CloudWatch
+// metric of the specified name, value and unit, e.g. "Milliseconds" or "Count", it's
+// logged under its name instead of the key it's logged with, so they'd better match.
+// If the unit is empty, "None" is used.
+func Metric(name string, value float64, unit string) MetricValue {
+	if unit == "" {
+		unit = "None"
+	}
+
+	return MetricValue{Name: name, Value: value, Unit: unit}
+}
+
+func (m MetricValue) String() string {
+	return strconv.FormatFloat(m.Value, 'g', -1, 64)
+}
+
+// MarshalJSON marshals only the metric value.
+func (m MetricValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Value)
+}
+
+// these are the CloudWatch embedded metric format metadata of a log entry.
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+type emfDirective struct {
+	Namespace  string              `json:"Namespace"`
+	Dimensions [][]string          `json:"Dimensions"`
+	Metrics    []emfMetricMetadata `json:"Metrics"`
+}
+
+type emfMetricMetadata struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+// this is a logger that adds the CloudWatch embedded metric format metadata to the log
+// entries having metric values before passing them to a JSON logger, so CloudWatch
+// extracts the metrics, the entries having no metric values are passed as they are.
+type emfLogger struct {
+	next         log.Logger
+	timestampKey string
+	nameKey      string
+}
+
+// returns a factory that creates EMF loggers that resolve
+// the timestamp and logger name using the specified keys.
+func createEMFLoggerFactory(timestampKey, nameKey string) func(io.Writer) log.Logger {
+	return func(w io.Writer) log.Logger {
+		return &emfLogger{next: log.NewJSONLogger(w), timestampKey: timestampKey, nameKey: nameKey}
+	}
+}
+
+func (l *emfLogger) Log(keyvals ...interface{}) error {
+	entry := make([]interface{}, 0, len(keyvals)+2)
+
+	var metrics []emfMetricMetadata
+	namespace, timestamp := EMFDefaultNamespace, time.Now()
+
+	for i := 0; i < len(keyvals)-1; i += 2 {
+		k, v := keyvals[i], keyvals[i+1]
+
+		switch k {
+		case l.timestampKey:
+			timestamp = getEMFTimestamp(v, timestamp)
+		case l.nameKey:
+			if s, ok := v.(string); ok && s != "" {
+				namespace = s
+			}
+		}
+
+		if m, ok := v.(MetricValue); ok {
+			metrics = append(metrics, emfMetricMetadata{Name: m.Name, Unit: m.Unit})
+			entry = append(entry, m.Name, m.Value)
+			continue
+		}
+
+		entry = append(entry, k, v)
+	}
+
+	if len(metrics) > 0 {
+		entry = append(entry, "_aws", &emfMetadata{
+			Timestamp: timestamp.UnixNano() / int64(time.Millisecond),
+			CloudWatchMetrics: []emfDirective{
+				{Namespace: namespace, Dimensions: [][]string{{}}, Metrics: metrics},
+			},
+		})
+	}
+
+	return l.next.Log(entry...)
+}
+
+// returns the time of the specified timestamp value of any of the timestamp
+// formats, or the specified fallback if it's not a timestamp.
+func getEMFTimestamp(v interface{}, fallback time.Time) time.Time {
+	switch x := v.(type) {
+	case int64:
+		// the unix timestamps in milliseconds are far less than the ones
+		// in nanoseconds, e.g. 1e15 milliseconds is in year 33658.
+		if x < 1e15 {
+			return time.Unix(0, x*int64(time.Millisecond))
+		}
+		return time.Unix(0, x)
+	default:
+		// the RFC3339 timestamps are go-kit formatted time values, not strings.
+		if t, err := time.Parse(time.RFC3339Nano, fmt.Sprint(x)); err == nil {
+			return t
+		}
+	}
+
+	return fallback
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestEMFFormat(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	now := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Format: FormatEMF}), WithTimestampFunc(func() time.Time { return now }),
+		WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))
+
+	level.Info(logger).Log("msg", "request served", "latency", Metric("latency", 12.5, "Milliseconds"),
+		"size", Metric("size", 512, ""), "path", "/")
+
+	var entry struct {
+		Message string  `json:"msg"`
+		Path    string  `json:"path"`
+		Latency float64 `json:"latency"`
+		Size    float64 `json:"size"`
+		AWS     struct {
+			Timestamp         int64 `json:"Timestamp"`
+			CloudWatchMetrics []struct {
+				Namespace  string
+				Dimensions [][]string
+				Metrics    []struct {
+					Name string
+					Unit string
+				}
+			}
+		} `json:"_aws"`
+	}
+
+	if err := json.Unmarshal(bufOut.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to parse EMF entry '%v', %v", bufOut.String(), err.Error())
+	}
+
+	if entry.Message != "request served" || entry.Path != "/" || entry.Latency != 12.5 || entry.Size != 512 {
+		t.Errorf("expected EMF entry to have the logged fields, but found %v", bufOut.String())
+	}
+
+	if entry.AWS.Timestamp != now.UnixNano()/int64(time.Millisecond) {
+		t.Errorf("expected EMF timestamp %v, but found %v", now.UnixNano()/int64(time.Millisecond), entry.AWS.Timestamp)
+	}
+
+	if len(entry.AWS.CloudWatchMetrics) != 1 {
+		t.Fatalf("expected 1 EMF metric directive, but found %v", bufOut.String())
+	}
+
+	directive := entry.AWS.CloudWatchMetrics[0]
+
+	if directive.Namespace != loggerName || !reflect.DeepEqual(directive.Dimensions, [][]string{{}}) {
+		t.Errorf("expected EMF directive namespace '%v' with no dimensions, but found %v", loggerName, bufOut.String())
+	}
+
+	if len(directive.Metrics) != 2 || directive.Metrics[0].Name != "latency" || directive.Metrics[0].Unit != "Milliseconds" ||
+		directive.Metrics[1].Name != "size" || directive.Metrics[1].Unit != "None" {
+		t.Errorf("expected EMF metrics 'latency' and 'size', but found %v", bufOut.String())
+	}
+
+	// entries with no metrics have no metadata.
+	bufOut.Reset()
+
+	level.Info(logger).Log("msg", "no metrics")
+
+	record := make(map[string]interface{})
+
+	if err := json.Unmarshal(bufOut.Bytes(), &record); err != nil {
+		t.Fatalf("failed to parse EMF entry '%v', %v", bufOut.String(), err.Error())
+	}
+
+	if _, found := record["_aws"]; found || record["msg"] != "no metrics" {
+		t.Errorf("expected EMF entry with no metrics to have no metadata, but found %v", bufOut.String())
+	}
+}
+
+func TestMetricValue(t *testing.T) {
+	logger, logs := CaptureLogger(WithName(loggerName))
+
+	level.Info(logger).Log("latency", Metric("latency", 12.5, "Milliseconds"))
+
+	if lines := logs.Lines(); len(lines) != 1 || lines[0]["latency"] != 12.5 {
+		t.Errorf("expected the metric value to be logged as a number by the 'json' format, but found %v", lines)
+	}
+
+	if s := Metric("count", 3, "Count").String(); s != "3" {
+		t.Errorf("expected metric string '3', but found '%v'", s)
+	}
+
+	for _, c := range []struct {
+		v        interface{}
+		expected time.Time
+	}{
+		{"2020-01-02T03:04:05.006Z", time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)},
+		{int64(1577934245006), time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)},
+		{int64(1577934245006000000), time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)},
+		{"invalid", time.Time{}},
+	} {
+		if ts := getEMFTimestamp(c.v, time.Time{}); !ts.Equal(c.expected) {
+			t.Errorf("expected EMF timestamp of %v to be %v, but found %v", c.v, c.expected, ts)
+		}
+	}
+}
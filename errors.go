@@ -0,0 +1,139 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// ErrorKey is the key of the error message added by LogError.
+	ErrorKey = "error"
+	// ErrorChainKey is the key of the messages of the unwrapped error chain added by LogError.
+	ErrorChainKey = "error_chain"
+	// StackKey is the key of the error stack trace added by LogError.
+	StackKey = "stack"
+	// PanicKey is the key of the panic value added by Recover.
+	PanicKey = "panic"
+)
+
+// LogError logs the specified error with the error severity level along with the specified keyvals,
+// it adds the error message, the messages of the error chain starting with the error itself and
+// unwrapped using errors.Unwrap, and the stack trace of the innermost error that has a StackTrace
+// method if any, e.g. errors created by github.com/pkg/errors.
+// It calls the logger directly, so the caller is resolved just like using the level loggers.
+func LogError(logger log.Logger, err error, keyvals ...interface{}) error {
+	entry := []interface{}{level.Key(), level.ErrorValue()}
+
+	if err != nil {
+		var chain []string
+		var stack interface{}
+
+		for e := err; e != nil; e = errors.Unwrap(e) {
+			chain = append(chain, e.Error())
+
+			if s, ok := getStackTrace(e); ok {
+				stack = s
+			}
+		}
+
+		entry = append(entry, ErrorKey, err.Error(), ErrorChainKey, chain)
+
+		if stack != nil {
+			entry = append(entry, StackKey, stack)
+		}
+	}
+
+	return logger.Log(append(entry, keyvals...)...)
+}
+
+// Recover recovers from a panic and logs its value along with the stack trace of the panicking
+// goroutine with the error severity level, then if rethrow is set it panics again with the same
+// value, e.g. to let the process crash after the panic is logged. It does nothing if there's no
+// panic, and it must be deferred directly to recover from it, i.e. defer logging.Recover(logger, true).
+func Recover(logger log.Logger, rethrow bool) {
+	v := recover()
+
+	if v == nil {
+		return
+	}
+
+	logger.Log(level.Key(), level.ErrorValue(), "msg", "recovered from panic", PanicKey, fmt.Sprint(v), StackKey, captureStack())
+
+	if rethrow {
+		panic(v)
+	}
+}
+
+// returns the formatted result of the StackTrace method of the specified error if it has one,
+// it's resolved by reflection since its type differs by the package the error is created by.
+func getStackTrace(err error) (string, bool) {
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return "", false
+	}
+
+	return fmt.Sprintf("%+v", m.Call(nil)[0].Interface()), true
+}
+
+// this is the path of this package, its frames are skipped by captureStack except for the tests.
+var packagePath = reflect.TypeOf(multiAppenderInstrumentedLogger{}).PkgPath()
+
+// the maximum number of frames captured by captureStack.
+const maxStackFrames = 32
+
+// returns the formatted stack trace of the caller of the logger, one 'function file:line'
+// frame per line, skipping the frames of this package and go-kit's log package.
+func captureStack() string {
+	pcs := make([]uintptr, maxStackFrames+16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	var sb strings.Builder
+
+	for n := 0; n < maxStackFrames; {
+		frame, more := frames.Next()
+
+		if !isLoggingFrame(frame) {
+			fmt.Fprintf(&sb, "%v %v:%v\n", frame.Function, frame.File, frame.Line)
+			n++
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// checks if the specified frame belongs to this package or go-kit's log package.
+func isLoggingFrame(frame runtime.Frame) bool {
+	if strings.HasPrefix(frame.Function, packagePath+".") {
+		return !strings.HasSuffix(frame.File, "_test.go")
+	}
+
+	return strings.HasPrefix(frame.Function, "github.com/go-kit/kit/log.") ||
+		strings.HasPrefix(frame.Function, "github.com/go-kit/kit/log/level.")
+}
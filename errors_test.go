@@ -0,0 +1,184 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// this is an error carrying a fake stack trace like the ones of github.com/pkg/errors.
+type stackError struct {
+	msg string
+}
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) StackTrace() []string { return []string{"main.go:12", "server.go:34"} }
+
+func TestLogError(t *testing.T) {
+	root := &stackError{msg: "connection refused"}
+	err := fmt.Errorf("failed to query: %w", fmt.Errorf("failed to connect: %w", root))
+
+	logger, logs := CaptureLogger(WithName(loggerName))
+
+	LogError(logger, err, "query", "select")
+
+	lines := logs.ErrorLines()
+
+	if len(lines) != 1 {
+		t.Fatalf("expected an error entry, but found %v", logs.Lines())
+	}
+
+	line := lines[0]
+
+	expectedChain := []interface{}{
+		"failed to query: failed to connect: connection refused",
+		"failed to connect: connection refused",
+		"connection refused",
+	}
+
+	if !reflect.DeepEqual(line[ErrorChainKey], expectedChain) {
+		t.Errorf("expected error chain %v, but found %v", expectedChain, line[ErrorChainKey])
+	}
+
+	if line[ErrorKey] != expectedChain[0] || line["level"] != "error" || line["query"] != "select" {
+		t.Errorf("expected the error entry fields, but found %v", line)
+	}
+
+	if stack, _ := line[StackKey].(string); !strings.Contains(stack, "main.go:12") {
+		t.Errorf("expected the stack trace of the root error, but found '%v'", stack)
+	}
+
+	if caller, _ := line["caller"].(string); !strings.HasPrefix(caller, "errors_test.go:") {
+		t.Errorf("expected the caller to be this file, but found '%v'", caller)
+	}
+
+	logs.Reset()
+
+	LogError(logger, errors.New("plain"))
+	LogError(logger, nil, "msg", "no error")
+
+	lines = logs.ErrorLines()
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 error entries, but found %v", logs.Lines())
+	}
+
+	if _, found := lines[0][StackKey]; found || !reflect.DeepEqual(lines[0][ErrorChainKey], []interface{}{"plain"}) {
+		t.Errorf("expected a single error chain with no stack, but found %v", lines[0])
+	}
+
+	if _, found := lines[1][ErrorKey]; found || lines[1]["msg"] != "no error" {
+		t.Errorf("expected no error fields for a nil error, but found %v", lines[1])
+	}
+}
+
+func TestCaptureStackOnError(t *testing.T) {
+	logger, logs := CaptureLogger(WithName(loggerName), WithConfig(&Config{Level: "info", CaptureStackOnError: true}))
+
+	level.Error(logger).Log("msg", "failed")
+	logger.Error("msg", "failed")
+	level.Warn(logger).Log("msg", "warned")
+	LogError(logger, errors.New("failed"), StackKey, "custom")
+
+	lines := logs.Lines()
+
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 log entries, but found %v", lines)
+	}
+
+	for i := 0; i < 2; i++ {
+		stack, _ := lines[i][StackKey].(string)
+		frames := strings.Split(stack, "\n")
+
+		if !strings.HasPrefix(frames[0], packagePath+".TestCaptureStackOnError ") || !strings.Contains(frames[0], "errors_test.go:") {
+			t.Errorf("expected the stack to start at the test function, but found %v", stack)
+		}
+
+		if strings.Contains(stack, "go-kit") || strings.Contains(stack, "logger.go") {
+			t.Errorf("expected the stack to have no logging frames, but found %v", stack)
+		}
+	}
+
+	if _, found := lines[2][StackKey]; found {
+		t.Errorf("expected no stack for warnings, but found %v", lines[2])
+	}
+
+	if v := lines[3][StackKey]; v != "custom" {
+		t.Errorf("expected the entry's own stack to be kept, but found %v", v)
+	}
+
+	logger, logs = CaptureLogger(WithName(loggerName))
+	level.Error(logger).Log("msg", "failed")
+
+	if lines := logs.Lines(); len(lines) != 1 || lines[0][StackKey] != nil {
+		t.Errorf("expected no stack by default, but found %v", lines)
+	}
+}
+
+// panics with the specified value in a function guarded by Recover.
+func panicking(logger Logger, v interface{}, rethrow bool) {
+	defer Recover(logger, rethrow)
+
+	panic(v)
+}
+
+func TestRecover(t *testing.T) {
+	logger, logs := CaptureLogger(WithName(loggerName))
+
+	panicking(logger, "something went wrong", false)
+
+	lines := logs.ErrorLines()
+
+	if len(lines) != 1 || lines[0][PanicKey] != "something went wrong" || lines[0]["msg"] != "recovered from panic" {
+		t.Fatalf("expected an error entry with the panic value, but found %v", logs.Lines())
+	}
+
+	// the stack includes the panicking function of the tests.
+	if stack, _ := lines[0][StackKey].(string); !strings.Contains(stack, "panicking") {
+		t.Errorf("expected the stack to include the panicking function, but found '%v'", lines[0][StackKey])
+	}
+
+	func() {
+		defer func() {
+			if v := recover(); v != "rethrown" {
+				t.Errorf("expected the panic to be rethrown, but found %v", v)
+			}
+		}()
+
+		panicking(logger, "rethrown", true)
+	}()
+
+	if lines := logs.ErrorLines(); len(lines) != 2 || lines[1][PanicKey] != "rethrown" {
+		t.Errorf("expected an error entry for the rethrown panic, but found %v", logs.Lines())
+	}
+
+	// nothing is logged if there's no panic.
+	func() {
+		defer Recover(logger, true)
+	}()
+
+	if len(logs.Lines()) != 2 {
+		t.Errorf("expected no entry without a panic, but found %v", logs.Lines())
+	}
+}
@@ -0,0 +1,95 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"strings"
+
+	"github.com/go-kit/kit/metrics"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// EventLogEventID is the event id of all the entries written to the Windows Event Log,
+// it's within the 1-1000 range required by sources installed using EventCreate.exe.
+const EventLogEventID uint32 = 1
+
+// these are the event log types of the severity levels indexed by their ranks.
+var eventLogTypes = [...]uint32{
+	rankTrace: eventlog.Info,
+	rankDebug: eventlog.Info,
+	rankInfo:  eventlog.Info,
+	rankWarn:  eventlog.Warning,
+	rankError: eventlog.Error,
+}
+
+// this is a writer that writes to the event log with a fixed event type.
+type eventLogTypeWriter struct {
+	log   *eventlog.Log
+	etype uint32
+}
+
+func (w *eventLogTypeWriter) Write(p []byte) (int, error) {
+	// the event viewer shows the message as is, so the entry line break is dropped.
+	msg := strings.TrimSuffix(string(p), "\n")
+
+	var err error
+
+	switch w.etype {
+	case eventlog.Error:
+		err = w.log.Error(EventLogEventID, msg)
+	case eventlog.Warning:
+		err = w.log.Warning(EventLogEventID, msg)
+	default:
+		err = w.log.Info(EventLogEventID, msg)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// CreateEventLogLogger returns an instance of instrumented logger named after the specified
+// source that writes to the Windows Event Log, entries are formatted using the configured
+// format and reported with the event type matching their level, where 'error' is Error,
+// 'warn' is Warning, 'info', 'debug', 'trace' and entries with no level are Information.
+// The source should be already installed, e.g. using eventlog.InstallAsEventCreate,
+// otherwise the event viewer shows the entries with a missing description notice.
+// The logger is further configured by the specified options.
+// The logger should be closed when it's no longer needed to deregister the source.
+// If configuration level is set to 'none' then neither
+// logs nor monitoring will take place.
+func CreateEventLogLogger(source string, counter metrics.Counter, config *Config, opts ...Option) (Logger, error) {
+
+	el, err := eventlog.Open(source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append([]Option{WithName(source), WithCounter(counter), WithConfig(config)}, opts...)
+	opts = append(opts, withCloser(el))
+
+	for r := rankTrace; r <= rankError; r++ {
+		opts = append(opts, withLevelWriter(r, &eventLogTypeWriter{log: el, etype: eventLogTypes[r]}))
+	}
+
+	return NewLogger(opts...), nil
+}
@@ -0,0 +1,89 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log/level"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+func TestEventLogTypes(t *testing.T) {
+	for _, c := range []struct {
+		level string
+		etype uint32
+	}{
+		{"error", eventlog.Error},
+		{"warn", eventlog.Warning},
+		{"info", eventlog.Info},
+		{"debug", eventlog.Info},
+		{"trace", eventlog.Info},
+	} {
+		r, _ := lookupLevel(c.level)
+
+		if etype := eventLogTypes[r]; etype != c.etype {
+			t.Errorf("expected %v entries event type to be %v, but found %v", c.level, c.etype, etype)
+		}
+	}
+}
+
+func TestEventLogLogger(t *testing.T) {
+	const source = "adzr-logging-test"
+
+	// installing a source requires administrator privileges.
+	if err := eventlog.InstallAsEventCreate(source, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		t.Skipf("failed to install event log source, %v", err.Error())
+	}
+
+	defer eventlog.Remove(source)
+
+	counter := newFakeCounter()
+
+	logger, err := CreateEventLogLogger(source, counter, &Config{Level: "trace", Format: FormatJSON},
+		WithFields("service", "test"))
+
+	if err != nil {
+		t.Fatalf("failed to create event log logger, %v", err.Error())
+	}
+
+	for _, e := range []struct {
+		log   func() error
+		level string
+	}{
+		{func() error { return level.Error(logger).Log("msg", "error") }, "error"},
+		{func() error { return level.Warn(logger).Log("msg", "warn") }, "warn"},
+		{func() error { return level.Info(logger).Log("msg", "info") }, "info"},
+		{func() error { return level.Debug(logger).Log("msg", "debug") }, "debug"},
+		{func() error { return Trace(logger).Log("msg", "trace") }, "trace"},
+	} {
+		if err := e.log(); err != nil {
+			t.Errorf("failed to log %v entry, %v", e.level, err.Error())
+		}
+
+		if c := counter.value("level", e.level); c != 1 {
+			t.Errorf("expected %v counter to be 1, but found %v", e.level, c)
+		}
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Errorf("failed to close event log logger, %v", err.Error())
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// this is the time layout of the rotated log files suffix,
+// it sorts lexicographically in the same order as time.
+const rotationTimeLayout = "20060102T150405.000000000"
+
+// RotationConfig carries the configuration of a rotating log file.
+type RotationConfig struct {
+	// Path is the path of the log file, rotated files are kept next to it
+	// with the rotation time appended to their names.
+	Path string `json:"path"`
+	// MaxSizeBytes is the maximum size of the log file before it's rotated, if zero it's never rotated by size.
+	MaxSizeBytes int64 `json:"max_size_bytes"`
+	// MaxAgeDuration is the maximum age of the log file before it's rotated, if zero it's never rotated by age.
+	MaxAgeDuration time.Duration `json:"max_age_duration"`
+	// MaxBackups is the maximum number of rotated log files to keep, if zero all of them are kept.
+	MaxBackups int `json:"max_backups"`
+}
+
+// this is a file writer that rotates the file once it exceeds its maximum size or age,
+// and if required, compresses the file using gzip. It's safe for concurrent use.
+type rotatingFileWriter struct {
+	mtx         sync.Mutex
+	config      RotationConfig
+	compression *compression
+	file        *os.File
+	gz          *gzipWriter
+	size        int64
+	opened      time.Time
+}
+
+// returns a new rotating file writer with the log file opened for appending,
+// the entries are compressed if the specified compression isn't nil.
+func newRotatingFileWriter(config RotationConfig, c *compression) (*rotatingFileWriter, error) {
+	w := &rotatingFileWriter{config: config, compression: c}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// opens the log file for appending, creating it if it doesn't exist.
+func (w *rotatingFileWriter) open() error {
+	f, err := os.OpenFile(w.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	// appending a new gzip stream to a compressed file keeps it valid,
+	// since gzip readers read the concatenated streams one after the other.
+	if w.compression != nil {
+		gz, err := newGzipWriter(f, w.compression)
+
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		w.gz = gz
+	}
+
+	w.file, w.size, w.opened = f, info.Size(), time.Now()
+	return nil
+}
+
+// finalizes the gzip stream if the file is compressed and closes the file.
+func (w *rotatingFileWriter) close() error {
+	var err error
+
+	if w.gz != nil {
+		err = w.gz.Close()
+		w.gz = nil
+	}
+
+	if e := w.file.Close(); err == nil {
+		err = e
+	}
+
+	w.file = nil
+	return err
+}
+
+// closes the log file, renames it with the rotation time appended,
+// removes the old rotated files and then opens a new log file.
+func (w *rotatingFileWriter) rotate() error {
+	if err := w.close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(w.config.Path, w.config.Path+"."+time.Now().UTC().Format(rotationTimeLayout)); err != nil {
+		return err
+	}
+
+	if err := w.prune(); err != nil {
+		return err
+	}
+
+	return w.open()
+}
+
+// removes the oldest rotated files exceeding the maximum number of backups.
+func (w *rotatingFileWriter) prune() error {
+	if w.config.MaxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := filepath.Glob(w.config.Path + ".*")
+
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(backups)
+
+	for len(backups) > w.config.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// checks if the log file should be rotated before writing the specified number of bytes.
+func (w *rotatingFileWriter) shouldRotate(n int) bool {
+	if w.config.MaxSizeBytes > 0 && w.size > 0 && w.size+int64(n) > w.config.MaxSizeBytes {
+		return true
+	}
+
+	return w.config.MaxAgeDuration > 0 && time.Since(w.opened) >= w.config.MaxAgeDuration
+}
+
+func (w *rotatingFileWriter) Write(p []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.file == nil {
+		return 0, ErrClosed
+	}
+
+	if w.shouldRotate(len(p)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	// the size of a compressed file is estimated by the size of the entries before compression.
+	var n int
+	var err error
+
+	if w.gz != nil {
+		n, err = w.gz.Write(p)
+	} else {
+		n, err = w.file.Write(p)
+	}
+
+	w.size += int64(n)
+
+	return n, err
+}
+
+// Flush writes the compressed entries to the log file if it's compressed.
+func (w *rotatingFileWriter) Flush() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.gz == nil {
+		return nil
+	}
+
+	return w.gz.Flush()
+}
+
+// Close finalizes the gzip stream if the log file is compressed and closes the log file.
+func (w *rotatingFileWriter) Close() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	return w.close()
+}
+
+// CreateFileLogger returns an instance of instrumented logger that writes all
+// the logs to a file rotated based on the specified rotation configuration,
+// it returns an error if the file can't be opened. The logger is further configured by
+// the specified options, e.g. WithCompression compresses the file, in which case the
+// maximum size applies to the size of the entries before they're compressed.
+// The returned logger must be closed to close the file.
+// If configuration level is set to 'none' then neither
+// logs nor monitoring will take place.
+func CreateFileLogger(loggerName string, counter metrics.Counter, config *Config, rotation RotationConfig, opts ...Option) (Logger, error) {
+
+	opts = append([]Option{WithName(loggerName), WithCounter(counter), WithConfig(config)}, opts...)
+
+	// the writer needs the resolved compression.
+	o := resolveOptions(opts)
+
+	w, err := newRotatingFileWriter(rotation, o.compression)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newLogger(o.apply(WithOutputWriter(w), WithErrorWriter(w), withCloser(w))), nil
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// returns the content of all the rotated files of the specified log file in order and the log file itself.
+func readRotatedFiles(t *testing.T, path string) ([]string, string) {
+	backups, err := filepath.Glob(path + ".*")
+
+	if err != nil {
+		t.Fatalf("failed to list rotated files, %v", err.Error())
+	}
+
+	var contents []string
+
+	for _, b := range backups {
+		data, err := os.ReadFile(b)
+
+		if err != nil {
+			t.Fatalf("failed to read rotated file '%v', %v", b, err.Error())
+		}
+
+		contents = append(contents, string(data))
+	}
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatalf("failed to read log file, %v", err.Error())
+	}
+
+	return contents, string(data)
+}
+
+func TestFileLoggerRotateBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	logger, err := CreateFileLogger(loggerName, nil, &Config{Level: "debug", Format: "json"},
+		RotationConfig{Path: path, MaxSizeBytes: 256})
+
+	if err != nil {
+		t.Fatalf("failed to create file logger, %v", err.Error())
+	}
+
+	var expected [][]int
+
+	for i := 0; i < 20; i++ {
+		level.Info(logger).Log(fmt.Sprintf("key_%v%v", i, 0), fmt.Sprintf("val_%v%v", i, 0))
+		expected = append(expected, []int{i, 0})
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("failed to close file logger, %v", err.Error())
+	}
+
+	backups, current := readRotatedFiles(t, path)
+
+	if len(backups) == 0 {
+		t.Fatalf("expected rotated files, but found none")
+	}
+
+	for i, b := range backups {
+		if len(b) > 256 {
+			t.Errorf("expected rotated file %v size not to exceed 256 bytes, but found %v", i, len(b))
+		}
+	}
+
+	if err := validateLogs(strings.Join(backups, "")+current, expected); err != nil {
+		t.Errorf("failed to validate rotated logs, %v", err.Error())
+	}
+}
+
+func TestFileLoggerRotateByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	logger, err := CreateFileLogger(loggerName, nil, &Config{Level: "debug", Format: "json"},
+		RotationConfig{Path: path, MaxAgeDuration: 10 * time.Millisecond, MaxBackups: 1})
+
+	if err != nil {
+		t.Fatalf("failed to create file logger, %v", err.Error())
+	}
+
+	for i := 0; i < 3; i++ {
+		level.Error(logger).Log(fmt.Sprintf("key_%v%v", i, 0), fmt.Sprintf("val_%v%v", i, 0))
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("failed to close file logger, %v", err.Error())
+	}
+
+	backups, current := readRotatedFiles(t, path)
+
+	if err := validateLogs(strings.Join(backups, ""), [][]int{{1, 0}}); err != nil {
+		t.Errorf("failed to validate rotated logs, %v", err.Error())
+	}
+
+	if err := validateLogs(current, [][]int{{2, 0}}); err != nil {
+		t.Errorf("failed to validate current logs, %v", err.Error())
+	}
+}
+
+func TestFileLoggerInvalidPath(t *testing.T) {
+	if _, err := CreateFileLogger(loggerName, nil, Configuration(),
+		RotationConfig{Path: filepath.Join(t.TempDir(), "missing", "test.log")}); err == nil {
+		t.Errorf("expected an error for an invalid path, but found none")
+	}
+}
+
+func TestFileLoggerAsyncClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	logger, err := CreateFileLogger(loggerName, nil, &Config{Level: "debug", Format: "json", Async: true, BufferSize: 256},
+		RotationConfig{Path: path})
+
+	if err != nil {
+		t.Fatalf("failed to create file logger, %v", err.Error())
+	}
+
+	var expected [][]int
+
+	for i := 0; i < 200; i++ {
+		level.Info(logger).Log(fmt.Sprintf("key_%v%v", i, 0), fmt.Sprintf("val_%v%v", i, 0))
+		expected = append(expected, []int{i, 0})
+	}
+
+	// closing must write all the pending entries before closing the file.
+	if err := logger.Close(); err != nil {
+		t.Fatalf("failed to close file logger, %v", err.Error())
+	}
+
+	_, current := readRotatedFiles(t, path)
+
+	if err := validateLogs(current, expected); err != nil {
+		t.Errorf("failed to validate log file after closing, %v", err.Error())
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Errorf("expected closing twice to succeed, but found %v", err.Error())
+	}
+
+	if err := level.Info(logger).Log("key", "val"); err != ErrClosed {
+		t.Errorf("expected error '%v' after closing, but found '%v'", ErrClosed, err)
+	}
+}
+
+// returns the decompressed content of the specified gzipped file.
+func readGzipFile(t *testing.T, path string) string {
+	f, err := os.Open(path)
+
+	if err != nil {
+		t.Fatalf("failed to open compressed file, %v", err.Error())
+	}
+
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+
+	if err != nil {
+		t.Fatalf("failed to read compressed file '%v', %v", path, err.Error())
+	}
+
+	data, err := io.ReadAll(gz)
+
+	if err != nil {
+		t.Fatalf("failed to decompress file '%v', %v", path, err.Error())
+	}
+
+	return string(data)
+}
+
+func TestFileLoggerCompression(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log.gz")
+
+	logger, err := CreateFileLogger(loggerName, nil, &Config{Level: "debug", Format: "json"},
+		RotationConfig{Path: path}, WithCompression(gzip.BestCompression, -1))
+
+	if err != nil {
+		t.Fatalf("failed to create file logger, %v", err.Error())
+	}
+
+	var expected [][]int
+
+	for i := 0; i < 100; i++ {
+		level.Info(logger).Log(fmt.Sprintf("key_%v%v", i, 0), fmt.Sprintf("val_%v%v", i, 0))
+		expected = append(expected, []int{i, 0})
+	}
+
+	// the stream is finalized by closing the logger.
+	if err := logger.Close(); err != nil {
+		t.Fatalf("failed to close file logger, %v", err.Error())
+	}
+
+	if err := validateLogs(readGzipFile(t, path), expected); err != nil {
+		t.Errorf("failed to validate compressed logs, %v", err.Error())
+	}
+
+	// reopening the file appends a new stream to it.
+	logger, err = CreateFileLogger(loggerName, nil, &Config{Level: "debug", Format: "json"},
+		RotationConfig{Path: path, MaxSizeBytes: 1024}, WithCompression(0, 0))
+
+	if err != nil {
+		t.Fatalf("failed to reopen file logger, %v", err.Error())
+	}
+
+	for i := 100; i < 120; i++ {
+		level.Error(logger).Log(fmt.Sprintf("key_%v%v", i, 0), fmt.Sprintf("val_%v%v", i, 0))
+		expected = append(expected, []int{i, 0})
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("failed to close file logger, %v", err.Error())
+	}
+
+	backups, err := filepath.Glob(path + ".*")
+
+	if err != nil || len(backups) == 0 {
+		t.Fatalf("expected rotated files, but found %v", backups)
+	}
+
+	var logs strings.Builder
+
+	for _, b := range append(backups, path) {
+		logs.WriteString(readGzipFile(t, b))
+	}
+
+	if err := validateLogs(logs.String(), expected); err != nil {
+		t.Errorf("failed to validate rotated compressed logs, %v", err.Error())
+	}
+}
+
+func TestFileLoggerInvalidCompression(t *testing.T) {
+	if _, err := CreateFileLogger(loggerName, nil, Configuration(),
+		RotationConfig{Path: filepath.Join(t.TempDir(), "test.log.gz")}, WithCompression(42, 0)); err == nil {
+		t.Errorf("expected an error for an invalid compression level, but found none")
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// GCPSourceLocationKey is the key of the source location of log entries in the 'gcp' format.
+const GCPSourceLocationKey = "logging.googleapis.com/sourceLocation"
+
+// GCPSeverity returns the Google Cloud Logging severity matching the specified
+// severity level value, 'trace' is mapped to DEBUG and unknown values to DEFAULT.
+func GCPSeverity(v interface{}) string {
+	r, ok := getLevelRank(v)
+
+	if !ok {
+		return "DEFAULT"
+	}
+
+	switch r {
+	case rankError:
+		return "ERROR"
+	case rankWarn:
+		return "WARNING"
+	case rankInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+// this is a logger that rewrites the log entries keys and level values to
+// match Google Cloud Logging structured logs before passing them to a JSON logger.
+type gcpLogger struct {
+	next         log.Logger
+	timestampKey string
+}
+
+// returns a factory that creates GCP loggers that resolve the timestamp using the specified key.
+func createGCPLoggerFactory(timestampKey string) func(io.Writer) log.Logger {
+	return func(w io.Writer) log.Logger {
+		return &gcpLogger{next: log.NewJSONLogger(w), timestampKey: timestampKey}
+	}
+}
+
+func (l *gcpLogger) Log(keyvals ...interface{}) error {
+	entry := make([]interface{}, 0, len(keyvals))
+
+	for i := 0; i < len(keyvals)-1; i += 2 {
+		k, v := keyvals[i], keyvals[i+1]
+
+		switch k {
+		case l.timestampKey:
+			entry = append(entry, "time", v)
+		case level.Key():
+			entry = append(entry, "severity", GCPSeverity(v))
+		case "msg", "message":
+			entry = append(entry, "message", v)
+		case CallerKey:
+			entry = append(entry, GCPSourceLocationKey, getGCPSourceLocation(fmt.Sprint(v)))
+		default:
+			entry = append(entry, k, v)
+		}
+	}
+
+	return l.next.Log(entry...)
+}
+
+// returns the source location fields of the specified 'file:line' caller,
+// the line is a string just like Cloud Logging expects.
+func getGCPSourceLocation(caller string) map[string]interface{} {
+	location := map[string]interface{}{"file": caller}
+
+	if i := strings.LastIndex(caller, ":"); i >= 0 {
+		if _, err := strconv.Atoi(caller[i+1:]); err == nil {
+			location["file"], location["line"] = caller[:i], caller[i+1:]
+		}
+	}
+
+	return location
+}
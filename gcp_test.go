@@ -0,0 +1,89 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestGCPSeverity(t *testing.T) {
+	for v, expected := range map[interface{}]string{
+		level.ErrorValue(): "ERROR",
+		level.WarnValue():  "WARNING",
+		level.InfoValue():  "INFO",
+		level.DebugValue(): "DEBUG",
+		TraceValue():       "DEBUG",
+		"error":            "DEFAULT",
+		nil:                "DEFAULT",
+	} {
+		if s := GCPSeverity(v); s != expected {
+			t.Errorf("expected severity of %v to be %v, but found %v", v, expected, s)
+		}
+	}
+}
+
+func TestGCPFormat(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Format: FormatGCP}),
+		WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))
+
+	level.Error(logger).Log("msg", "error message")
+	level.Warn(logger).Log("msg", "warn message")
+
+	var entry struct {
+		Severity string `json:"severity"`
+		Message  string `json:"message"`
+		Time     string `json:"time"`
+		Logger   string `json:"logger"`
+		Location struct {
+			File string `json:"file"`
+			Line string `json:"line"`
+		} `json:"logging.googleapis.com/sourceLocation"`
+	}
+
+	if err := json.Unmarshal(bufErr.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to parse GCP entry '%v', %v", bufErr.String(), err.Error())
+	}
+
+	if entry.Severity != "ERROR" || entry.Message != "error message" || entry.Logger != loggerName {
+		t.Errorf("expected error entry to have the GCP fields, but found %v", bufErr.String())
+	}
+
+	if _, err := time.Parse(time.RFC3339Nano, entry.Time); err != nil {
+		t.Errorf("failed to parse GCP time '%v', %v", entry.Time, err.Error())
+	}
+
+	if entry.Location.File != "gcp_test.go" || entry.Location.Line == "" {
+		t.Errorf("expected error entry source location to be this file, but found %v", bufErr.String())
+	}
+
+	record := make(map[string]interface{})
+
+	if err := json.Unmarshal(bufOut.Bytes(), &record); err != nil {
+		t.Fatalf("failed to parse GCP entry '%v', %v", bufOut.String(), err.Error())
+	}
+
+	if record["severity"] != "WARNING" || record["message"] != "warn message" || record["level"] != nil {
+		t.Errorf("expected warn entry to be written to out with the GCP fields, but found %v", bufOut.String())
+	}
+}
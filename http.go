@@ -0,0 +1,295 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+const (
+	// DefaultBatchSize is the default number of log entries posted at once by HTTP loggers.
+	DefaultBatchSize = 100
+	// DefaultFlushInterval is the default interval HTTP loggers post their pending log entries at.
+	DefaultFlushInterval = time.Second
+	// these are the number of retries of posting a batch and the initial delay between them.
+	maxPostRetries  = 5
+	minRetryBackoff = 100 * time.Millisecond
+	// this is the timeout of each post request.
+	postTimeout = 10 * time.Second
+)
+
+// ErrHTTPStatus is wrapped by the errors of posting log entries that failed with an unexpected response status.
+var ErrHTTPStatus = errors.New("unexpected http response status")
+
+// this is a writer that accumulates the log entries and posts them as a JSON array
+// on a background goroutine once the batch is full or the flush interval elapses.
+// Batches that fail with a server error are retried with an exponential backoff,
+// while those which fail with a client error are dropped right away.
+// The pending entries are bounded, the oldest ones are dropped when it's full.
+type httpWriter struct {
+	endpoint    string
+	client      *http.Client
+	batchSize   int
+	capacity    int
+	interval    time.Duration
+	dropCounter metrics.Counter
+	onError     func(error)
+	mtx         sync.Mutex
+	batch       []pendingEntry
+	closed      bool
+	full        chan struct{}
+	flushes     chan chan struct{}
+	done        chan struct{}
+	stopped     chan struct{}
+}
+
+// returns a new HTTP writer posting to the specified endpoint, it starts the background
+// goroutine which keeps running until the returned writer is closed.
+// The pending entries are bounded by the specified capacity, which is
+// never less than the batch size so a batch can always fill up.
+func newHTTPWriter(endpoint string, batchSize int, interval time.Duration, capacity int,
+	dropCounter metrics.Counter, onError func(error)) *httpWriter {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	if capacity <= 0 {
+		capacity = DefaultBufferSize
+	}
+
+	if capacity < batchSize {
+		capacity = batchSize
+	}
+
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+
+	w := &httpWriter{endpoint: endpoint, client: &http.Client{Timeout: postTimeout}, batchSize: batchSize,
+		capacity: capacity, interval: interval, dropCounter: dropCounter, onError: onError, full: make(chan struct{}, 1),
+		flushes: make(chan chan struct{}), done: make(chan struct{}), stopped: make(chan struct{})}
+
+	go w.run()
+
+	return w
+}
+
+func (w *httpWriter) write(p []byte, label string) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	// the caller may reuse the slice once we return, so we keep a copy.
+	w.batch = append(w.batch, pendingEntry{data: bytes.TrimSpace(append([]byte(nil), p...)), label: label})
+
+	// while the endpoint is down the entries keep piling up, so the oldest ones are dropped.
+	if len(w.batch) > w.capacity {
+		if w.dropCounter != nil {
+			w.dropCounter.With("level", w.batch[0].label).Add(1)
+		}
+		w.batch = w.batch[1:]
+	}
+
+	if len(w.batch) >= w.batchSize {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return len(p), nil
+}
+
+// posts the pending entries whenever the batch is full, the interval elapses
+// or it's flushed, until the writer is closed.
+func (w *httpWriter) run() {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.post()
+		case <-w.full:
+			w.post()
+		case flushed := <-w.flushes:
+			w.post()
+			close(flushed)
+		case <-w.done:
+			w.post()
+			return
+		}
+	}
+}
+
+// posts the pending entries in batches retrying the ones failing with server errors.
+func (w *httpWriter) post() {
+	for {
+		w.mtx.Lock()
+		n := len(w.batch)
+
+		if n > w.batchSize {
+			n = w.batchSize
+		}
+
+		batch := w.batch[:n]
+		w.batch = w.batch[n:]
+		w.mtx.Unlock()
+
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := w.postBatch(batch); err != nil {
+			for _, e := range batch {
+				if w.dropCounter != nil {
+					w.dropCounter.With("level", e.label).Add(1)
+				}
+			}
+
+			if w.onError != nil {
+				w.onError(err)
+			}
+		}
+	}
+}
+
+// posts the specified entries as a JSON array, retrying with an exponential
+// backoff if it fails with a server error or the request fails.
+func (w *httpWriter) postBatch(batch []pendingEntry) error {
+	var body bytes.Buffer
+
+	body.WriteByte('[')
+
+	for i, e := range batch {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(e.data)
+	}
+
+	body.WriteByte(']')
+
+	backoff := minRetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		resp, err := w.client.Post(w.endpoint, "application/json", bytes.NewReader(body.Bytes()))
+
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode < 300 {
+				return nil
+			}
+
+			err = fmt.Errorf("%w '%v'", ErrHTTPStatus, resp.Status)
+
+			// client errors won't go away by retrying.
+			if resp.StatusCode < 500 {
+				return err
+			}
+		}
+
+		if attempt >= maxPostRetries {
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Flush blocks until all the log entries written so far are posted.
+func (w *httpWriter) Flush() error {
+	flushed := make(chan struct{})
+
+	select {
+	case w.flushes <- flushed:
+		<-flushed
+		return nil
+	case <-w.stopped:
+		return ErrClosed
+	}
+}
+
+// Close posts the pending entries and stops the background goroutine.
+func (w *httpWriter) Close() error {
+	w.mtx.Lock()
+
+	if w.closed {
+		w.mtx.Unlock()
+		return nil
+	}
+
+	w.closed = true
+	close(w.done)
+	w.mtx.Unlock()
+
+	<-w.stopped
+	return nil
+}
+
+// CreateHTTPLogger returns an instance of instrumented logger that posts the log entries in
+// batches to the specified endpoint as JSON arrays, a batch is posted once it has the specified
+// number of entries or the specified interval elapses, if they're zero or less then the defaults
+// are used. Batches failing with server errors are retried with an exponential backoff while
+// those which fail with client errors are dropped, the dropped entries are counted by the drop
+// counter and the errors are passed to the error handler if they're set by the specified options.
+// The pending entries are buffered up to the configured buffer size, or the batch size if it's
+// larger, dropping the oldest ones when the buffer is full, which are counted by the drop counter.
+// The entries are always formatted as JSON unless a JSON based format is configured.
+// The logger must be closed when it's no longer needed to post the final batch.
+// If configuration level is set to 'none' then neither
+// logs nor monitoring will take place.
+func CreateHTTPLogger(loggerName string, counter metrics.Counter, config *Config, endpoint string,
+	batchSize int, flushInterval time.Duration, opts ...Option) (Logger, error) {
+
+	if _, err := url.ParseRequestURI(endpoint); err != nil {
+		return nil, err
+	}
+
+	opts = append([]Option{WithName(loggerName), WithCounter(counter), WithConfig(config)}, opts...)
+
+	// the writer needs the resolved configuration, drop counter and error handler.
+	o := resolveOptions(opts)
+
+	// entries are posted within a JSON array, so they must be JSON objects.
+	o.apply(WithConfig(withJSONFormats(o.config)))
+
+	w := newHTTPWriter(endpoint, batchSize, flushInterval, o.config.BufferSize, o.dropCounter, o.errorHandler)
+
+	for r := rankTrace; r <= rankError; r++ {
+		o.apply(withLevelWriter(r, &levelLabelWriter{w: w, label: levelNames[r]}))
+	}
+
+	return newLogger(o.apply(withCloser(w))), nil
+}
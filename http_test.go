@@ -0,0 +1,256 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// this is an HTTP server keeping the posted batches,
+// it responds with the specified statuses in order then with 200.
+type batchServer struct {
+	*httptest.Server
+	mtx      sync.Mutex
+	batches  [][]map[string]interface{}
+	attempts int
+	statuses []int
+}
+
+func newBatchServer(t *testing.T, statuses ...int) *batchServer {
+	s := &batchServer{statuses: statuses}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+
+		s.attempts++
+
+		if len(s.statuses) > 0 {
+			status := s.statuses[0]
+			s.statuses = s.statuses[1:]
+			rw.WriteHeader(status)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		var batch []map[string]interface{}
+
+		if err := json.Unmarshal(body, &batch); err != nil {
+			t.Errorf("failed to parse posted batch '%v', %v", string(body), err.Error())
+		}
+
+		if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("expected JSON content type, but found '%v'", contentType)
+		}
+
+		s.batches = append(s.batches, batch)
+	}))
+
+	return s
+}
+
+// returns the posted batches and the number of attempts.
+func (s *batchServer) received() ([][]map[string]interface{}, int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return append([][]map[string]interface{}{}, s.batches...), s.attempts
+}
+
+func TestHTTPLoggerBatching(t *testing.T) {
+	server := newBatchServer(t)
+	defer server.Close()
+
+	logger, err := CreateHTTPLogger(loggerName, nil, &Config{Format: FormatLogfmt}, server.URL, 3, time.Hour)
+
+	if err != nil {
+		t.Fatalf("failed to create HTTP logger, %v", err.Error())
+	}
+
+	for i := 0; i < 7; i++ {
+		level.Info(logger).Log("msg", "info", "i", i)
+	}
+
+	// the last entry is only posted once the logger is closed.
+	if err := logger.Close(); err != nil {
+		t.Errorf("failed to close HTTP logger, %v", err.Error())
+	}
+
+	batches, _ := server.received()
+
+	if len(batches) != 3 || len(batches[0]) != 3 || len(batches[1]) != 3 || len(batches[2]) != 1 {
+		t.Fatalf("expected batches of 3, 3 and 1 entries, but found %v", batches)
+	}
+
+	i := 0.0
+
+	for _, batch := range batches {
+		for _, entry := range batch {
+			if entry["i"] != i || entry["logger"] != loggerName {
+				t.Errorf("expected entry %v in order, but found %v", i, entry)
+			}
+			i++
+		}
+	}
+}
+
+func TestHTTPLoggerFlushInterval(t *testing.T) {
+	server := newBatchServer(t)
+	defer server.Close()
+
+	logger, err := CreateHTTPLogger(loggerName, nil, nil, server.URL, 100, 20*time.Millisecond)
+
+	if err != nil {
+		t.Fatalf("failed to create HTTP logger, %v", err.Error())
+	}
+
+	defer logger.Close()
+
+	level.Info(logger).Log("msg", "info")
+	level.Error(logger).Log("msg", "error")
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		if batches, _ := server.received(); len(batches) > 0 {
+			if len(batches) != 1 || len(batches[0]) != 2 {
+				t.Errorf("expected a single batch of 2 entries, but found %v", batches)
+			}
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("expected entries to be posted once the interval elapses, but found none")
+}
+
+func TestHTTPLoggerRetries(t *testing.T) {
+	for _, c := range []struct {
+		statuses []int
+		attempts int
+		posted   bool
+	}{
+		{[]int{http.StatusServiceUnavailable, http.StatusInternalServerError}, 3, true},
+		{[]int{http.StatusBadRequest}, 1, false},
+	} {
+		server := newBatchServer(t, c.statuses...)
+
+		dropCounter := newFakeCounter()
+
+		var errs []error
+
+		logger, err := CreateHTTPLogger(loggerName, nil, nil, server.URL, 10, time.Hour,
+			WithDropCounter(dropCounter), WithErrorHandler(func(err error) { errs = append(errs, err) }))
+
+		if err != nil {
+			t.Fatalf("failed to create HTTP logger, %v", err.Error())
+		}
+
+		level.Warn(logger).Log("msg", "warn")
+
+		if err := logger.Flush(); err != nil {
+			t.Errorf("failed to flush HTTP logger, %v", err.Error())
+		}
+
+		batches, attempts := server.received()
+
+		if attempts != c.attempts {
+			t.Errorf("expected %v attempts for statuses %v, but found %v", c.attempts, c.statuses, attempts)
+		}
+
+		if posted := len(batches) == 1; posted != c.posted {
+			t.Errorf("expected the batch to be posted to be %v for statuses %v, but found %v", c.posted, c.statuses, batches)
+		}
+
+		if !c.posted {
+			if d := dropCounter.value("level", "warn"); d != 1 {
+				t.Errorf("expected the dropped entry to be counted, but found %v", d)
+			}
+
+			if len(errs) != 1 || !errors.Is(errs[0], ErrHTTPStatus) {
+				t.Errorf("expected the error handler to be called with the status error, but found %v", errs)
+			}
+		}
+
+		logger.Close()
+		server.Close()
+	}
+}
+
+func TestHTTPLoggerInvalidEndpoint(t *testing.T) {
+	if _, err := CreateHTTPLogger(loggerName, nil, nil, "not a url", 0, 0); err == nil {
+		t.Errorf("expected an invalid endpoint to fail, but found no error")
+	}
+}
+
+func TestHTTPWriterDropOldest(t *testing.T) {
+	posting, release := make(chan struct{}), make(chan struct{})
+
+	// the endpoint hangs on the first batch until it's released.
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		select {
+		case posting <- struct{}{}:
+			<-release
+		default:
+		}
+	}))
+
+	defer server.Close()
+
+	dropCounter := newFakeCounter()
+
+	w := newHTTPWriter(server.URL, 1, time.Hour, 3, dropCounter, nil)
+	defer w.Close()
+
+	if _, err := w.write([]byte(`{"i":0}`), "warn"); err != nil {
+		t.Errorf("failed to write entry, %v", err.Error())
+	}
+
+	<-posting
+
+	for i := 1; i < 6; i++ {
+		if _, err := w.write([]byte(fmt.Sprintf(`{"i":%v}`, i)), "warn"); err != nil {
+			t.Errorf("expected writing while the endpoint is down to succeed, but found %v", err.Error())
+		}
+	}
+
+	w.mtx.Lock()
+
+	if len(w.batch) != 3 || string(w.batch[0].data) != `{"i":3}` || string(w.batch[2].data) != `{"i":5}` {
+		t.Errorf("expected the 3 newest entries to be pending, but found %v", w.batch)
+	}
+
+	w.mtx.Unlock()
+
+	close(release)
+
+	if c := dropCounter.value("level", "warn"); c != 2 {
+		t.Errorf("expected 2 dropped warn entries, but found %v", c)
+	}
+}
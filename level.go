@@ -0,0 +1,251 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// these are the ranks of the supported severity levels, the more severe
+// the level the higher its rank, a log entry is allowed only if its level
+// rank is not less than the rank of the configured level.
+const (
+	rankAll = iota
+	rankTrace
+	rankDebug
+	rankInfo
+	rankWarn
+	rankError
+	rankNone
+)
+
+// ErrInvalidLevel is the error wrapped by all the errors returned for invalid logging levels.
+var ErrInvalidLevel = errors.New("invalid logging level")
+
+// these are the names of the severity levels indexed by their ranks.
+var levelNames = [...]string{
+	rankAll:   "all",
+	rankTrace: "trace",
+	rankDebug: "debug",
+	rankInfo:  "info",
+	rankWarn:  "warn",
+	rankError: "error",
+	rankNone:  "none",
+}
+
+// go-kit has no trace level, and its level values can't be implemented
+// outside of its package, so this is our own value for trace entries.
+type traceLevelValue struct {
+	name string
+}
+
+func (v *traceLevelValue) String() string { return v.name }
+
+// this is the unique value of trace log entries.
+var traceValue = &traceLevelValue{name: levelNames[rankTrace]}
+
+// TraceValue returns the unique value added to log entries by Trace.
+func TraceValue() fmt.Stringer {
+	return traceValue
+}
+
+// Trace returns a logger that includes a level.Key()/TraceValue() pair,
+// trace is the least severe level, it's below debug.
+func Trace(logger log.Logger) log.Logger {
+	return log.WithPrefix(logger, level.Key(), traceValue)
+}
+
+// these are the alternative names of the severity levels used by other
+// systems, they're accepted wherever a level string is.
+var levelAliases = map[string]string{
+	"warning":  "warn",
+	"err":      "error",
+	"critical": "error",
+	"fatal":    "error",
+	"verbose":  "debug",
+}
+
+// checks if the specified level string matches to
+// a valid logger level and returns its rank if it does,
+// else it returns the rank of "all" which lets all
+// logs to go through.
+func getValidLevel(l string) int {
+	r, _ := lookupLevel(l)
+	return r
+}
+
+// SupportedLevels returns the names of the supported logging severity levels from the least
+// severe to 'none', the names are matched case-insensitively along with their aliases, e.g.
+// 'warning', and the returned slice is a copy.
+func SupportedLevels() []string {
+	return append([]string(nil), levelNames[rankTrace:]...)
+}
+
+// returns the rank of the specified level string or alias and whether it's a valid level or not.
+func lookupLevel(l string) (int, bool) {
+	l = strings.ToLower(strings.TrimSpace(l))
+
+	if name, ok := levelAliases[l]; ok {
+		l = name
+	}
+
+	for r := rankTrace; r <= rankNone; r++ {
+		if levelNames[r] == l {
+			return r, true
+		}
+	}
+
+	return rankAll, false
+}
+
+// ParseLevel returns the go-kit level filter option matching the specified level string,
+// it returns an error if the string isn't a valid level, unlike the loggers created by this
+// package which let all logs go through on invalid levels.
+// The 'none' level returns an option that allows no logs, and since go-kit has no trace
+// level the 'trace' level returns an option that allows all logs.
+func ParseLevel(l string) (level.Option, error) {
+	r, ok := lookupLevel(l)
+
+	if !ok {
+		return nil, fmt.Errorf("%w '%v'", ErrInvalidLevel, l)
+	}
+
+	switch r {
+	case rankNone:
+		return level.AllowNone(), nil
+	case rankError:
+		return level.AllowError(), nil
+	case rankWarn:
+		return level.AllowWarn(), nil
+	case rankInfo:
+		return level.AllowInfo(), nil
+	case rankDebug:
+		return level.AllowDebug(), nil
+	default:
+		return level.AllowAll(), nil
+	}
+}
+
+// returns the rank of the specified error level, or -1 if it's empty so the entries written
+// to stderr are filtered by the logger level, invalid levels let all of them go through.
+func getValidErrorLevel(l string) int {
+	if strings.TrimSpace(l) == "" {
+		return -1
+	}
+
+	return getValidLevel(l)
+}
+
+// returns the ranks of the specified valid levels that should be written to stderr,
+// invalid levels are ignored and if nil, only errors are written to stderr.
+func getValidStderrLevels(levels []string) []int {
+	if levels == nil {
+		return []int{rankError}
+	}
+
+	ranks := make([]int, 0, len(levels))
+
+	for _, l := range levels {
+		if r, ok := lookupLevel(l); ok && r != rankNone {
+			ranks = append(ranks, r)
+		}
+	}
+
+	return ranks
+}
+
+// returns the rank of the specified log entry level value
+// if it's one of the known severity level values.
+func getLevelRank(v interface{}) (int, bool) {
+	switch v {
+	case level.ErrorValue():
+		return rankError, true
+	case level.WarnValue():
+		return rankWarn, true
+	case level.InfoValue():
+		return rankInfo, true
+	case level.DebugValue():
+		return rankDebug, true
+	case traceValue:
+		return rankTrace, true
+	default:
+		return rankAll, false
+	}
+}
+
+// returns the severity level value of the specified rank.
+func getLevelValue(r int) interface{} {
+	switch r {
+	case rankError:
+		return level.ErrorValue()
+	case rankWarn:
+		return level.WarnValue()
+	case rankInfo:
+		return level.InfoValue()
+	case rankDebug:
+		return level.DebugValue()
+	default:
+		return traceValue
+	}
+}
+
+// LevelSeverity returns the syslog numeric severity matching the specified severity level value,
+// i.e. 3 for error, 4 for warn, 6 for info and 7 for debug and trace. Unknown values are
+// mapped to info just like the entries having no level.
+func LevelSeverity(v interface{}) int {
+	r, _ := getLevelRank(v)
+
+	switch r {
+	case rankError:
+		return 3
+	case rankWarn:
+		return 4
+	case rankDebug, rankTrace:
+		return 7
+	default:
+		return 6
+	}
+}
+
+// returns the severity level rank of the specified log entry and whether it has a level key
+// at all, entries with no level key are ranked as info. It returns false if the entry has
+// a level key but its value isn't a known severity level value.
+func getEntryLevel(keyvals []interface{}) (int, bool, bool) {
+	for i := 0; i < len(keyvals)-1; i += 2 {
+		if keyvals[i] == level.Key() {
+			r, ok := getLevelRank(keyvals[i+1])
+			return r, true, ok
+		}
+	}
+
+	return rankInfo, false, true
+}
+
+// returns the value of the level gauge for the specified severity level rank, the
+// more levels are allowed the higher the value, so 'all' has the same value as 'trace'.
+func getLevelGaugeValue(r int) float64 {
+	if r == rankAll {
+		r = rankTrace
+	}
+
+	return float64(rankNone - r)
+}
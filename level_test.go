@@ -0,0 +1,522 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+)
+
+func TestTraceLevel(t *testing.T) {
+	for _, c := range []struct {
+		filter   string
+		expected [][]int
+		emitted  float64
+	}{
+		{"trace", [][]int{{1, 0}, {1, 1}}, 1},
+		{"debug", [][]int{{1, 1}}, 0},
+		{"info", nil, 0},
+	} {
+		var bufOut, bufErr bytes.Buffer
+
+		counter, dropCounter := newFakeCounter(), newFakeCounter()
+		logger := NewLogger(WithName(loggerName), WithCounter(counter), WithDropCounter(dropCounter),
+			WithConfig(&Config{Level: c.filter, Format: "json"}), WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))
+
+		Trace(logger).Log("key_10", "val_10")
+		level.Debug(logger).Log("key_11", "val_11")
+
+		if err := validateLogs(bufOut.String(), c.expected); err != nil {
+			t.Errorf("failed to validate out writer at level '%v', %v", c.filter, err.Error())
+		}
+
+		if bufErr.Len() != 0 {
+			t.Errorf("expected no err logs at level '%v', but found '%v'", c.filter, bufErr.String())
+		}
+
+		if v := counter.value("level", "trace") + dropCounter.value("level", "trace"); v != 1 {
+			t.Errorf("expected total counter value 1 for level 'trace' at level '%v', but found %v", c.filter, v)
+		}
+
+		if v := counter.value("level", "trace"); v != c.emitted {
+			t.Errorf("expected counter value %v for level 'trace' at level '%v', but found %v", c.emitted, c.filter, v)
+		}
+	}
+}
+
+func TestFilteredNotCounted(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	counter, dropCounter := newFakeCounter(), newFakeCounter()
+	logger := NewLogger(WithName(loggerName), WithCounter(counter), WithDropCounter(dropCounter),
+		WithConfig(&Config{Level: "warn", Format: "json"}), WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))
+
+	level.Debug(logger).Log("key_10", "val_10")
+	level.Warn(logger).Log("key_11", "val_11")
+
+	if err := validateLogs(bufOut.String(), [][]int{{1, 1}}); err != nil {
+		t.Errorf("failed to validate out writer, %v", err.Error())
+	}
+
+	// only the written entries are counted as emitted, the filtered ones are counted as dropped.
+	for _, c := range []struct {
+		level            string
+		emitted, dropped float64
+	}{
+		{"debug", 0, 1},
+		{"warn", 1, 0},
+	} {
+		if v := counter.value("level", c.level); v != c.emitted {
+			t.Errorf("expected counter value %v for level '%v', but found %v", c.emitted, c.level, v)
+		}
+
+		if v := dropCounter.value("level", c.level); v != c.dropped {
+			t.Errorf("expected drop counter value %v for level '%v', but found %v", c.dropped, c.level, v)
+		}
+	}
+}
+
+func TestTraceValue(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := CreateSyncLogger(loggerName, nil, &Config{Level: "trace", Format: "json"}, &bufOut, &bufErr)
+
+	Trace(logger).Log("key", "val")
+
+	record := make(map[string]string)
+
+	if err := json.Unmarshal(bufOut.Bytes(), &record); err != nil {
+		t.Fatalf("failed to parse log entry, %v", err.Error())
+	}
+
+	if v := record["level"]; v != TraceValue().String() {
+		t.Errorf("expected level '%v', but found '%v'", TraceValue().String(), v)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, c := range []struct {
+		level   string
+		allowed []string
+	}{
+		{"none", nil},
+		{"error", []string{"error"}},
+		{"warn", []string{"error", "warn"}},
+		{"info", []string{"error", "warn", "info"}},
+		{"debug", []string{"error", "warn", "info", "debug"}},
+		{"trace", []string{"error", "warn", "info", "debug"}},
+		{"  InFo ", []string{"error", "warn", "info"}},
+		{"\tDEBUG\n", []string{"error", "warn", "info", "debug"}},
+		{"Warning", []string{"error", "warn"}},
+		{"fatal", []string{"error"}},
+	} {
+		opt, err := ParseLevel(c.level)
+
+		if err != nil {
+			t.Errorf("expected no error for level '%v', but found %v", c.level, err.Error())
+			continue
+		}
+
+		var allowed []string
+
+		logger := level.NewFilter(log.LoggerFunc(func(keyvals ...interface{}) error {
+			allowed = append(allowed, fmt.Sprint(keyvals[1]))
+			return nil
+		}), opt)
+
+		for _, l := range []func(log.Logger) log.Logger{level.Error, level.Warn, level.Info, level.Debug} {
+			l(logger).Log("key", "val")
+		}
+
+		if strings.Join(allowed, ",") != strings.Join(c.allowed, ",") {
+			t.Errorf("expected allowed levels %v for level '%v', but found %v", c.allowed, c.level, allowed)
+		}
+	}
+
+	for _, l := range []string{"", "infi", "all", "warnings"} {
+		if _, err := ParseLevel(l); err == nil {
+			t.Errorf("expected an error for level '%v', but found none", l)
+		}
+	}
+}
+
+func TestLevelAliases(t *testing.T) {
+	for alias, expected := range map[string]string{
+		"warning":    "warn",
+		" WARNING ":  "warn",
+		"err":        "error",
+		"critical":   "error",
+		"Fatal":      "error",
+		"verbose":    "debug",
+		"trace":      "trace",
+		"unknown":    "all",
+		"warn_level": "all",
+	} {
+		logger := NewLogger(WithConfig(&Config{Level: alias}), WithOutputWriter(&bytes.Buffer{}), WithErrorWriter(&bytes.Buffer{}))
+
+		if l := logger.Level(); l != expected {
+			t.Errorf("expected level '%v' for alias '%v', but found '%v'", expected, alias, l)
+		}
+
+		// unknown levels are still rejected when set explicitly and by the validation.
+		_, ok := lookupLevel(alias)
+
+		if err := logger.SetLevel(alias); (err == nil) != ok {
+			t.Errorf("expected setting level '%v' to succeed %v, but found %v", alias, ok, err)
+		}
+
+		if err := (&Config{Level: alias}).Validate(); (err == nil) != ok {
+			t.Errorf("expected level '%v' to be valid %v, but found %v", alias, ok, err)
+		}
+	}
+}
+
+func TestSupportedLevels(t *testing.T) {
+	levels := SupportedLevels()
+
+	if expected := []string{"trace", "debug", "info", "warn", "error", "none"}; strings.Join(levels, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected supported levels %v, but found %v", expected, levels)
+	}
+
+	for _, l := range levels {
+		if r, ok := lookupLevel(l); !ok || getValidLevel(strings.ToUpper(l)) != r || levelNames[r] != l {
+			t.Errorf("expected level '%v' to be valid, but found rank %v", l, r)
+		}
+
+		if err := (&Config{Level: l}).Validate(); err != nil {
+			t.Errorf("expected level '%v' to be valid, but found %v", l, err.Error())
+		}
+	}
+
+	levels[0] = "changed"
+
+	if SupportedLevels()[0] != "trace" {
+		t.Errorf("expected the supported levels to be a copy")
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	counter := newFakeCounter()
+	logger := CreateSyncLogger(loggerName, counter, &Config{Level: "debug", Format: "json"}, &bufOut, &bufErr)
+
+	level.Debug(logger).Log("key_10", "val_10")
+
+	if err := logger.SetLevel("info"); err != nil {
+		t.Fatalf("failed to set level, %v", err.Error())
+	}
+
+	level.Debug(logger).Log("key_11", "val_11")
+	level.Info(logger).Log("key_12", "val_12")
+
+	if err := logger.SetLevel("infi"); !errors.Is(err, ErrInvalidLevel) {
+		t.Errorf("expected error '%v' for an invalid level, but found '%v'", ErrInvalidLevel, err)
+	}
+
+	level.Debug(logger).Log("key_13", "val_13")
+
+	if err := logger.SetLevel("none"); err != nil {
+		t.Fatalf("failed to set level, %v", err.Error())
+	}
+
+	level.Error(logger).Log("key_14", "val_14")
+
+	if err := logger.SetLevel("DEBUG"); err != nil {
+		t.Fatalf("failed to set level, %v", err.Error())
+	}
+
+	level.Debug(logger).Log("key_15", "val_15")
+
+	if err := validateLogs(bufOut.String(), [][]int{{1, 0}, {1, 2}, {1, 5}}); err != nil {
+		t.Errorf("failed to validate out writer, %v", err.Error())
+	}
+
+	if bufErr.Len() != 0 {
+		t.Errorf("expected no err logs, but found '%v'", bufErr.String())
+	}
+
+	if v := counter.value("level", "error"); v != 0 {
+		t.Errorf("expected no error entries counted at level 'none', but found %v", v)
+	}
+}
+
+func TestConcurrentSetLevel(t *testing.T) {
+	const loggers, entries = 8, 500
+
+	counter, dropCounter := newFakeCounter(), newFakeCounter()
+	logger, logs := CaptureLogger(WithName(loggerName), WithCounter(counter), WithDropCounter(dropCounter),
+		WithConfig(&Config{Level: "debug"}))
+
+	done := make(chan struct{})
+	var setters, wg sync.WaitGroup
+
+	for i := 0; i < 2; i++ {
+		setters.Add(1)
+
+		go func(i int) {
+			defer setters.Done()
+
+			for lvls := []string{"error", "debug"}; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				if err := logger.SetLevel(lvls[i%2]); err != nil {
+					t.Errorf("failed to set level, %v", err.Error())
+					return
+				}
+
+				if l := logger.Level(); l != "error" && l != "debug" {
+					t.Errorf("expected level 'error' or 'debug', but found '%v'", l)
+				}
+			}
+		}(i)
+	}
+
+	for i := 0; i < loggers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < entries; j++ {
+				level.Info(logger).Log("key", "val")
+				level.Error(logger).Log("key", "val")
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	setters.Wait()
+
+	// errors are allowed by both levels, while infos are either written or dropped.
+	if n := len(logs.ErrorLines()); n != loggers*entries {
+		t.Errorf("expected %v error entries, but found %v", loggers*entries, n)
+	}
+
+	n := len(logs.OutputLines())
+
+	if v := counter.value("level", "info"); int(v) != n {
+		t.Errorf("expected counter value %v for level 'info', but found %v", n, v)
+	}
+
+	if v := counter.value("level", "info") + dropCounter.value("level", "info"); v != loggers*entries {
+		t.Errorf("expected total counter value %v for level 'info', but found %v", loggers*entries, v)
+	}
+}
+
+func TestLevelAndName(t *testing.T) {
+	for _, c := range []struct {
+		config, expected string
+	}{
+		{"none", "none"},
+		{"error", "error"},
+		{" Warn ", "warn"},
+		{"INFO", "info"},
+		{"debug", "debug"},
+		{"trace", "trace"},
+		{"infi", "all"},
+	} {
+		var bufOut, bufErr bytes.Buffer
+
+		logger := CreateSyncLogger(loggerName, nil, &Config{Level: c.config, Format: "json"}, &bufOut, &bufErr)
+
+		if l := logger.Level(); l != c.expected {
+			t.Errorf("expected level '%v' for configured level '%v', but found '%v'", c.expected, c.config, l)
+		}
+
+		if n := logger.Name(); n != loggerName {
+			t.Errorf("expected logger name '%v', but found '%v'", loggerName, n)
+		}
+	}
+
+	var bufOut, bufErr bytes.Buffer
+
+	logger := NewLogger(WithConfig(&Config{Level: "debug"}), WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))
+
+	if err := logger.SetLevel("warn"); err != nil {
+		t.Fatalf("failed to set level, %v", err.Error())
+	}
+
+	if l := logger.Level(); l != "warn" {
+		t.Errorf("expected level 'warn' after setting it, but found '%v'", l)
+	}
+}
+
+// this is an in-memory metrics gauge that keeps the last value set.
+type fakeGauge struct {
+	mtx sync.Mutex
+	val float64
+}
+
+func (g *fakeGauge) With(...string) metrics.Gauge { return g }
+
+func (g *fakeGauge) Set(value float64) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.val = value
+}
+
+func (g *fakeGauge) Add(delta float64) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.val += delta
+}
+
+func (g *fakeGauge) value() float64 {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.val
+}
+
+func TestLevelGauge(t *testing.T) {
+	for _, c := range []struct {
+		level    string
+		expected float64
+	}{
+		{"none", 0},
+		{"error", 1},
+		{"warn", 2},
+		{"info", 3},
+		{"debug", 4},
+		{"trace", 5},
+		{"infi", 5},
+	} {
+		var bufOut, bufErr bytes.Buffer
+
+		gauge := &fakeGauge{val: -1}
+
+		logger := NewLogger(WithConfig(&Config{Level: c.level}), WithLevelGauge(gauge),
+			WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))
+
+		if v := gauge.value(); v != c.expected {
+			t.Errorf("expected gauge value %v for level '%v', but found %v", c.expected, c.level, v)
+		}
+
+		if err := logger.SetLevel("debug"); err != nil {
+			t.Fatalf("failed to set level, %v", err.Error())
+		}
+
+		if v := gauge.value(); v != 4 {
+			t.Errorf("expected gauge value 4 after setting level 'debug', but found %v", v)
+		}
+
+		logger.SetLevel("invalid")
+
+		if v := gauge.value(); v != 4 {
+			t.Errorf("expected gauge value 4 after setting an invalid level, but found %v", v)
+		}
+	}
+}
+
+func TestLeveledMethods(t *testing.T) {
+	logger, logs := CaptureLogger(WithName(loggerName), WithConfig(&Config{Level: "trace"}))
+
+	logger.Trace("msg", "trace")
+	logger.Debug("msg", "debug")
+	logger.Info("msg", "info")
+	logger.Warn("msg", "warn")
+	logger.Error("msg", "error")
+
+	for _, c := range []struct {
+		lines  []map[string]interface{}
+		levels []string
+	}{
+		{logs.OutputLines(), []string{"trace", "debug", "info", "warn"}},
+		{logs.ErrorLines(), []string{"error"}},
+	} {
+		if len(c.lines) != len(c.levels) {
+			t.Errorf("expected %v entries, but found %v", len(c.levels), c.lines)
+			continue
+		}
+
+		for i, line := range c.lines {
+			if line["level"] != c.levels[i] || line["msg"] != c.levels[i] {
+				t.Errorf("expected %v entry, but found %v", c.levels[i], line)
+			}
+		}
+	}
+
+	// the leveled methods resolve the caller just like the level loggers.
+	if lines := logs.ErrorLines(); len(lines) == 1 {
+		if caller, _ := lines[0]["caller"].(string); !strings.HasPrefix(caller, "level_test.go:") {
+			t.Errorf("expected the caller to be this file, but found '%v'", caller)
+		}
+	}
+}
+
+func TestNumericLevel(t *testing.T) {
+	for v, expected := range map[interface{}]int{
+		level.ErrorValue(): 3,
+		level.WarnValue():  4,
+		level.InfoValue():  6,
+		level.DebugValue(): 7,
+		TraceValue():       7,
+		"error":            6,
+		nil:                6,
+	} {
+		if s := LevelSeverity(v); s != expected {
+			t.Errorf("expected severity of %v to be %v, but found %v", v, expected, s)
+		}
+	}
+
+	for _, key := range []string{"", "syslog_severity", "level"} {
+		logger, logs := CaptureLogger(WithName(loggerName), WithConfig(&Config{Level: "trace"}), WithNumericLevel(key))
+
+		logger.Error("msg", "error")
+		logger.Warn("msg", "warn")
+		logger.Info("msg", "info")
+		logger.Debug("msg", "debug")
+		logger.Trace("msg", "trace")
+		logger.Log("msg", "none")
+
+		lines := logs.Lines()
+
+		if len(lines) != 6 {
+			t.Fatalf("expected 6 log entries, but found %v", lines)
+		}
+
+		k := key
+
+		if k == "" {
+			k = SeverityKey
+		}
+
+		for i, expected := range []float64{3, 4, 6, 7, 7, 6} {
+			if v := lines[i][k]; v != expected {
+				t.Errorf("expected entry %v severity %v under key '%v', but found %v", lines[i]["msg"], expected, k, lines[i])
+			}
+
+			if _, found := lines[i]["level"]; k != "level" && i < 5 && !found {
+				t.Errorf("expected the string level to be kept, but found %v", lines[i])
+			}
+		}
+	}
+}
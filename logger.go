@@ -17,10 +17,15 @@ limitations under the License.
 package logging
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -28,12 +33,53 @@ import (
 )
 
 const (
+	// FormatJSON is the JSON logging output format.
+	FormatJSON = "json"
+	// FormatLogfmt is the logfmt logging output format.
+	FormatLogfmt = "logfmt"
+	// FormatECS is the Elastic Common Schema JSON logging output format.
+	FormatECS = "ecs"
+	// FormatGCP is the Google Cloud Logging structured JSON logging output format.
+	FormatGCP = "gcp"
+	// FormatEMF is the CloudWatch embedded metric format JSON logging output format.
+	FormatEMF = "emf"
+	// FormatProto is the size prefixed protobuf binary logging output format, e.g. for gRPC log streams.
+	FormatProto = "proto"
+	// FormatMsgpack is the MessagePack binary logging output format, e.g. for bandwidth-constrained sinks.
+	FormatMsgpack = "msgpack"
+	// FormatConsole is the human-friendly logging output format, it's colorized on terminals.
+	FormatConsole = "console"
 	// DefaultFormat is the default logging output format.
-	DefaultFormat = "json"
+	DefaultFormat = FormatJSON
 	// DefaultLevel is the default logging severity level.
 	DefaultLevel = "info"
+	// DefaultCallerDepth is the default stack depth used to resolve the caller of error logs.
+	DefaultCallerDepth = 5
+	// DefaultNameKey is the default key of the logger name added to every log entry.
+	DefaultNameKey = "logger"
+	// CallerKey is the key of the log entries caller.
+	CallerKey = "caller"
+	// DefaultUserKeyPrefix is the prefix of the log entry keys colliding with the keys added by the logger.
+	DefaultUserKeyPrefix = "user."
+	// DefaultTimestampKey is the default key of the log entries timestamp.
+	DefaultTimestampKey = "ts"
+	// TimestampRFC3339 is the timestamp format of RFC3339 UTC time with seconds precision.
+	TimestampRFC3339 = "rfc3339"
+	// TimestampRFC3339Nano is the timestamp format of RFC3339 UTC time with nanoseconds precision,
+	// it's the default timestamp format.
+	TimestampRFC3339Nano = "rfc3339nano"
+	// TimestampUnixMilli is the timestamp format of the number of milliseconds since the unix epoch.
+	TimestampUnixMilli = "unixmilli"
+	// TimestampUnixNano is the timestamp format of the number of nanoseconds since the unix epoch.
+	TimestampUnixNano = "unixnano"
 )
 
+// this is the counter label used for log entries that have no severity level.
+const noLevelLabel = "default"
+
+// this is the length of the logger name key-value pair every logger prefix starts with.
+const namePrefixLen = 2
+
 var (
 	// these are instances for std synchronized writers.
 	// they only need to be initialized once cause we
@@ -41,76 +87,365 @@ var (
 	// to stdout and stderr.
 	stdoutSyncWriter, stderrSyncWriter io.Writer
 	// and this is to make sure of that.
-	initializeWritersOnce sync.Once
+	writersMtx sync.Mutex
 )
 
+// ResetWriters clears the synchronized writers shared by the loggers writing to stdout
+// and stderr, so the loggers created afterwards write to the current os.Stdout and
+// os.Stderr, e.g. after a test replaced them. The loggers created before keep writing
+// to the writers they were created with.
+func ResetWriters() {
+	writersMtx.Lock()
+	defer writersMtx.Unlock()
+
+	stdoutSyncWriter, stderrSyncWriter = nil, nil
+}
+
 // Config carries service logging configuration.
 type Config struct {
-	// Format is the logging output format, it can be only 'json' for now, any other value will be ignored.
-	Format string `json:"format"`
-	// Level is the logging severity level allowed, it can be 'none', 'error', 'warn', 'info', 'debug'.
+	// Format is the logging output format, it can be 'json', 'logfmt', 'ecs', 'gcp', 'emf' or 'console', any other value will fall back to 'json'.
+	Format string `json:"format" yaml:"format"`
+	// OutputFormat if set, overrides Format for the log entries written to the output writer.
+	OutputFormat string `json:"output_format" yaml:"output_format"`
+	// ErrorFormat if set, overrides Format for the log entries written to the error writer.
+	ErrorFormat string `json:"error_format" yaml:"error_format"`
+	// Level is the logging severity level allowed, it can be 'none', 'error', 'warn', 'info', 'debug', 'trace'
+	// or one of their common aliases, i.e. 'warning', 'err', 'critical', 'fatal' and 'verbose'.
 	// If set to 'none' no logs will appear.
-	Level string `json:"level"`
+	Level string `json:"level" yaml:"level"`
+	// ErrorLevel if set, is the severity level allowed for the log entries written to the error writer,
+	// i.e. the StderrLevels ones, instead of Level which then only filters the rest of the entries,
+	// e.g. 'none' suppresses them while the rest are still written. It has no effect if Level is 'none'.
+	ErrorLevel string `json:"error_level" yaml:"error_level"`
+	// CallerDepth is the stack depth used to resolve the caller of error logs, it should be
+	// increased by one for each extra wrapping layer between the caller and the logger.
+	// If set to zero the default depth is used.
+	CallerDepth int `json:"caller_depth" yaml:"caller_depth"`
+	// Async if set, log entries are buffered and written on a background goroutine,
+	// the logger then must be flushed or closed to make sure all entries are written.
+	Async bool `json:"async" yaml:"async"`
+	// BufferSize is the number of log entries an async logger can hold before they're written,
+	// if set to zero the default buffer size is used.
+	BufferSize int `json:"buffer_size" yaml:"buffer_size"`
+	// DropOnFull if set, an async logger drops log entries when its buffer is full,
+	// else logging blocks until there's room in the buffer.
+	DropOnFull bool `json:"drop_on_full" yaml:"drop_on_full"`
+	// StderrLevels are the severity levels of the log entries written to the error writer,
+	// the rest are written to the output writer. If nil, only errors are written to the
+	// error writer and if empty, all the entries are written to the output writer.
+	StderrLevels []string `json:"stderr_levels" yaml:"stderr_levels"`
+	// TimestampKey is the key of the log entries timestamp, if empty 'ts' is used.
+	TimestampKey string `json:"timestamp_key" yaml:"timestamp_key"`
+	// TimestampFormat is the format of the log entries timestamp, it can be 'rfc3339', 'rfc3339nano',
+	// 'unixmilli' or 'unixnano', if empty 'rfc3339nano' is used.
+	TimestampFormat string `json:"timestamp_format" yaml:"timestamp_format"`
+	// NameKey is the key of the logger name added to every log entry, if empty 'logger' is used.
+	NameKey string `json:"name_key" yaml:"name_key"`
+	// IncludeTimestamp if set to false, the timestamp isn't added to the logs, e.g. when it's
+	// added by the runtime like journald or docker, if nil it's added.
+	IncludeTimestamp *bool `json:"include_timestamp" yaml:"include_timestamp"`
+	// IncludeCaller if set to false, the caller isn't added to error logs, if nil it's added.
+	IncludeCaller *bool `json:"include_caller" yaml:"include_caller"`
+	// CallerOnAllLevels if set, the caller is added to all the logs instead of error logs only,
+	// unless IncludeCaller is set to false.
+	CallerOnAllLevels bool `json:"caller_on_all_levels" yaml:"caller_on_all_levels"`
+	// Pretty if set, JSON log entries are indented which is only meant for local development,
+	// it has no effect on the 'logfmt' format.
+	Pretty bool `json:"pretty" yaml:"pretty"`
+	// ErrorsToStdoutToo if set, the log entries written to the error writer are written to the
+	// output writer too, e.g. for collectors tailing stdout only, unless their level has a dedicated writer.
+	ErrorsToStdoutToo bool `json:"errors_to_stdout_too" yaml:"errors_to_stdout_too"`
+	// CaptureStackOnError if set, the stack trace of the caller is added to error logs under
+	// the 'stack' key unless they already have one, it's expensive so it's off by default.
+	CaptureStackOnError bool `json:"capture_stack_on_error" yaml:"capture_stack_on_error"`
+	// ForceColor if set, the 'console' format is colorized even if the writers aren't terminals,
+	// unless the NO_COLOR environment variable is set.
+	ForceColor bool `json:"force_color" yaml:"force_color"`
 }
 
 // Configuration returns a new instance of the default configurations for logging.
 func Configuration() *Config {
 	return &Config{
-		Format: "json",
-		Level:  "info",
-	}
-}
-
-// checks if the logger is configured not to log anything.
-func isLevelNone(l string) bool {
-	return "none" == strings.ToLower(strings.TrimSpace(l))
-}
-
-// checks if the specified level string matches to
-// a valid logger level and returns it if it does,
-// else it returns "AllowAll" which lets all
-// logs to go through.
-func getValidLevel(l string) level.Option {
-	switch strings.ToLower(strings.TrimSpace(l)) {
-	case "error":
-		return level.AllowError()
-	case "warn":
-		return level.AllowWarn()
-	case "info":
-		return level.AllowInfo()
-	case "debug":
-		return level.AllowDebug()
-	default:
-		return level.AllowAll()
+		Format:          "json",
+		Level:           "info",
+		CallerDepth:     DefaultCallerDepth,
+		StderrLevels:    []string{"error"},
+		TimestampKey:    DefaultTimestampKey,
+		TimestampFormat: TimestampRFC3339Nano,
+		NameKey:         DefaultNameKey,
 	}
 }
 
-// takes a format-type string and returns a factory
-// that creates a non-filtered logger with a writer.
-func createLoggerFactory(loggerType string) func(io.Writer) log.Logger {
-	switch strings.ToLower(strings.TrimSpace(loggerType)) {
-	default:
-		return log.NewJSONLogger
+// this is a supported logging output format.
+type format struct {
+	name string
+	// json is set if the log entries are serialized as JSON.
+	json bool
+	// this takes the configuration and the color flag and returns the format logger factory.
+	factory func(config *Config, color bool) func(io.Writer) log.Logger
+}
+
+// these are the supported formats in the order they're listed by SupportedFormats.
+var formats = [...]format{
+	{FormatJSON, true, func(*Config, bool) func(io.Writer) log.Logger { return log.NewJSONLogger }},
+	{FormatLogfmt, false, func(*Config, bool) func(io.Writer) log.Logger { return log.NewLogfmtLogger }},
+	{FormatECS, true, func(config *Config, _ bool) func(io.Writer) log.Logger {
+		return createECSLoggerFactory(getValidTimestampKey(config.TimestampKey), getValidNameKey(config.NameKey))
+	}},
+	{FormatGCP, true, func(config *Config, _ bool) func(io.Writer) log.Logger {
+		return createGCPLoggerFactory(getValidTimestampKey(config.TimestampKey))
+	}},
+	{FormatEMF, true, func(config *Config, _ bool) func(io.Writer) log.Logger {
+		return createEMFLoggerFactory(getValidTimestampKey(config.TimestampKey), getValidNameKey(config.NameKey))
+	}},
+	{FormatProto, false, func(config *Config, _ bool) func(io.Writer) log.Logger {
+		return createProtoLoggerFactory(getValidTimestampKey(config.TimestampKey), getValidNameKey(config.NameKey))
+	}},
+	{FormatMsgpack, false, func(*Config, bool) func(io.Writer) log.Logger { return createMsgpackLoggerFactory() }},
+	{FormatConsole, false, func(config *Config, color bool) func(io.Writer) log.Logger {
+		return createConsoleLoggerFactory(getValidTimestampKey(config.TimestampKey), color)
+	}},
+}
+
+// SupportedFormats returns the names of the supported logging output formats,
+// the names are matched case-insensitively and the returned slice is a copy.
+func SupportedFormats() []string {
+	names := make([]string, len(formats))
+
+	for i, f := range formats {
+		names[i] = f.name
+	}
+
+	return names
+}
+
+// returns the supported format matching the specified format-type string, if any.
+func lookupFormat(loggerType string) (*format, bool) {
+	name := strings.ToLower(strings.TrimSpace(loggerType))
+
+	for i := range formats {
+		if formats[i].name == name {
+			return &formats[i], true
+		}
 	}
+
+	return nil, false
 }
 
-// returns new synchronized stdOut & stdErr loggers based on the specified logger factory.
-func createSyncStdLoggers(loggerTypeFactory func(io.Writer) log.Logger) (log.Logger, log.Logger) {
+// checks if the specified format-type string is one of the supported formats.
+func isValidFormat(loggerType string) bool {
+	_, ok := lookupFormat(loggerType)
+	return ok
+}
+
+// checks if the specified format-type string is serialized as JSON,
+// the invalid formats are considered JSON since they fall back to 'json'.
+func isJSONFormat(loggerType string) bool {
+	f, ok := lookupFormat(loggerType)
+	return !ok || f.json
+}
+
+// returns the format of the log entries written to the error writer if stderr is set,
+// or to the output writer if not, falling back to the configured format if not overridden.
+func getStreamFormat(config *Config, stderr bool) string {
+	f := config.OutputFormat
+
+	if stderr {
+		f = config.ErrorFormat
+	}
+
+	if strings.TrimSpace(f) == "" {
+		return config.Format
+	}
+
+	return f
+}
+
+// returns a copy of the specified configuration where all the
+// formats that aren't JSON based are replaced by 'json'.
+func withJSONFormats(config *Config) *Config {
+	c := *config
+
+	for _, f := range []*string{&c.Format, &c.OutputFormat, &c.ErrorFormat} {
+		if *f != "" && !isJSONFormat(*f) {
+			*f = FormatJSON
+		}
+	}
+
+	return &c
+}
+
+// takes a format-type and returns a factory that creates a non-filtered logger with a
+// writer, the color flag is only used by the 'console' format, and the invalid formats
+// fall back to 'json'.
+func createLoggerFactory(format string, config *Config, color bool) func(io.Writer) log.Logger {
+	if f, ok := lookupFormat(format); ok {
+		return f.factory(config, color)
+	}
+
+	return log.NewJSONLogger
+}
 
-	// initialize the writers only once.
-	initializeWritersOnce.Do(func() {
+// returns a synchronized writer for the specified one, std writers
+// are wrapped only once and shared across all loggers while any other
+// writer gets wrapped on every call so it's never cached by the package.
+func createSyncWriter(w io.Writer) io.Writer {
+
+	if w != os.Stdout && w != os.Stderr {
+		return log.NewSyncWriter(w)
+	}
+
+	// initialize the std writers only once unless they're reset.
+	writersMtx.Lock()
+	defer writersMtx.Unlock()
+
+	if w == os.Stdout {
 		if stdoutSyncWriter == nil {
 			stdoutSyncWriter = log.NewSyncWriter(os.Stdout)
 		}
+		return stdoutSyncWriter
+	}
+
+	if stderrSyncWriter == nil {
+		stderrSyncWriter = log.NewSyncWriter(os.Stderr)
+	}
+
+	return stderrSyncWriter
+}
+
+// returns the specified caller depth, or the default one if not set.
+func getValidCallerDepth(depth int) int {
+	if depth <= 0 {
+		return DefaultCallerDepth
+	}
+	return depth
+}
+
+// checks if the specified timestamp format string is one of the supported formats.
+func isValidTimestampFormat(format string) bool {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case TimestampRFC3339, TimestampRFC3339Nano, TimestampUnixMilli, TimestampUnixNano:
+		return true
+	default:
+		return false
+	}
+}
+
+// takes a timestamp format string and returns a valuer resolving the log entry timestamp
+// using the specified clock, any other value falls back to 'rfc3339nano'.
+func createTimestampValuer(format string, now func() time.Time) log.Valuer {
+	nowUTC := func() time.Time { return now().UTC() }
+
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case TimestampRFC3339:
+		return log.TimestampFormat(nowUTC, time.RFC3339)
+	case TimestampUnixMilli:
+		return func() interface{} { return now().UnixNano() / int64(time.Millisecond) }
+	case TimestampUnixNano:
+		return func() interface{} { return now().UnixNano() }
+	default:
+		// this is what log.DefaultTimestampUTC does.
+		return log.TimestampFormat(nowUTC, time.RFC3339Nano)
+	}
+}
+
+// returns the specified timestamp key, or the default one if not set.
+func getValidTimestampKey(key string) string {
+	if key = strings.TrimSpace(key); key == "" {
+		return DefaultTimestampKey
+	}
+	return key
+}
+
+// returns the specified logger name key, or the default one if not set.
+func getValidNameKey(key string) string {
+	if key = strings.TrimSpace(key); key == "" {
+		return DefaultNameKey
+	}
+	return key
+}
+
+// returns the value of the specified optional flag, or the default one if not set.
+func getFlag(flag *bool, defaultValue bool) bool {
+	if flag == nil {
+		return defaultValue
+	}
+	return *flag
+}
+
+// returns the keyvals that every out & err log entry starts with, timestamps are resolved by the specified clock.
+func createAppenderContexts(config *Config, now func() time.Time) ([]interface{}, []interface{}) {
+	var timestamp []interface{}
+
+	if getFlag(config.IncludeTimestamp, true) {
+		timestamp = []interface{}{getValidTimestampKey(config.TimestampKey), createTimestampValuer(config.TimestampFormat, now)}
+	}
+
+	if !getFlag(config.IncludeCaller, true) {
+		return timestamp, timestamp
+	}
+
+	// both appenders are called through the same stack frames,
+	// so the caller is resolved using the same depth for both.
+	caller := append(append([]interface{}{}, timestamp...), CallerKey, log.Caller(getValidCallerDepth(config.CallerDepth)))
+
+	if config.CallerOnAllLevels {
+		return caller, caller
+	}
 
-		if stderrSyncWriter == nil {
-			stderrSyncWriter = log.NewSyncWriter(os.Stderr)
+	return timestamp, caller
+}
+
+// returns the keys added by the logger to the entries of an appender, i.e. the specified name key
+// and the keys of the specified appender context, so the caller key is reserved only if it's added.
+func getReservedKeys(nameKey string, context []interface{}) []string {
+	keys := []string{nameKey}
+
+	for i := 0; i < len(context)-1; i += 2 {
+		if k, ok := context[i].(string); ok {
+			keys = append(keys, k)
 		}
-	})
+	}
 
-	// now, we can use the writers to return as many loggers as we want by just calling the function.
-	return log.With(loggerTypeFactory(stdoutSyncWriter), "ts", log.DefaultTimestampUTC),
-		log.With(loggerTypeFactory(stderrSyncWriter), "ts", log.DefaultTimestampUTC, "caller", log.Caller(5))
+	return keys
+}
+
+// returns a new "appender" based on the specified logger formatting the entries,
+// every log entry appended starts with the specified keyvals.
+func createAppender(next log.Logger, keyvals []interface{}) log.Logger {
+	return log.With(next, keyvals...)
+}
+
+// Logger is the instrumented logger created by this package, it's a go-kit
+// logger that also exposes its configuration and runtime controls.
+type Logger interface {
+	log.Logger
+	io.Closer
+	// Name returns the logger name.
+	Name() string
+	// Level returns the logging severity level currently allowed.
+	Level() string
+	// SetLevel changes the logging severity level allowed.
+	SetLevel(string) error
+	// Flush blocks until all the pending log entries are written.
+	Flush() error
+	// Trace logs the specified keyvals with the trace severity level.
+	Trace(keyvals ...interface{}) error
+	// Debug logs the specified keyvals with the debug severity level.
+	Debug(keyvals ...interface{}) error
+	// Info logs the specified keyvals with the info severity level.
+	Info(keyvals ...interface{}) error
+	// Warn logs the specified keyvals with the warn severity level.
+	Warn(keyvals ...interface{}) error
+	// Error logs the specified keyvals with the error severity level.
+	Error(keyvals ...interface{}) error
+	// Fatal logs the specified keyvals with the error severity level, flushes
+	// the logger and then exits the process.
+	Fatal(keyvals ...interface{})
+	// With returns a child logger adding the specified keyvals to every log entry.
+	With(keyvals ...interface{}) Logger
+	// Stats returns the numbers of log entries emitted by level since the logger was created.
+	Stats() map[string]uint64
 }
 
 // this is to keep track of how many log entries has been sent
@@ -118,71 +453,701 @@ func createSyncStdLoggers(loggerTypeFactory func(io.Writer) log.Logger) (log.Log
 // for errors and another for the rest of the logs.
 // let's call these two loggers "appenders".
 type multiAppenderInstrumentedLogger struct {
-	loggers map[level.Value]log.Logger
-	counter metrics.Counter
-	name    string
+	loggers      map[int]log.Logger
+	counters     map[string]metrics.Counter
+	dropCounters map[string]metrics.Counter
+	stats        map[string]*uint64
+	name         string
+	prefix       []interface{}
+	level        *levelState
+	samplers     []sampler
+	transforms   []transform
+	middlewares  []Middleware
+	closers      []io.Closer
+	onError      func(error)
+	exitCode     int
+	exit         func(int)
+	reserved     map[int][]string
+	captureStack bool
+	latencies    map[string]metrics.Histogram
+	userPrefix   string
+	stderr       map[int]bool
+	errLevel     int
+	dropOnce     sync.Once
+}
+
+// this is the severity level allowed by a logger and the loggers derived from it.
+type levelState struct {
+	threshold int32
+	mtx       sync.Mutex
+	gauge     metrics.Gauge
 }
 
 func (l *multiAppenderInstrumentedLogger) Log(keyvals ...interface{}) error {
 
-	// here we loop through keys and values.
-	for i := 0; i < len(keyvals); i += 2 {
-		// check if this is the key that indicates the severity level of the log entry.
-		if k := keyvals[i]; k == level.Key() {
-			// if yes then get its value.
-			if v, ok := keyvals[i+1].(level.Value); ok {
-				// if we use a metrics counter then increment it for the resolved value.
-				if l.counter != nil {
-					l.counter.With("level", v.String()).Add(1)
-				}
+	// the latency is only measured if it's observed.
+	var start time.Time
+
+	if l.latencies != nil {
+		start = time.Now()
+	}
+
+	// a dangling key gets a missing value placeholder just like go-kit does,
+	// so we can safely read the value next to each key.
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, log.ErrMissingValue)
+	}
+
+	// the level is loaded once so the whole entry is handled by the same level
+	// even if it's changed concurrently, and if it's 'none' then there's
+	// neither logging nor monitoring.
+	threshold := int(atomic.LoadInt32(&l.level.threshold))
+
+	if threshold == rankNone {
+		return nil
+	}
 
-				// now if the loggers are defined - which they should be - get the logger
-				// that matches the severity level of the log entry and append the entry
-				// to that logger adding the logger name.
-				if l.loggers != nil {
-					if target := l.loggers[v.(level.Value)]; target != nil {
-						keyvals = append(keyvals, "logger", l.name)
-						return target.Log(keyvals...)
-					}
+	// the middlewares run before the entry is routed, so they may change its level.
+	keyvals = l.applyMiddlewares(keyvals)
+
+	// resolve the severity level of the log entry, entries of unknown levels are ignored.
+	r, leveled, ok := getEntryLevel(keyvals)
+
+	if !ok {
+		return nil
+	}
+
+	label := levelNames[r]
+
+	// entries with no severity level are counted under the default label and
+	// appended to the info logger instead of being lost, yet they're never
+	// filtered by the level just like go-kit level filters do.
+	if !leveled {
+		label = noLevelLabel
+	}
+
+	// if the severity level isn't allowed or the entry is sampled out then it's dropped,
+	// and if we use a drop counter then increment it for the resolved value.
+	if (leveled && r < l.threshold(r, threshold)) || !l.sample(r, keyvals) {
+		if c := l.dropCounters[label]; c != nil {
+			c.Add(1)
+		}
+		return nil
+	}
+
+	// if required, attach the stack trace to error entries without modifying the caller's keyvals.
+	if r == rankError && l.captureStack && !hasKey(keyvals, StackKey) {
+		keyvals = append(keyvals[:len(keyvals):len(keyvals)], StackKey, captureStack())
+	}
+
+	atomic.AddUint64(l.stats[label], 1)
+
+	// if we use a metrics counter then increment it for the resolved value.
+	if c := l.counters[label]; c != nil {
+		c.Add(1)
+	}
+
+	// now if the loggers are defined - which they should be - get the logger
+	// that matches the severity level of the log entry and append the
+	// normalized entry to that logger.
+	if l.loggers != nil {
+		if target := l.loggers[r]; target != nil {
+			var dropped string
+			keyvals, dropped = l.entry(r, keyvals)
+
+			// the error is returned anyway, yet it's usually ignored
+			// so the error handler lets it be noticed.
+			err := target.Log(keyvals...)
+
+			// the entries that timed out are dropped even though they were counted.
+			if errors.Is(err, ErrWriteTimeout) {
+				if c := l.dropCounters[label]; c != nil {
+					c.Add(1)
 				}
 			}
-			break
+
+			if err != nil && l.onError != nil {
+				l.onError(err)
+			}
+
+			if h := l.latencies[label]; h != nil {
+				h.Observe(time.Since(start).Seconds())
+			}
+
+			// the first time a reserved key is dropped, it's reported by a warning entry.
+			if dropped != "" {
+				l.warnDropped(dropped)
+			}
+
+			return err
 		}
 	}
 
 	return nil
 }
 
+// the leveled methods prepend the level to the entry and call Log directly instead
+// of using the go-kit level loggers, so they add no extra stack frames and the
+// caller is resolved with the same depth as using the level loggers.
+
+// Trace logs the specified keyvals with the trace severity level.
+func (l *multiAppenderInstrumentedLogger) Trace(keyvals ...interface{}) error {
+	return l.Log(append([]interface{}{level.Key(), traceValue}, keyvals...)...)
+}
+
+// Debug logs the specified keyvals with the debug severity level.
+func (l *multiAppenderInstrumentedLogger) Debug(keyvals ...interface{}) error {
+	return l.Log(append([]interface{}{level.Key(), level.DebugValue()}, keyvals...)...)
+}
+
+// Info logs the specified keyvals with the info severity level.
+func (l *multiAppenderInstrumentedLogger) Info(keyvals ...interface{}) error {
+	return l.Log(append([]interface{}{level.Key(), level.InfoValue()}, keyvals...)...)
+}
+
+// Warn logs the specified keyvals with the warn severity level.
+func (l *multiAppenderInstrumentedLogger) Warn(keyvals ...interface{}) error {
+	return l.Log(append([]interface{}{level.Key(), level.WarnValue()}, keyvals...)...)
+}
+
+// Error logs the specified keyvals with the error severity level.
+func (l *multiAppenderInstrumentedLogger) Error(keyvals ...interface{}) error {
+	return l.Log(append([]interface{}{level.Key(), level.ErrorValue()}, keyvals...)...)
+}
+
+// Fatal logs the specified keyvals with the error severity level, flushes the logger
+// and then exits the process with the configured exit code, it exits even if
+// the log entry wasn't written, e.g. if the level is set to 'none'.
+func (l *multiAppenderInstrumentedLogger) Fatal(keyvals ...interface{}) {
+	l.Log(append([]interface{}{level.Key(), level.ErrorValue()}, keyvals...)...)
+	l.Flush()
+	l.exit(l.exitCode)
+}
+
+// With returns a child logger adding the specified keyvals to every log entry after the ones
+// of the logger, they're added as they are so valuers aren't resolved and a level key among
+// them is ignored. The child shares everything else with the logger, i.e. its name, appenders,
+// metrics, level and resources, so changing the level or closing either of them affects both.
+func (l *multiAppenderInstrumentedLogger) With(keyvals ...interface{}) Logger {
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, log.ErrMissingValue)
+	}
+
+	prefix := append(make([]interface{}, 0, len(l.prefix)+len(keyvals)), l.prefix...)
+
+	for i := 0; i < len(keyvals); i += 2 {
+		if keyvals[i] != level.Key() {
+			prefix = append(prefix, keyvals[i], keyvals[i+1])
+		}
+	}
+
+	return &multiAppenderInstrumentedLogger{
+		loggers:      l.loggers,
+		counters:     l.counters,
+		dropCounters: l.dropCounters,
+		stats:        l.stats,
+		name:         l.name,
+		prefix:       prefix,
+		level:        l.level,
+		samplers:     l.samplers,
+		transforms:   l.transforms,
+		middlewares:  l.middlewares,
+		closers:      l.closers,
+		onError:      l.onError,
+		exitCode:     l.exitCode,
+		exit:         l.exit,
+		reserved:     l.reserved,
+		captureStack: l.captureStack,
+		latencies:    l.latencies,
+		userPrefix:   l.userPrefix,
+		stderr:       l.stderr,
+		errLevel:     l.errLevel,
+	}
+}
+
+// returns a copy of the specified log entry keeping only the first level key which is the
+// one the entry is routed by, e.g. if level loggers are chained, then applies the transforms
+// in order to it along with the fields of the child loggers which precede it, so they're
+// redacted or dropped just like the caller's keyvals, and finally prefixes it by the logger
+// name which is never transformed.
+// The keys colliding with the ones added by the logger are prefixed by the user prefix,
+// or dropped if it's empty and then one of them is returned as well.
+// The caller's keyvals are never modified, and unless there are transforms the
+// entry is allocated once since it's on the path of every log entry.
+func (l *multiAppenderInstrumentedLogger) entry(r int, keyvals []interface{}) ([]interface{}, string) {
+	entry := append(make([]interface{}, 0, len(l.prefix)+len(keyvals)), l.prefix...)
+
+	leveled, dropped := false, ""
+
+	for i := 0; i < len(keyvals)-1; i += 2 {
+		k := keyvals[i]
+
+		if k == level.Key() {
+			if leveled {
+				continue
+			}
+			leveled = true
+		} else if key, ok := k.(string); ok && l.isReserved(r, key) {
+			if l.userPrefix == "" {
+				dropped = key
+				continue
+			}
+			k = l.userPrefix + key
+		}
+
+		entry = append(entry, k, keyvals[i+1])
+	}
+
+	if len(l.transforms) == 0 {
+		return entry, dropped
+	}
+
+	fields := entry[namePrefixLen:]
+
+	for _, t := range l.transforms {
+		fields = t(fields)
+	}
+
+	return append(append(make([]interface{}, 0, namePrefixLen+len(fields)), l.prefix[:namePrefixLen]...), fields...), dropped
+}
+
+// checks if the specified key is one of the keys added by the logger itself
+// to the entries of the specified severity level rank.
+func (l *multiAppenderInstrumentedLogger) isReserved(r int, key string) bool {
+	for _, k := range l.reserved[r] {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// logs a warning about the specified dropped reserved key only the first time it's called.
+func (l *multiAppenderInstrumentedLogger) warnDropped(key string) {
+	l.dropOnce.Do(func() {
+		l.Log(level.Key(), level.WarnValue(), "msg", "dropped log entry keys colliding with reserved keys", "key", key)
+	})
+}
+
+// returns the log entry after passing a copy of it through the middlewares in order, the
+// caller's keyvals are returned as they are if there are no middlewares.
+func (l *multiAppenderInstrumentedLogger) applyMiddlewares(keyvals []interface{}) []interface{} {
+	if len(l.middlewares) == 0 {
+		return keyvals
+	}
+
+	keyvals = append(make([]interface{}, 0, len(keyvals)), keyvals...)
+
+	for _, m := range l.middlewares {
+		keyvals = m(keyvals)
+	}
+
+	// the middlewares may leave a dangling key as well.
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, log.ErrMissingValue)
+	}
+
+	return keyvals
+}
+
+// returns the threshold of the log entries of the specified severity level rank, which is the
+// error level for the ones written to the error writer if it's set, else the specified one.
+func (l *multiAppenderInstrumentedLogger) threshold(r, threshold int) int {
+	if l.errLevel >= 0 && l.stderr[r] {
+		return l.errLevel
+	}
+
+	return threshold
+}
+
+// checks if the log entries of the specified severity level rank are allowed by the current
+// level, or by the error level for the ones written to the error writer if it's set.
+func (l *multiAppenderInstrumentedLogger) enabled(r int) bool {
+	threshold := int(atomic.LoadInt32(&l.level.threshold))
+	return threshold != rankNone && r >= l.threshold(r, threshold)
+}
+
+// checks if the log entry of the specified severity level rank passes all the samplers.
+func (l *multiAppenderInstrumentedLogger) sample(r int, keyvals []interface{}) bool {
+	for _, s := range l.samplers {
+		if !s(r, keyvals) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Name returns the logger name.
+func (l *multiAppenderInstrumentedLogger) Name() string {
+	return l.name
+}
+
+// Stats returns the numbers of log entries emitted by level since the logger was created, the
+// entries having no level are counted under 'default'. The counts are the same ones of the
+// metrics counter, i.e. the filtered and sampled out entries aren't counted, and they're
+// shared with the child loggers.
+func (l *multiAppenderInstrumentedLogger) Stats() map[string]uint64 {
+	stats := make(map[string]uint64, len(l.stats))
+
+	for label, n := range l.stats {
+		stats[label] = atomic.LoadUint64(n)
+	}
+
+	return stats
+}
+
+// Level returns the logging severity level currently allowed, it's 'all'
+// if the logger was configured with an invalid level.
+func (l *multiAppenderInstrumentedLogger) Level() string {
+	return levelNames[atomic.LoadInt32(&l.level.threshold)]
+}
+
+// SetLevel changes the logging severity level allowed, it returns an error
+// if the specified level isn't valid, and the current level is kept.
+// It's safe to change the level while logging concurrently, each log entry is
+// filtered by the level loaded once when it's logged, so an entry logged while
+// the level is changed is filtered by either the old or the new level, never
+// by an invalid or a mix of both. The entries logged after SetLevel returns
+// are filtered by the new level unless it's changed again.
+func (l *multiAppenderInstrumentedLogger) SetLevel(lvl string) error {
+	r, ok := lookupLevel(lvl)
+
+	if !ok {
+		return fmt.Errorf("%w '%v'", ErrInvalidLevel, lvl)
+	}
+
+	l.setThreshold(r)
+	return nil
+}
+
+// stores the specified severity level rank as the threshold and updates the level gauge,
+// the level is set under a lock so the gauge always matches the last level set.
+func (l *multiAppenderInstrumentedLogger) setThreshold(r int) {
+	l.level.mtx.Lock()
+	defer l.level.mtx.Unlock()
+
+	atomic.StoreInt32(&l.level.threshold, int32(r))
+
+	if l.level.gauge != nil {
+		l.level.gauge.Set(getLevelGaugeValue(r))
+	}
+}
+
+// Flush blocks until all the pending log entries are written, it's only
+// needed for async loggers, for other loggers it returns immediately.
+func (l *multiAppenderInstrumentedLogger) Flush() error {
+	for _, c := range l.closers {
+		if f, ok := c.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close writes all the pending log entries and releases the resources held by the logger,
+// e.g. files and connections, the logger must not be used afterwards. It's safe to call
+// more than once and it's a no-op for sync loggers that don't own their writers.
+func (l *multiAppenderInstrumentedLogger) Close() error {
+	var err error
+
+	for _, c := range l.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// Instrument returns an instance of instrumented logger appending the log entries to the specified
+// go-kit loggers instead of writing them, errors are appended to err and the rest of the logs to
+// out, unless configured otherwise by the specified options. If err is nil, errors are appended to
+// out as well and vice versa, and if both are nil, the entries are discarded.
+// The appended entries are leveled, named and timestamped just like the written ones, and the
+// errors are given their caller, but they're formatted by the specified loggers, so the format
+// related configuration is ignored, e.g. Format and Async.
+// If configuration level is set to 'none' then neither
+// logs nor monitoring will take place.
+func Instrument(loggerName string, counter metrics.Counter, out, err log.Logger, opts ...Option) Logger {
+	switch {
+	case out == nil && err == nil:
+		out, err = log.NewNopLogger(), log.NewNopLogger()
+	case out == nil:
+		out = err
+	case err == nil:
+		err = out
+	}
+
+	return NewLogger(append([]Option{WithName(loggerName), WithCounter(counter)}, append(opts, func(o *options) {
+		o.outLogger, o.errLogger = out, err
+	})...)...)
+}
+
 // CreateStdSyncLogger returns an instance of stdout & stderr instrumented logger.
 // If configuration level is set to 'none' then neither
 // logs nor monitoring will take place.
-func CreateStdSyncLogger(loggerName string, counter metrics.Counter, config *Config) log.Logger {
+func CreateStdSyncLogger(loggerName string, counter metrics.Counter, config *Config) Logger {
+	return NewLogger(WithName(loggerName), WithCounter(counter), WithConfig(config))
+}
 
-	// if you're required to log nothing, then just return a dummy logger.
-	if isLevelNone(config.Level) {
-		return log.NewNopLogger()
+// CreateSyncLogger returns an instance of instrumented logger that writes errors
+// to the specified err writer and the rest of the logs to the specified out writer,
+// both writers are synchronized so they can be safely shared across goroutines.
+// If configuration level is set to 'none' then neither
+// logs nor monitoring will take place.
+func CreateSyncLogger(loggerName string, counter metrics.Counter, config *Config, out, err io.Writer) Logger {
+	return NewLogger(WithName(loggerName), WithCounter(counter), WithConfig(config),
+		WithOutputWriter(out), WithErrorWriter(err))
+}
+
+// NewLogger returns an instance of instrumented logger configured by the specified options,
+// by default it uses the default configuration, no metrics counter and writes errors
+// to stderr and the rest of the logs to stdout.
+// The returned logger must be flushed or closed before exiting
+// if it's configured to be async so no entries are lost.
+// If configuration level is set to 'none' then neither
+// logs nor monitoring will take place.
+func NewLogger(opts ...Option) Logger {
+	return newLogger(resolveOptions(opts))
+}
+
+// NewLoggerStrict is like NewLogger except that it returns an error wrapping ErrInvalidConfig
+// instead of falling back to the defaults if the resolved configuration isn't valid, e.g. if
+// the format is misspelled, the configuration is checked by Config.Validate.
+func NewLoggerStrict(opts ...Option) (Logger, error) {
+	o := resolveOptions(opts)
+
+	if err := o.config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return newLogger(o), nil
+}
+
+// returns an instance of instrumented logger configured by the specified resolved options.
+func newLogger(o *options) Logger {
+	stderrLevels := make(map[int]bool)
+
+	for _, r := range getValidStderrLevels(o.config.StderrLevels) {
+		stderrLevels[r] = true
+	}
+
+	var closers []io.Closer
+
+	// the drop counters are shared by the async writers and the logger.
+	dropCounters := getLevelCounters(o.dropCounter)
+
+	// get synchronized writers and if required, buffer
+	// their entries to be written in the background.
+	prepareWriter := func(w io.Writer) io.Writer {
+		w = createSyncWriter(w)
+
+		// if required, writing must never block for longer than the timeout.
+		if o.writeTimeout > 0 {
+			w = newTimeoutWriter(w, o.writeTimeout)
+		}
+
+		if o.config.Async {
+			asyncWriter := newAsyncWriter(w, o.config.BufferSize, o.config.DropOnFull, o.errorHandler, dropCounters)
+			closers = append(closers, asyncWriter)
+			return asyncWriter
+		}
+
+		return w
+	}
+
+	// the writers are prepared only if they're used by any level, so that
+	// the std writers aren't touched if all the levels have dedicated writers.
+	var out, err io.Writer
+
+	levelWriters := make(map[int]io.Writer)
+
+	// the same writer used by more than one level, e.g. if the output and the error
+	// writers are the same, is prepared once so they're synchronized together,
+	// if it can be compared at all.
+	prepared := make(map[io.Writer]io.Writer)
+
+	prepareSharedWriter := func(w io.Writer) io.Writer {
+		if w == nil || !reflect.TypeOf(w).Comparable() {
+			return prepareWriter(w)
+		}
+
+		if p, ok := prepared[w]; ok {
+			return p
+		}
+
+		p := prepareWriter(w)
+		prepared[w] = p
+
+		return p
+	}
+
+	// the caller-provided appenders need no writers.
+	for r := rankTrace; r <= rankError && o.outLogger == nil; r++ {
+		switch w := o.levelWriters[r]; {
+		case w != nil:
+			levelWriters[r] = prepareSharedWriter(w)
+		case stderrLevels[r] && err == nil:
+			err = prepareSharedWriter(o.err)
+		case !stderrLevels[r] && out == nil:
+			out = prepareSharedWriter(o.out)
+		}
+
+		// the entries written to err may be written to out too.
+		if stderrLevels[r] && o.levelWriters[r] == nil && o.config.ErrorsToStdoutToo && out == nil {
+			out = prepareSharedWriter(o.out)
+		}
+	}
+
+	// the resources owned by the logger are closed after the pending entries are written.
+	closers = append(closers, o.closers...)
+
+	// if required, collapse the repeated entries before they're formatted, the held
+	// entries must be written before the writers are flushed or closed.
+	var d *deduplicator
+
+	if o.dedupWindow > 0 {
+		d = newDeduplicator(o.dedupWindow, getValidTimestampKey(o.config.TimestampKey), o.errorHandler)
+		closers = append([]io.Closer{d}, closers...)
+	}
+
+	// the factories are created once for each format and colorization used by the appenders.
+	factories := make(map[string]func(io.Writer) log.Logger)
+
+	getFactory := func(format string, color bool) func(io.Writer) log.Logger {
+		k := fmt.Sprintf("%v/%v", format, color)
+
+		if f, ok := factories[k]; ok {
+			return f
+		}
+
+		f := createLoggerFactory(format, o.config, color)
+		factories[k] = f
+
+		return f
+	}
+
+	outContext, errContext := createAppenderContexts(o.config, o.now)
+
+	// now, create a map of "appenders" matching each severity level based on the factory
+	// chosen, all of them go to out except for the ones configured to go to err,
+	// unless they have dedicated writers.
+	loggers := make(map[int]log.Logger)
+
+	// returns the logger formatting the entries of the specified level rank written to the
+	// specified writer, the original writer is the one it was prepared of.
+	createFormatter := func(r int, w, original io.Writer, stderr bool) log.Logger {
+		format := getStreamFormat(o.config, stderr)
+
+		// the timed out entries written in the background are counted by their level.
+		if a, ok := w.(*asyncWriter); ok {
+			w = asyncLabelWriter{a: a, label: levelNames[r]}
+		}
+
+		// if required, indent the JSON entries.
+		if o.config.Pretty && isJSONFormat(format) {
+			w = &prettyWriter{w: w}
+		}
+
+		// if required, observe the size of each entry of the level.
+		if o.sizeHistogram != nil {
+			w = &sizeObservingWriter{w: w, histogram: o.sizeHistogram.With("level", levelNames[r])}
+		}
+
+		// the entries are colorized based on the writer they're finally written to.
+		return getFactory(format, isColorEnabled(o.config, original))(w)
 	}
 
-	// else get the severity level required.
-	lvl := getValidLevel(config.Level)
+	nameKey := getValidNameKey(o.config.NameKey)
+	reserved := make(map[int][]string)
 
-	// create two "appenders" for stdout and stderr based on the factory chosen.
-	outLogger, errLogger := createSyncStdLoggers(createLoggerFactory(config.Format))
+	for r := rankTrace; r <= rankError; r++ {
+		w, keyvals, original := out, outContext, o.out
 
-	// create a filter for the stdout "appender" based on the resolved severity level.
-	outLogger = level.NewFilter(outLogger, lvl)
+		if stderrLevels[r] {
+			w, keyvals, original = err, errContext, o.err
+		}
+
+		reserved[r] = getReservedKeys(nameKey, keyvals)
+
+		if lw := levelWriters[r]; lw != nil {
+			w, original = lw, o.levelWriters[r]
+		}
+
+		var next log.Logger
 
-	// now, create a map for the defined appenders matching each severity level.
-	loggers := make(map[level.Value]log.Logger)
+		switch {
+		case o.outLogger == nil:
+			next = createFormatter(r, w, original, stderrLevels[r])
 
-	// errors should only go to stderr.
-	loggers[level.ErrorValue()] = errLogger
+			// if required, the entries written to the error writer are written to the output writer too,
+			// they're forwarded below the appender context so the caller is resolved with the same depth.
+			if stderrLevels[r] && levelWriters[r] == nil && o.config.ErrorsToStdoutToo {
+				next = teeLogger{next, createFormatter(r, out, o.out, false)}
+			}
+		case stderrLevels[r] && o.config.ErrorsToStdoutToo:
+			next = teeLogger{o.errLogger, o.outLogger}
+		case stderrLevels[r]:
+			next = o.errLogger
+		default:
+			next = o.outLogger
+		}
+
+		// if required, validate the entries as they're finally formatted.
+		if o.schema != nil {
+			if handler := getSchemaHandler(o); handler != nil {
+				next = &schemaLogger{next: next, schema: o.schema, handler: handler}
+			}
+		}
+
+		// if required, sort the keys of the entries as they're finally formatted.
+		if o.sortKeys {
+			next = &sortedKeysLogger{next: next, timestampKey: getValidTimestampKey(o.config.TimestampKey)}
+		}
+
+		// if required, collapse the repeated entries.
+		if d != nil {
+			next = d.wrap(next)
+		}
+
+		// the static fields follow the context of each appender.
+		keyvals = append(append(make([]interface{}, 0, len(keyvals)+len(o.fields)), keyvals...), o.fields...)
+
+		loggers[r] = createAppender(next, keyvals)
+	}
+
+	// finally return an instrumented wrapping logger for the appenders we've created,
+	// filtering the entries based on the resolved severity level.
+	// the name and the counters children are resolved once since they're used by every log entry.
+	l := &multiAppenderInstrumentedLogger{
+		loggers:      loggers,
+		counters:     getLevelCounters(o.counter, o.counterLabels...),
+		dropCounters: dropCounters,
+		stats:        getLevelStats(),
+		name:         o.name,
+		prefix:       []interface{}{nameKey, o.name},
+		level:        &levelState{gauge: o.levelGauge},
+		samplers:     o.samplers,
+		transforms:   o.transforms,
+		middlewares:  o.middlewares,
+		closers:      closers,
+		onError:      o.errorHandler,
+		exitCode:     o.exitCode,
+		exit:         o.exit,
+		reserved:     reserved,
+		captureStack: o.config.CaptureStackOnError,
+		latencies:    getLevelHistograms(o.latencyHistogram),
+		userPrefix:   o.userPrefix,
+		stderr:       stderrLevels,
+		errLevel:     getValidErrorLevel(o.config.ErrorLevel),
+	}
 
-	// the rest to stdout
-	loggers[level.WarnValue()] = outLogger
-	loggers[level.InfoValue()] = outLogger
-	loggers[level.DebugValue()] = outLogger
+	l.setThreshold(getValidLevel(o.config.Level))
 
-	// finally return an instrumented wrapping logger for the appenders we've created.
-	return &multiAppenderInstrumentedLogger{name: loggerName, loggers: loggers, counter: counter}
+	return l
 }
@@ -20,16 +20,19 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
-	"github.com/go-kit/kit/metrics/prometheus"
-	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/go-kit/kit/metrics"
 )
 
 const (
@@ -56,20 +59,44 @@ type customLevelValue struct {
 func (v *customLevelValue) String() string { return v.name }
 func (v *customLevelValue) levelVal()      {}
 
+// this is an in-memory metrics counter that keeps
+// the accumulated value of each set of label values.
+type fakeCounter struct {
+	mtx    *sync.Mutex
+	values map[string]float64
+	lvs    []string
+}
+
+func newFakeCounter() *fakeCounter {
+	return &fakeCounter{mtx: &sync.Mutex{}, values: make(map[string]float64)}
+}
+
+func (c *fakeCounter) With(labelValues ...string) metrics.Counter {
+	return &fakeCounter{mtx: c.mtx, values: c.values, lvs: append(append([]string{}, c.lvs...), labelValues...)}
+}
+
+func (c *fakeCounter) Add(delta float64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.values[strings.Join(c.lvs, ",")] += delta
+}
+
+// returns the accumulated value for the specified label values.
+func (c *fakeCounter) value(labelValues ...string) float64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.values[strings.Join(labelValues, ",")]
+}
+
 func simulate(filter string, lvl func(log.Logger) log.Logger, keyVals ...interface{}) {
-	counter := stdprometheus.NewCounterVec(stdprometheus.CounterOpts{
-		Namespace: namespace,
-		Subsystem: subsystem,
-		Name:      metricName,
-		Help:      "Number of log entries for each severity level.",
-	}, []string{"level"})
-
-	if err := stdprometheus.Register(counter); err != nil {
+	counter, err := SafeCounter(strings.Join([]string{namespace, subsystem, metricName}, "_"),
+		"Number of log entries for each severity level.", []string{"level"})
+
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to register counter '%v', %v\n", strings.Join([]string{namespace, subsystem, metricName}, "_"), err.Error())
 	}
 
-	logger := CreateStdSyncLogger(loggerName, prometheus.NewCounter(counter),
-		&Config{Level: filter, Format: "json"})
+	var logger log.Logger = CreateStdSyncLogger(loggerName, counter, &Config{Level: filter, Format: "json"})
 
 	if lvl != nil {
 		logger = lvl(logger)
@@ -78,11 +105,6 @@ func simulate(filter string, lvl func(log.Logger) log.Logger, keyVals ...interfa
 	logger.Log(keyVals...)
 }
 
-func cleanPrometheusErrors(str string) string {
-	prometheusError := fmt.Sprintf("failed to register counter '%v', duplicate metrics collector registration attempted\n", strings.Join([]string{namespace, "logger_" + loggerName, "entries_total"}, "_"))
-	return strings.Replace(str, prometheusError, "", -1)
-}
-
 func collectLogs() (string, string) {
 
 	stdOutReader, stdOutWriter, _ := os.Pipe()
@@ -96,6 +118,10 @@ func collectLogs() (string, string) {
 
 	os.Stdout, os.Stderr = stdOutWriter, stdErrWriter
 
+	// the std loggers must write to the pipes even if other loggers wrote to stdout before.
+	ResetWriters()
+	defer ResetWriters()
+
 	for fi, f := range filters {
 		for li, l := range levels {
 			key := fmt.Sprintf("key_%v%v", fi, li)
@@ -111,7 +137,7 @@ func collectLogs() (string, string) {
 	io.Copy(&bufOut, stdOutReader)
 	io.Copy(&bufErr, stdErrReader)
 
-	return bufOut.String(), cleanPrometheusErrors(bufErr.String())
+	return bufOut.String(), bufErr.String()
 }
 
 func validateLogs(logs string, expected [][]int) error {
@@ -168,3 +194,716 @@ func TestConfiguration(t *testing.T) {
 			c.Format, c.Level)
 	}
 }
+
+// replaces the package std writers with the specified ones
+// and returns a function that restores the original writers.
+func swapStdWriters(out, err io.Writer) func() {
+	writersMtx.Lock()
+	defer writersMtx.Unlock()
+
+	origOut, origErr := stdoutSyncWriter, stderrSyncWriter
+	stdoutSyncWriter, stderrSyncWriter = log.NewSyncWriter(out), log.NewSyncWriter(err)
+
+	return func() {
+		writersMtx.Lock()
+		defer writersMtx.Unlock()
+
+		stdoutSyncWriter, stderrSyncWriter = origOut, origErr
+	}
+}
+
+func TestLogfmtFormat(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	defer swapStdWriters(&bufOut, &bufErr)()
+
+	logger := CreateStdSyncLogger(loggerName, nil, &Config{Level: "debug", Format: FormatLogfmt})
+
+	level.Info(logger).Log("key", "val")
+	level.Error(logger).Log("key", "val")
+
+	for name, logs := range map[string]string{"stdout": bufOut.String(), "stderr": bufErr.String()} {
+		if strings.HasPrefix(strings.TrimSpace(logs), "{") {
+			t.Errorf("expected logfmt %v entry, but found JSON '%v'", name, logs)
+		}
+
+		for _, kv := range []string{"key=val", "logger=" + loggerName} {
+			if !strings.Contains(logs, kv) {
+				t.Errorf("expected %v entry to contain '%v', but found '%v'", name, kv, logs)
+			}
+		}
+	}
+}
+
+func TestNewLoggerStrict(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	config := &Config{Level: "info", Format: "jsn"}
+
+	if logger, err := NewLoggerStrict(WithConfig(config), WithOutputWriter(&bufOut)); !errors.Is(err, ErrInvalidConfig) || logger != nil {
+		t.Errorf("expected an error wrapping ErrInvalidConfig for format 'jsn', but found (%v, %v)", logger, err)
+	}
+
+	// the lenient constructors fall back to JSON.
+	level.Info(CreateSyncLogger(loggerName, nil, config, &bufOut, &bufErr)).Log("key", "val")
+
+	var entry map[string]interface{}
+
+	if err := json.Unmarshal(bufOut.Bytes(), &entry); err != nil || entry["key"] != "val" {
+		t.Errorf("expected a JSON entry for format 'jsn', but found '%v'", bufOut.String())
+	}
+
+	bufOut.Reset()
+
+	logger, err := NewLoggerStrict(WithConfig(&Config{Level: "info", Format: "logfmt"}), WithOutputWriter(&bufOut))
+
+	if err != nil {
+		t.Fatalf("failed to create strict logger, %v", err.Error())
+	}
+
+	logger.Info("key", "val")
+
+	if !strings.Contains(bufOut.String(), "key=val") {
+		t.Errorf("expected a logfmt entry, but found '%v'", bufOut.String())
+	}
+}
+
+func TestSupportedFormats(t *testing.T) {
+	formats := SupportedFormats()
+
+	if len(formats) == 0 || formats[0] != DefaultFormat {
+		t.Errorf("expected the supported formats to start with '%v', but found %v", DefaultFormat, formats)
+	}
+
+	for _, f := range formats {
+		if !isValidFormat(f) || !isValidFormat(strings.ToUpper(f)) {
+			t.Errorf("expected format '%v' to be valid", f)
+		}
+
+		var buf bytes.Buffer
+
+		if err := createLoggerFactory(f, Configuration(), false)(&buf).Log("key", "val"); err != nil || buf.Len() == 0 {
+			t.Errorf("expected format '%v' to create a logger, but found (%v, '%v')", f, err, buf.String())
+		}
+
+		// only the formats serialized as JSON are JSON.
+		var entry map[string]interface{}
+
+		if err := json.Unmarshal(buf.Bytes(), &entry); (err == nil) != isJSONFormat(f) {
+			t.Errorf("expected format '%v' to be JSON %v, but found '%v'", f, isJSONFormat(f), buf.String())
+		}
+	}
+
+	formats[0] = "changed"
+
+	if SupportedFormats()[0] != DefaultFormat {
+		t.Errorf("expected the supported formats to be a copy")
+	}
+}
+
+func TestStreamFormats(t *testing.T) {
+	for _, c := range []struct {
+		config           *Config
+		outJSON, errJSON bool
+	}{
+		{&Config{Level: "debug", OutputFormat: FormatJSON, ErrorFormat: FormatLogfmt}, true, false},
+		{&Config{Level: "debug", Format: FormatLogfmt, OutputFormat: FormatJSON}, true, false},
+		{&Config{Level: "debug", Format: FormatLogfmt}, false, false},
+		{&Config{Level: "debug", Format: FormatJSON}, true, true},
+	} {
+		var bufOut, bufErr bytes.Buffer
+
+		logger := CreateSyncLogger(loggerName, nil, c.config, &bufOut, &bufErr)
+
+		level.Info(logger).Log("key", "val")
+		level.Error(logger).Log("key", "val")
+
+		for _, s := range []struct {
+			name string
+			logs string
+			json bool
+		}{
+			{"stdout", bufOut.String(), c.outJSON},
+			{"stderr", bufErr.String(), c.errJSON},
+		} {
+			var entry map[string]interface{}
+
+			if err := json.Unmarshal([]byte(s.logs), &entry); (err == nil) != s.json {
+				t.Errorf("expected %v entry of config (%v, %v, %v) to be JSON %v, but found '%v'",
+					s.name, c.config.Format, c.config.OutputFormat, c.config.ErrorFormat, s.json, s.logs)
+			}
+
+			if !s.json && !strings.Contains(s.logs, "key=val") {
+				t.Errorf("expected logfmt %v entry to contain 'key=val', but found '%v'", s.name, s.logs)
+			}
+		}
+	}
+}
+
+func TestCustomWriters(t *testing.T) {
+	var bufOut1, bufErr1, bufOut2, bufErr2 bytes.Buffer
+
+	logger1 := CreateSyncLogger(loggerName, nil, &Config{Level: "debug", Format: "json"}, &bufOut1, &bufErr1)
+	logger2 := CreateSyncLogger(loggerName, nil, &Config{Level: "debug", Format: "json"}, &bufOut2, &bufErr2)
+
+	level.Info(logger1).Log("key_11", "val_11")
+	level.Error(logger1).Log("key_12", "val_12")
+	level.Info(logger2).Log("key_21", "val_21")
+	level.Error(logger2).Log("key_22", "val_22")
+
+	for _, c := range []struct {
+		name     string
+		logs     string
+		expected [][]int
+	}{
+		{"first out", bufOut1.String(), [][]int{{1, 1}}},
+		{"first err", bufErr1.String(), [][]int{{1, 2}}},
+		{"second out", bufOut2.String(), [][]int{{2, 1}}},
+		{"second err", bufErr2.String(), [][]int{{2, 2}}},
+	} {
+		if err := validateLogs(c.logs, c.expected); err != nil {
+			t.Errorf("failed to validate %v writer, %v", c.name, err.Error())
+		}
+	}
+}
+
+func TestSharedCustomWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	// the same writer is synchronized once for both the output and the errors.
+	logger := CreateSyncLogger(loggerName, nil, &Config{Level: "debug", Format: "json"}, &buf, &buf)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				logger.Info("msg", "info")
+			} else {
+				logger.Error("msg", "error")
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if n := strings.Count(buf.String(), "\n"); n != 10 {
+		t.Errorf("expected 10 log entries, but found %v", n)
+	}
+}
+
+func TestNoLevel(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	counter := newFakeCounter()
+	logger := CreateSyncLogger(loggerName, counter, &Config{Level: "error", Format: "json"}, &bufOut, &bufErr)
+
+	logger.Log("key_11", "val_11")
+
+	if err := validateLogs(bufOut.String(), [][]int{{1, 1}}); err != nil {
+		t.Errorf("failed to validate out writer, %v", err.Error())
+	}
+
+	if err := validateLogs(bufErr.String(), nil); err != nil {
+		t.Errorf("failed to validate err writer, %v", err.Error())
+	}
+
+	if v := counter.value("level", noLevelLabel); v != 1 {
+		t.Errorf("expected counter value 1 for level '%v', but found %v", noLevelLabel, v)
+	}
+}
+
+func TestOddKeyVals(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := CreateSyncLogger(loggerName, nil, &Config{Level: "debug", Format: "json"}, &bufOut, &bufErr)
+
+	if err := logger.Log(level.Key()); err != nil {
+		t.Errorf("expected no error for a dangling level key, but found %v", err.Error())
+	}
+
+	level.Info(logger).Log("key_11", "val_11", "dangling")
+
+	if err := validateLogs(bufOut.String(), [][]int{{1, 1}}); err != nil {
+		t.Errorf("failed to validate out writer, %v", err.Error())
+	}
+
+	record := make(map[string]string)
+
+	if err := json.Unmarshal(bufOut.Bytes(), &record); err != nil {
+		t.Fatalf("failed to parse log entry, %v", err.Error())
+	}
+
+	if v := record["dangling"]; v != log.ErrMissingValue.Error() {
+		t.Errorf("expected dangling key value '%v', but found '%v'", log.ErrMissingValue.Error(), v)
+	}
+}
+
+// logs an error through an extra wrapping layer.
+func logErrorWrapped(logger log.Logger, keyvals ...interface{}) {
+	level.Error(logger).Log(keyvals...)
+}
+
+// returns the "file:line" of the caller of this function, shifted by the specified lines.
+func callerLine(shift int) string {
+	_, file, line, _ := runtime.Caller(1)
+	return fmt.Sprintf("%v:%v", filepath.Base(file), line+shift)
+}
+
+func TestCallerDepth(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		depth int
+		log   func(log.Logger) string
+	}{
+		{"default", 0, func(logger log.Logger) string {
+			level.Error(logger).Log("key", "val")
+			return callerLine(-1)
+		}},
+		{"wrapped", DefaultCallerDepth + 1, func(logger log.Logger) string {
+			logErrorWrapped(logger, "key", "val")
+			return callerLine(-1)
+		}},
+	} {
+		var bufOut, bufErr bytes.Buffer
+
+		logger := CreateSyncLogger(loggerName, nil, &Config{Level: "debug", Format: "json", CallerDepth: c.depth}, &bufOut, &bufErr)
+
+		expected := c.log(logger)
+		record := make(map[string]string)
+
+		if err := json.Unmarshal(bufErr.Bytes(), &record); err != nil {
+			t.Fatalf("failed to parse %v log entry, %v", c.name, err.Error())
+		}
+
+		if caller := record["caller"]; caller != expected {
+			t.Errorf("expected %v caller '%v', but found '%v'", c.name, expected, caller)
+		}
+	}
+}
+
+func TestIncludeCaller(t *testing.T) {
+	include, exclude := true, false
+
+	for _, c := range []struct {
+		flag     *bool
+		expected bool
+	}{
+		{nil, true},
+		{&include, true},
+		{&exclude, false},
+	} {
+		logger, logs := CaptureLogger(WithConfig(&Config{IncludeCaller: c.flag}))
+
+		level.Error(logger).Log("msg", "error")
+		level.Info(logger).Log("msg", "info")
+
+		lines := logs.Lines()
+
+		if len(lines) != 2 {
+			t.Errorf("expected 2 entries, but found %v", lines)
+			continue
+		}
+
+		if _, found := lines[0]["caller"]; found != c.expected {
+			t.Errorf("expected error entry to have a caller to be %v, but found %v", c.expected, lines[0])
+		}
+
+		if _, found := lines[1]["caller"]; found {
+			t.Errorf("expected info entry to have no caller, but found %v", lines[1])
+		}
+	}
+}
+
+func TestIncludeTimestamp(t *testing.T) {
+	exclude := false
+
+	logger, logs := CaptureLogger(WithConfig(&Config{IncludeTimestamp: &exclude}))
+
+	level.Error(logger).Log("msg", "error")
+	level.Info(logger).Log("msg", "info")
+
+	lines := logs.Lines()
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries, but found %v", lines)
+	}
+
+	for _, l := range lines {
+		if _, found := l["ts"]; found {
+			t.Errorf("expected entry to have no timestamp, but found %v", l)
+		}
+	}
+
+	if _, found := lines[0]["caller"]; !found {
+		t.Errorf("expected error entry to have a caller, but found %v", lines[0])
+	}
+
+	// the timestamp key isn't reserved anymore, so it's kept as is.
+	level.Info(logger).Log("ts", "user")
+
+	if l := logs.Lines()[2]; l["ts"] != "user" {
+		t.Errorf("expected user timestamp key to be kept, but found %v", l)
+	}
+}
+
+func TestCallerOnAllLevels(t *testing.T) {
+	exclude := false
+
+	for _, c := range []struct {
+		config   *Config
+		expected bool
+	}{
+		{&Config{Level: "debug"}, false},
+		{&Config{Level: "debug", CallerOnAllLevels: true}, true},
+		{&Config{Level: "debug", CallerOnAllLevels: true, IncludeCaller: &exclude}, false},
+	} {
+		logger, logs := CaptureLogger(WithConfig(c.config))
+
+		level.Debug(logger).Log("msg", "debug")
+		expected := callerLine(-1)
+		logger.Debug("msg", "debug")
+		expectedMethod := callerLine(-1)
+
+		lines := logs.OutputLines()
+
+		if len(lines) != 2 {
+			t.Errorf("expected 2 entries, but found %v", lines)
+			continue
+		}
+
+		for i, e := range []string{expected, expectedMethod} {
+			caller, found := lines[i]["caller"]
+
+			if found != c.expected || (found && caller != e) {
+				t.Errorf("expected debug entry caller '%v' to be found %v, but found %v", e, c.expected, lines[i])
+			}
+		}
+	}
+}
+
+func TestChainedLevels(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := CreateSyncLogger(loggerName, nil, &Config{Level: "debug", Format: FormatLogfmt}, &bufOut, &bufErr)
+
+	level.Info(level.Debug(logger)).Log("key", "val")
+	level.Info(level.Error(logger)).Log("key", "val")
+
+	if bufErr.Len() > 0 {
+		t.Errorf("expected entries to be routed by the outermost level, but found '%v' in err", bufErr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(bufOut.String()), "\n")
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries, but found '%v'", bufOut.String())
+	}
+
+	for _, line := range lines {
+		if c := strings.Count(line, "level="); c != 1 || !strings.Contains(line, "level=info") {
+			t.Errorf("expected exactly one info level field, but found '%v'", line)
+		}
+
+		if c := strings.Count(line, "logger="); c != 1 {
+			t.Errorf("expected exactly one logger field, but found '%v'", line)
+		}
+
+		if strings.Index(line, "logger=") > strings.Index(line, "level=") {
+			t.Errorf("expected the logger field to precede the level field, but found '%v'", line)
+		}
+	}
+}
+
+func TestStderrLevels(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		levels   []string
+		out, err [][]int
+	}{
+		{"default", nil, [][]int{{1, 1}, {1, 2}, {1, 3}}, [][]int{{1, 0}}},
+		{"warnings", []string{"error", "warn"}, [][]int{{1, 2}, {1, 3}}, [][]int{{1, 0}, {1, 1}}},
+		{"stdout only", []string{}, [][]int{{1, 0}, {1, 1}, {1, 2}, {1, 3}}, nil},
+	} {
+		var bufOut, bufErr bytes.Buffer
+
+		logger := CreateSyncLogger(loggerName, nil, &Config{Level: "debug", Format: "json", StderrLevels: c.levels},
+			&bufOut, &bufErr)
+
+		for i, l := range []func(log.Logger) log.Logger{level.Error, level.Warn, level.Info, level.Debug} {
+			l(logger).Log(fmt.Sprintf("key_1%v", i), fmt.Sprintf("val_1%v", i))
+		}
+
+		if err := validateLogs(bufOut.String(), c.out); err != nil {
+			t.Errorf("failed to validate %v out writer, %v", c.name, err.Error())
+		}
+
+		if err := validateLogs(bufErr.String(), c.err); err != nil {
+			t.Errorf("failed to validate %v err writer, %v", c.name, err.Error())
+		}
+	}
+}
+
+func TestErrorLevel(t *testing.T) {
+	for _, c := range []struct {
+		name            string
+		level, errLevel string
+		stderrLevels    []string
+		out, err        [][]int
+	}{
+		{"unset", "info", "", []string{"error", "warn"}, [][]int{{1, 2}}, [][]int{{1, 0}, {1, 1}}},
+		{"errors only", "info", "error", []string{"error", "warn"}, [][]int{{1, 2}}, [][]int{{1, 0}}},
+		{"none", "info", "none", []string{"error", "warn"}, [][]int{{1, 2}}, nil},
+		{"looser", "warn", "debug", []string{"error", "warn", "debug"}, nil, [][]int{{1, 0}, {1, 1}, {1, 3}}},
+	} {
+		var bufOut, bufErr bytes.Buffer
+
+		logger := CreateSyncLogger(loggerName, nil, &Config{Level: c.level, ErrorLevel: c.errLevel, Format: "json",
+			StderrLevels: c.stderrLevels}, &bufOut, &bufErr)
+
+		for i, l := range []func(log.Logger) log.Logger{level.Error, level.Warn, level.Info, level.Debug} {
+			l(logger).Log(fmt.Sprintf("key_1%v", i), fmt.Sprintf("val_1%v", i))
+		}
+
+		if err := validateLogs(bufOut.String(), c.out); err != nil {
+			t.Errorf("failed to validate %v out writer, %v", c.name, err.Error())
+		}
+
+		if err := validateLogs(bufErr.String(), c.err); err != nil {
+			t.Errorf("failed to validate %v err writer, %v", c.name, err.Error())
+		}
+	}
+}
+
+func TestErrorsToStdoutToo(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := CreateSyncLogger(loggerName, nil, &Config{Level: "debug", Format: "json", ErrorsToStdoutToo: true},
+		&bufOut, &bufErr)
+
+	level.Info(logger).Log("key_10", "val_10")
+	level.Error(logger).Log("key_11", "val_11")
+	expected := callerLine(-1)
+
+	if err := validateLogs(bufOut.String(), [][]int{{1, 0}, {1, 1}}); err != nil {
+		t.Errorf("failed to validate out writer, %v", err.Error())
+	}
+
+	if err := validateLogs(bufErr.String(), [][]int{{1, 1}}); err != nil {
+		t.Errorf("failed to validate err writer, %v", err.Error())
+	}
+
+	// both copies of the error entry have the same caller.
+	for name, logs := range map[string]string{"stdout": strings.SplitAfter(bufOut.String(), "\n")[1], "stderr": bufErr.String()} {
+		record := make(map[string]interface{})
+
+		if err := json.Unmarshal([]byte(logs), &record); err != nil {
+			t.Fatalf("failed to parse %v entry '%v', %v", name, logs, err.Error())
+		}
+
+		if record["caller"] != expected {
+			t.Errorf("expected %v entry caller '%v', but found %v", name, expected, record["caller"])
+		}
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	outLogger, outLogs := CaptureLogger(WithConfig(&Config{Level: "trace"}))
+	errLogger, errLogs := CaptureLogger(WithConfig(&Config{Level: "trace"}))
+
+	counter := newFakeCounter()
+	logger := Instrument(loggerName, counter, outLogger, errLogger, WithConfig(&Config{Level: "info"}))
+
+	level.Info(logger).Log("msg", "info")
+	level.Warn(logger).Log("msg", "warn")
+	level.Error(logger).Log("msg", "error")
+	expectedCaller := callerLine(-1)
+	level.Debug(logger).Log("msg", "debug")
+
+	out, errs := outLogs.Lines(), errLogs.Lines()
+
+	if len(out) != 2 || out[0]["msg"] != "info" || out[1]["msg"] != "warn" || out[1]["level"] != "warn" {
+		t.Errorf("expected the info and warn entries to be appended to out, but found %v", out)
+	}
+
+	// the appenders are given the entries with the instrumented logger keys, which they rename as theirs.
+	if len(errs) != 1 || errs[0]["msg"] != "error" || errs[0]["user.logger"] != loggerName || errs[0]["user.caller"] != expectedCaller {
+		t.Errorf("expected the error entry to be appended to err, but found %v", errs)
+	}
+
+	for _, c := range []struct {
+		level    string
+		expected float64
+	}{
+		{"info", 1},
+		{"warn", 1},
+		{"error", 1},
+		{"debug", 0},
+	} {
+		if v := counter.value("level", c.level); v != c.expected {
+			t.Errorf("expected counter value %v for level '%v', but found %v", c.expected, c.level, v)
+		}
+	}
+
+	// errors go to out if there's no err logger.
+	outLogs.Reset()
+
+	level.Error(Instrument(loggerName, nil, outLogger, nil)).Log("msg", "error")
+
+	if lines := outLogs.Lines(); len(lines) != 1 || lines[0]["msg"] != "error" {
+		t.Errorf("expected the error entry to be appended to out, but found %v", lines)
+	}
+
+	if err := Instrument(loggerName, nil, nil, nil).Log("msg", "discarded"); err != nil {
+		t.Errorf("expected no error discarding the entries, but found %v", err.Error())
+	}
+}
+
+func TestStdLoggerClose(t *testing.T) {
+	logger := CreateStdSyncLogger(loggerName, nil, &Config{Level: "none"})
+
+	// there's nothing to release for std loggers, so closing them is a no-op.
+	for i := 0; i < 2; i++ {
+		if err := logger.Close(); err != nil {
+			t.Errorf("expected closing a std logger to succeed, but found %v", err.Error())
+		}
+	}
+
+	if err := logger.Flush(); err != nil {
+		t.Errorf("expected flushing a std logger to succeed, but found %v", err.Error())
+	}
+}
+
+func TestResetWriters(t *testing.T) {
+	dir := t.TempDir()
+
+	for i := 0; i < 2; i++ {
+		out, err := os.Create(filepath.Join(dir, fmt.Sprintf("stdout_%v.log", i)))
+
+		if err != nil {
+			t.Fatalf("failed to create stdout file, %v", err.Error())
+		}
+
+		defer out.Close()
+
+		stdout := os.Stdout
+		os.Stdout = out
+
+		// each logger must write to the stdout it's created with.
+		ResetWriters()
+		level.Info(CreateStdSyncLogger(loggerName, nil, &Config{Level: "info"})).Log(fmt.Sprintf("key_%v0", i), fmt.Sprintf("val_%v0", i))
+
+		os.Stdout = stdout
+		ResetWriters()
+
+		data, err := os.ReadFile(out.Name())
+
+		if err != nil {
+			t.Fatalf("failed to read stdout file, %v", err.Error())
+		}
+
+		if err := validateLogs(string(data), [][]int{{i, 0}}); err != nil {
+			t.Errorf("failed to validate stdout %v, %v", i, err.Error())
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	counter := newFakeCounter()
+	logger, logs := CaptureLogger(WithName(loggerName), WithCounter(counter), WithFields("service", "api"))
+
+	child := logger.With("request_id", "42", level.Key(), level.ErrorValue(), "dangling")
+	grandchild := child.With("user", "bob")
+
+	child.Info("msg", "child")
+	grandchild.Error("msg", "grandchild")
+	logger.Info("msg", "parent")
+	expected := callerLine(-2)
+
+	lines := logs.Lines()
+
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 log entries, but found %v", lines)
+	}
+
+	for i, c := range []map[string]interface{}{
+		{"msg": "child", "level": "info", "request_id": "42", "dangling": log.ErrMissingValue.Error()},
+		{"msg": "grandchild", "level": "error", "request_id": "42", "user": "bob", "caller": expected},
+		{"msg": "parent", "level": "info", "request_id": nil},
+	} {
+		c["logger"], c["service"] = loggerName, "api"
+
+		for k, v := range c {
+			if lines[i][k] != v {
+				t.Errorf("expected entry %v key-value (%v, %v), but found %v", i, k, v, lines[i])
+			}
+		}
+	}
+
+	if v := counter.value("level", "info"); v != 2 {
+		t.Errorf("expected counter value 2 for level 'info', but found %v", v)
+	}
+
+	// the children share the level of the parent.
+	logger.SetLevel("error")
+
+	if l := grandchild.Level(); l != "error" {
+		t.Errorf("expected the child level to be 'error', but found '%v'", l)
+	}
+}
+
+// this is an appender that discards everything, so only the allocations of the logger itself are measured.
+type nopAppender struct{}
+
+func (nopAppender) Log(...interface{}) error { return nil }
+
+// returns a logger, counted by a nop counter, whose appenders discard everything.
+func createNopAppendersLogger() *multiAppenderInstrumentedLogger {
+	l := CreateSyncLogger(loggerName, NopCounter(), &Config{Level: "info"}, io.Discard, io.Discard).(*multiAppenderInstrumentedLogger)
+
+	for r := range l.loggers {
+		l.loggers[r] = nopAppender{}
+	}
+
+	return l
+}
+
+func TestLogAllocs(t *testing.T) {
+	logger := createNopAppendersLogger()
+	keyvals := []interface{}{level.Key(), level.InfoValue(), "key", "val"}
+
+	// the only allocation is the entry prefixed by the logger name.
+	if n := testing.AllocsPerRun(100, func() { logger.Log(keyvals...) }); n > 1 {
+		t.Errorf("expected at most 1 allocation per log entry, but found %v", n)
+	}
+
+	// filtered entries aren't allocated at all.
+	keyvals[1] = level.DebugValue()
+
+	if n := testing.AllocsPerRun(100, func() { logger.Log(keyvals...) }); n > 0 {
+		t.Errorf("expected no allocations per filtered log entry, but found %v", n)
+	}
+}
+
+func BenchmarkLog(b *testing.B) {
+	for _, c := range []struct {
+		name   string
+		logger log.Logger
+	}{
+		{"nop", createNopAppendersLogger()},
+		{"json", CreateSyncLogger(loggerName, NopCounter(), &Config{Level: "info"}, io.Discard, io.Discard)},
+		{"logfmt", CreateSyncLogger(loggerName, NopCounter(), &Config{Level: "info", Format: FormatLogfmt}, io.Discard, io.Discard)},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			keyvals := []interface{}{level.Key(), level.InfoValue(), "key", "val"}
+
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				c.logger.Log(keyvals...)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"errors"
+	"io"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// this is a writer that observes the number of bytes of each write,
+// since loggers write each log entry at once it observes entry sizes.
+type sizeObservingWriter struct {
+	w         io.Writer
+	histogram metrics.Histogram
+}
+
+func (w *sizeObservingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.histogram.Observe(float64(n))
+	return n, err
+}
+
+// returns the children of the specified counter labeled by the specified label values and
+// each severity level name and the label of the entries having no level, so they're resolved
+// once instead of on every log entry, it returns nil if the counter is nil.
+func getLevelCounters(counter metrics.Counter, labelValues ...string) map[string]metrics.Counter {
+	if counter == nil {
+		return nil
+	}
+
+	if len(labelValues) > 0 {
+		counter = counter.With(labelValues...)
+	}
+
+	counters := map[string]metrics.Counter{noLevelLabel: counter.With("level", noLevelLabel)}
+
+	for r := rankTrace; r <= rankError; r++ {
+		counters[levelNames[r]] = counter.With("level", levelNames[r])
+	}
+
+	return counters
+}
+
+// returns the children of the specified histogram labeled by each severity level name and
+// the label of the entries having no level, it returns nil if the histogram is nil.
+func getLevelHistograms(histogram metrics.Histogram) map[string]metrics.Histogram {
+	if histogram == nil {
+		return nil
+	}
+
+	histograms := map[string]metrics.Histogram{noLevelLabel: histogram.With("level", noLevelLabel)}
+
+	for r := rankTrace; r <= rankError; r++ {
+		histograms[levelNames[r]] = histogram.With("level", levelNames[r])
+	}
+
+	return histograms
+}
+
+// returns a counter of each severity level name and the label of the entries having no level,
+// the map is never modified afterwards so it's safe to read it concurrently.
+func getLevelStats() map[string]*uint64 {
+	stats := map[string]*uint64{noLevelLabel: new(uint64)}
+
+	for r := rankTrace; r <= rankError; r++ {
+		stats[levelNames[r]] = new(uint64)
+	}
+
+	return stats
+}
+
+// this is a metrics counter that discards everything.
+type nopCounter struct{}
+
+func (c nopCounter) With(labelValues ...string) metrics.Counter { return c }
+
+func (c nopCounter) Add(delta float64) {}
+
+// NopCounter returns a metrics counter that discards all the values added to it,
+// it can be passed as the counter of loggers that don't need to be monitored.
+func NopCounter() metrics.Counter {
+	return nopCounter{}
+}
+
+// this is a metrics counter that adds the values to all of its counters.
+type multiCounter []metrics.Counter
+
+func (c multiCounter) With(labelValues ...string) metrics.Counter {
+	children := make(multiCounter, len(c))
+
+	for i, counter := range c {
+		children[i] = counter.With(labelValues...)
+	}
+
+	return children
+}
+
+func (c multiCounter) Add(delta float64) {
+	for _, counter := range c {
+		counter.Add(delta)
+	}
+}
+
+// MultiCounter returns a metrics counter that adds the values added to it to all the specified
+// counters with the same labels, e.g. to count the log entries by more than one metrics backend,
+// the nil counters are skipped. It returns nil if all of them are nil, and the only counter if
+// there's only one, so it can be passed as the counter of any logger.
+func MultiCounter(counters ...metrics.Counter) metrics.Counter {
+	c := make(multiCounter, 0, len(counters))
+
+	for _, counter := range counters {
+		if counter != nil {
+			c = append(c, counter)
+		}
+	}
+
+	switch len(c) {
+	case 0:
+		return nil
+	case 1:
+		return c[0]
+	default:
+		return c
+	}
+}
+
+// SafeCounter registers a Prometheus counter of the specified full name, help and label names with
+// the default registerer and returns it as a metrics counter ready to be passed to the loggers, the
+// label names must include "level", and if there are none then it's the only one. If a counter of
+// the same name and labels is already registered, e.g. by another logger or an earlier test, the
+// registered one is returned instead of failing, so it's safe to call more than once. It returns
+// an error if the counter can't be registered otherwise, e.g. if its name isn't valid.
+func SafeCounter(name, help string, labels []string) (metrics.Counter, error) {
+	if len(labels) == 0 {
+		labels = []string{"level"}
+	}
+
+	counter := stdprometheus.NewCounterVec(stdprometheus.CounterOpts{Name: name, Help: help}, labels)
+
+	if err := stdprometheus.Register(counter); err != nil {
+		var registered stdprometheus.AlreadyRegisteredError
+
+		if !errors.As(err, &registered) {
+			return nil, err
+		}
+
+		existing, ok := registered.ExistingCollector.(*stdprometheus.CounterVec)
+
+		if !ok {
+			return nil, err
+		}
+
+		counter = existing
+	}
+
+	return prometheus.NewCounter(counter), nil
+}
@@ -0,0 +1,315 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// this is an in-memory metrics histogram that keeps
+// the observed values of each set of label values.
+type fakeHistogram struct {
+	mtx    *sync.Mutex
+	values map[string][]float64
+	lvs    []string
+}
+
+func newFakeHistogram() *fakeHistogram {
+	return &fakeHistogram{mtx: &sync.Mutex{}, values: make(map[string][]float64)}
+}
+
+func (h *fakeHistogram) With(labelValues ...string) metrics.Histogram {
+	return &fakeHistogram{mtx: h.mtx, values: h.values, lvs: append(append([]string{}, h.lvs...), labelValues...)}
+}
+
+func (h *fakeHistogram) Observe(value float64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	key := strings.Join(h.lvs, ",")
+	h.values[key] = append(h.values[key], value)
+}
+
+// returns the observed values for the specified label values.
+func (h *fakeHistogram) observations(labelValues ...string) []float64 {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return append([]float64{}, h.values[strings.Join(labelValues, ",")]...)
+}
+
+func TestSizeHistogram(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	histogram := newFakeHistogram()
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Level: "debug", Format: FormatJSON}),
+		WithOutputWriter(&bufOut), WithErrorWriter(&bufErr), WithSizeHistogram(histogram))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			level.Info(logger).Log("key", strings.Repeat("i", i))
+			level.Error(logger).Log("key", strings.Repeat("e", i*10))
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, c := range []struct {
+		level string
+		logs  string
+	}{
+		{"info", bufOut.String()},
+		{"error", bufErr.String()},
+	} {
+		sizes := make(map[float64]int)
+
+		for _, line := range strings.SplitAfter(c.logs, "\n") {
+			if line != "" {
+				sizes[float64(len(line))]++
+			}
+		}
+
+		observations := histogram.observations("level", c.level)
+
+		if len(observations) != 10 {
+			t.Errorf("expected 10 %v observations, but found %v", c.level, len(observations))
+		}
+
+		for _, o := range observations {
+			if sizes[o]--; sizes[o] < 0 {
+				t.Errorf("expected %v observations to match the entries sizes, but found unexpected size %v", c.level, o)
+			}
+		}
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	const delay = 20 * time.Millisecond
+
+	histogram := newFakeHistogram()
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Level: "info"}), WithLatencyHistogram(histogram),
+		WithOutputWriter(&bytes.Buffer{}), WithErrorWriter(&slowWriter{delay: delay}))
+
+	level.Info(logger).Log("key", "val")
+	level.Error(logger).Log("key", "val")
+	level.Debug(logger).Log("key", "filtered")
+
+	if observations := histogram.observations("level", "info"); len(observations) != 1 || observations[0] >= delay.Seconds() {
+		t.Errorf("expected 1 info observation less than %v seconds, but found %v", delay.Seconds(), observations)
+	}
+
+	if observations := histogram.observations("level", "error"); len(observations) != 1 || observations[0] < delay.Seconds() {
+		t.Errorf("expected 1 error observation of at least %v seconds, but found %v", delay.Seconds(), observations)
+	}
+
+	if observations := histogram.observations("level", "debug"); len(observations) != 0 {
+		t.Errorf("expected no debug observations, but found %v", observations)
+	}
+}
+
+func TestNopCounter(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := CreateSyncLogger(loggerName, NopCounter(), &Config{Level: "trace"}, &bufOut, &bufErr)
+
+	Trace(logger).Log("msg", "trace")
+	level.Debug(logger).Log("msg", "debug")
+	level.Info(logger).Log("msg", "info")
+	level.Warn(logger).Log("msg", "warn")
+	level.Error(logger).Log("msg", "error")
+	logger.Log("msg", "default")
+
+	if lines := strings.Count(bufOut.String(), "\n"); lines != 5 {
+		t.Errorf("expected 5 entries written to out, but found %v", bufOut.String())
+	}
+
+	if lines := strings.Count(bufErr.String(), "\n"); lines != 1 || !strings.Contains(bufErr.String(), `"msg":"error"`) {
+		t.Errorf("expected only the error entry written to err, but found %v", bufErr.String())
+	}
+}
+
+func TestCounterLabels(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	counter := stdprometheus.NewCounterVec(stdprometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tenant_entries_total",
+		Help:      "Number of log entries for each tenant and severity level.",
+	}, []string{"level", "tenant"})
+
+	for _, tenant := range []string{"acme", "initech"} {
+		logger := NewLogger(WithName(loggerName), WithCounter(prometheus.NewCounter(counter)),
+			WithCounterLabels("tenant", tenant), WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))
+
+		level.Info(logger).Log("key", "val")
+		level.Error(logger).Log("key", "val")
+	}
+
+	level.Info(NewLogger(WithName(loggerName), WithCounter(prometheus.NewCounter(counter)),
+		WithCounterLabels("tenant", "acme"), WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))).Log("key", "val")
+
+	for _, c := range []struct {
+		level, tenant string
+		expected      float64
+	}{
+		{"info", "acme", 2},
+		{"error", "acme", 1},
+		{"info", "initech", 1},
+		{"error", "initech", 1},
+	} {
+		var m dto.Metric
+
+		if err := counter.WithLabelValues(c.level, c.tenant).Write(&m); err != nil {
+			t.Fatalf("failed to read counter, %v", err.Error())
+		}
+
+		if v := m.GetCounter().GetValue(); v != c.expected {
+			t.Errorf("expected counter value %v for level '%v' and tenant '%v', but found %v", c.expected, c.level, c.tenant, v)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	logger := CreateSyncLogger(loggerName, nil, &Config{Level: "debug", Format: "json"}, io.Discard, io.Discard)
+
+	for i := 0; i < 3; i++ {
+		logger.Info("msg", "info")
+	}
+
+	for i := 0; i < 2; i++ {
+		level.Error(logger).Log("msg", "error")
+	}
+
+	logger.Warn("msg", "warn")
+	logger.Log("msg", "no level")
+
+	// the filtered entries aren't emitted, so they're not counted.
+	logger.Trace("msg", "trace")
+
+	// the child loggers share the counts.
+	logger.With("key", "val").Debug("msg", "debug")
+
+	expected := map[string]uint64{"trace": 0, "debug": 1, "info": 3, "warn": 1, "error": 2, noLevelLabel: 1}
+
+	if stats := logger.Stats(); !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected stats %v, but found %v", expected, stats)
+	}
+}
+
+func TestMultipleCounters(t *testing.T) {
+	first, second := newFakeCounter(), newFakeCounter()
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Level: "debug"}), WithOutputWriter(io.Discard),
+		WithErrorWriter(io.Discard), WithCounter(first), WithCounters(nil, second), WithCounterLabels("tenant", "acme"))
+
+	logger.Info("msg", "info")
+	logger.Info("msg", "info")
+	logger.Error("msg", "error")
+	logger.Log("msg", "no level")
+
+	if !reflect.DeepEqual(first.values, second.values) || len(first.values) != 3 {
+		t.Errorf("expected both counters to have identical values, but found %v and %v", first.values, second.values)
+	}
+
+	if v := second.value("tenant", "acme", "level", "info"); v != 2 {
+		t.Errorf("expected the info count to be 2, but found %v", v)
+	}
+
+	if c := MultiCounter(nil, nil); c != nil {
+		t.Errorf("expected no counter for nil counters, but found %v", c)
+	}
+
+	if c := MultiCounter(nil, first); c != first {
+		t.Errorf("expected the only counter, but found %v", c)
+	}
+}
+
+// returns the value of the counter of the specified name and level registered with the default registerer.
+func gatherCounter(t *testing.T, name, lvl string) float64 {
+	families, err := stdprometheus.DefaultGatherer.Gather()
+
+	if err != nil {
+		t.Fatalf("failed to gather metrics, %v", err.Error())
+	}
+
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+
+		for _, m := range f.GetMetric() {
+			if m.GetLabel()[0].GetValue() == lvl {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return 0
+}
+
+func TestSafeCounter(t *testing.T) {
+	const name = namespace + "_safe_entries_total"
+
+	var counters []metrics.Counter
+
+	for i := 0; i < 2; i++ {
+		counter, err := SafeCounter(name, "Number of log entries for each severity level.", nil)
+
+		if err != nil {
+			t.Fatalf("failed to register counter %v time(s), %v", i+1, err.Error())
+		}
+
+		counters = append(counters, counter)
+	}
+
+	// both counters must be backed by the registered collector, which may have been used before.
+	before := gatherCounter(t, name, "info")
+
+	for _, counter := range counters {
+		CreateSyncLogger(loggerName, counter, Configuration(), io.Discard, io.Discard).Log("level", level.InfoValue())
+	}
+
+	if v := gatherCounter(t, name, "info") - before; v != 2 {
+		t.Errorf("expected the registered counter to be increased by 2 for level 'info', but found %v", v)
+	}
+
+	// the registration fails if something else is registered under the same name.
+	if _, err := SafeCounter(name, "Number of log entries.", []string{"level", "tenant"}); err == nil {
+		t.Errorf("expected an error registering a different counter of the same name, but found none")
+	}
+
+	if _, err := SafeCounter("invalid name", "", nil); err == nil {
+		t.Errorf("expected an error registering a counter of an invalid name, but found none")
+	}
+}
@@ -0,0 +1,394 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/go-kit/kit/log"
+)
+
+// ErrInvalidMsgpack is the error wrapped by all the errors returned for malformed 'msgpack' log entries.
+var ErrInvalidMsgpack = errors.New("invalid msgpack log entry")
+
+// these are the limits of the 'msgpack' log entries accepted by the decoder, so a malformed
+// entry can't make it allocate too much memory or recurse indefinitely.
+const (
+	maxMsgpackLength = 4 << 20
+	maxMsgpackDepth  = 32
+)
+
+// this is a logger that serializes each log entry into a MessagePack map, the entries
+// are written one after the other since every MessagePack value has a known size.
+type msgpackLogger struct {
+	w io.Writer
+}
+
+// returns a factory that creates msgpack loggers.
+func createMsgpackLoggerFactory() func(io.Writer) log.Logger {
+	return func(w io.Writer) log.Logger {
+		return &msgpackLogger{w: w}
+	}
+}
+
+func (l *msgpackLogger) Log(keyvals ...interface{}) error {
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, log.ErrMissingValue)
+	}
+
+	b := appendMsgpackHeader(make([]byte, 0, 256), len(keyvals)/2, 0x80, 15, 0, 0xde, 0xdf)
+
+	for i := 0; i < len(keyvals); i += 2 {
+		b = appendMsgpackString(b, fmt.Sprint(keyvals[i]))
+		b = appendMsgpackValue(b, keyvals[i+1])
+	}
+
+	// the entry is written at once, so concurrent entries aren't interleaved.
+	_, err := l.w.Write(b)
+	return err
+}
+
+// appends the specified value the way the go-kit JSON logger marshals it, i.e. the errors and the
+// fmt.Stringer values are written as strings, and the values that aren't primitive, nor a slice
+// or a map of them, are written in their fmt.Sprint form.
+func appendMsgpackValue(b []byte, v interface{}) []byte {
+	switch x := v.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if x {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case int:
+		return appendMsgpackInt(b, int64(x))
+	case int8:
+		return appendMsgpackInt(b, int64(x))
+	case int16:
+		return appendMsgpackInt(b, int64(x))
+	case int32:
+		return appendMsgpackInt(b, int64(x))
+	case int64:
+		return appendMsgpackInt(b, x)
+	case uint:
+		return appendMsgpackUint(b, uint64(x))
+	case uint8:
+		return appendMsgpackUint(b, uint64(x))
+	case uint16:
+		return appendMsgpackUint(b, uint64(x))
+	case uint32:
+		return appendMsgpackUint(b, uint64(x))
+	case uint64:
+		return appendMsgpackUint(b, x)
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(x))
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(x))
+	case string:
+		return appendMsgpackString(b, x)
+	case []byte:
+		return append(appendMsgpackHeader(b, len(x), 0, 0, 0xc4, 0xc5, 0xc6), x...)
+	case error:
+		// the nil pointer errors are written as nil and the stringers as "NULL" like go-kit does.
+		if isNilPointer(x) {
+			return append(b, 0xc0)
+		}
+		return appendMsgpackString(b, x.Error())
+	case fmt.Stringer:
+		if isNilPointer(x) {
+			return appendMsgpackString(b, "NULL")
+		}
+		return appendMsgpackString(b, x.String())
+	case []interface{}:
+		b = appendMsgpackHeader(b, len(x), 0x90, 15, 0, 0xdc, 0xdd)
+
+		for _, e := range x {
+			b = appendMsgpackValue(b, e)
+		}
+		return b
+	case map[string]interface{}:
+		b = appendMsgpackHeader(b, len(x), 0x80, 15, 0, 0xde, 0xdf)
+
+		for k, e := range x {
+			b = appendMsgpackValue(appendMsgpackString(b, k), e)
+		}
+		return b
+	default:
+		return appendMsgpackString(b, fmt.Sprint(x))
+	}
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMsgpackUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+func appendMsgpackUint(b []byte, v uint64) []byte {
+	switch {
+	case v < 0x80:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+	}
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	return append(appendMsgpackHeader(b, len(s), 0xa0, 31, 0xd9, 0xda, 0xdb), s...)
+}
+
+// appends the header of a string, binary, array or map value of the specified length, using the
+// specified fixed format if the length fits in its low bits, i.e. it's not greater than the specified
+// maximum, or else the smallest of the 8, 16 and 32 bits formats that fits it, a zero format means
+// there's none, e.g. the binary values have no fixed format and the arrays have no 8 bits one.
+func appendMsgpackHeader(b []byte, n int, fixed byte, fixedMax int, f8, f16, f32 byte) []byte {
+	switch {
+	case fixed != 0 && n <= fixedMax:
+		return append(b, fixed|byte(n))
+	case f8 != 0 && n <= math.MaxUint8:
+		return append(b, f8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, f16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, f32), uint32(n))
+	}
+}
+
+// MsgpackDecoder reads the log entries written in the 'msgpack' format.
+type MsgpackDecoder struct {
+	r *bufio.Reader
+}
+
+// NewMsgpackDecoder returns a decoder reading the 'msgpack' log entries from the specified reader.
+func NewMsgpackDecoder(r io.Reader) *MsgpackDecoder {
+	return &MsgpackDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads and decodes the next log entry into a map, it returns io.EOF if there are no more
+// entries, or io.ErrUnexpectedEOF if the reader ends in the middle of an entry. The integers are
+// decoded as int64 unless they're too large which are decoded as uint64, the floats as float64,
+// the binary values as []byte, the arrays as []interface{} and the maps as map[string]interface{}.
+func (d *MsgpackDecoder) Decode() (map[string]interface{}, error) {
+	if _, err := d.r.Peek(1); err != nil {
+		return nil, err
+	}
+
+	v, err := d.decode(0)
+
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	entry, ok := v.(map[string]interface{})
+
+	if !ok {
+		return nil, fmt.Errorf("%w, expected a map but found %T", ErrInvalidMsgpack, v)
+	}
+
+	return entry, nil
+}
+
+func (d *MsgpackDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxMsgpackDepth {
+		return nil, fmt.Errorf("%w, nested too deep", ErrInvalidMsgpack)
+	}
+
+	t, err := d.r.ReadByte()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case t < 0x80:
+		return int64(t), nil
+	case t >= 0xe0:
+		return int64(int8(t)), nil
+	case t&0xe0 == 0xa0:
+		return d.readString(int(t & 0x1f))
+	case t&0xf0 == 0x90:
+		return d.readArray(int(t&0x0f), depth)
+	case t&0xf0 == 0x80:
+		return d.readMap(int(t&0x0f), depth)
+	}
+
+	switch t {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.readUint(1 << (t - 0xcc))
+
+		if err != nil || u > math.MaxInt64 {
+			return u, err
+		}
+		return int64(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (t - 0xd0)
+		u, err := d.readUint(size)
+
+		// sign extend the value from its size.
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, err
+	case 0xca:
+		u, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.readUint(8)
+		return math.Float64frombits(u), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readUint(1 << (t - 0xd9))
+
+		if err != nil {
+			return nil, err
+		}
+		return d.readString(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readUint(1 << (t - 0xc4))
+
+		if err != nil {
+			return nil, err
+		}
+		return d.readBytes(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.readUint(2 << (t - 0xdc))
+
+		if err != nil {
+			return nil, err
+		}
+		return d.readArray(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := d.readUint(2 << (t - 0xde))
+
+		if err != nil {
+			return nil, err
+		}
+		return d.readMap(int(n), depth)
+	default:
+		return nil, fmt.Errorf("%w, unsupported type 0x%x", ErrInvalidMsgpack, t)
+	}
+}
+
+// reads a big endian unsigned integer of the specified number of bytes.
+func (d *MsgpackDecoder) readUint(size int) (uint64, error) {
+	var buf [8]byte
+
+	if _, err := io.ReadFull(d.r, buf[8-size:]); err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func (d *MsgpackDecoder) readBytes(n int) ([]byte, error) {
+	if n > maxMsgpackLength {
+		return nil, fmt.Errorf("%w, length %v exceeds the maximum length", ErrInvalidMsgpack, n)
+	}
+
+	b := make([]byte, n)
+
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (d *MsgpackDecoder) readString(n int) (string, error) {
+	b, err := d.readBytes(n)
+	return string(b), err
+}
+
+func (d *MsgpackDecoder) readArray(n, depth int) ([]interface{}, error) {
+	if n > maxMsgpackLength {
+		return nil, fmt.Errorf("%w, length %v exceeds the maximum length", ErrInvalidMsgpack, n)
+	}
+
+	// the elements are appended as they're read, so a malformed length allocates nothing upfront.
+	var a []interface{}
+
+	for i := 0; i < n; i++ {
+		v, err := d.decode(depth + 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		a = append(a, v)
+	}
+
+	return a, nil
+}
+
+func (d *MsgpackDecoder) readMap(n, depth int) (map[string]interface{}, error) {
+	if n > maxMsgpackLength {
+		return nil, fmt.Errorf("%w, length %v exceeds the maximum length", ErrInvalidMsgpack, n)
+	}
+
+	m := make(map[string]interface{})
+
+	for i := 0; i < n; i++ {
+		k, err := d.decode(depth + 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := d.decode(depth + 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// the keys are written as strings, yet any other key is accepted by its string form.
+		if s, ok := k.(string); ok {
+			m[s] = v
+		} else {
+			m[fmt.Sprint(k)] = v
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	now := time.Date(2018, 6, 1, 12, 30, 0, 123456789, time.UTC)
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Level: "debug", Format: FormatMsgpack, IncludeCaller: new(bool)}),
+		WithOutputWriter(&bufOut), WithErrorWriter(&bufErr), WithTimestampFunc(func() time.Time { return now }))
+
+	level.Info(logger).Log("msg", "started", "port", 8080, "ratio", 0.25, "ok", true, "none", nil,
+		"nil_err", (*stackError)(nil), "nil_url", (*url.URL)(nil))
+	level.Debug(logger).Log("neg", -1, "neg8", -100, "neg16", -1000, "neg32", -100000, "neg64", int64(math.MinInt64),
+		"u8", uint8(200), "u16", uint16(60000), "u32", uint32(4000000000), "u64", uint64(math.MaxUint64), "f32", float32(1.5))
+	level.Warn(logger).Log("err", errors.New("timeout"), "elapsed", 1500*time.Millisecond, "data", []byte{1, 2, 3},
+		"list", []interface{}{"a", 1}, "nested", map[string]interface{}{"key": "val"})
+	level.Error(logger).Log("short", strings.Repeat("a", 31), "str8", strings.Repeat("b", 32), "str16", strings.Repeat("c", 256),
+		"str32", strings.Repeat("d", 65536), "array16", make([]interface{}, 16))
+
+	ts := now.Format(time.RFC3339Nano)
+
+	for _, c := range []struct {
+		name     string
+		buf      *bytes.Buffer
+		expected []map[string]interface{}
+	}{
+		{"out", &bufOut, []map[string]interface{}{
+			{"level": "info", "ts": ts, "logger": loggerName, "msg": "started", "port": int64(8080), "ratio": 0.25, "ok": true, "none": nil,
+				"nil_err": nil, "nil_url": "NULL"},
+			{"level": "debug", "ts": ts, "logger": loggerName, "neg": int64(-1), "neg8": int64(-100), "neg16": int64(-1000),
+				"neg32": int64(-100000), "neg64": int64(math.MinInt64), "u8": int64(200), "u16": int64(60000),
+				"u32": int64(4000000000), "u64": uint64(math.MaxUint64), "f32": 1.5},
+			{"level": "warn", "ts": ts, "logger": loggerName, "err": "timeout", "elapsed": "1.5s", "data": []byte{1, 2, 3},
+				"list": []interface{}{"a", int64(1)}, "nested": map[string]interface{}{"key": "val"}},
+		}},
+		{"err", &bufErr, []map[string]interface{}{
+			{"level": "error", "ts": ts, "logger": loggerName, "short": strings.Repeat("a", 31), "str8": strings.Repeat("b", 32),
+				"str16": strings.Repeat("c", 256), "str32": strings.Repeat("d", 65536), "array16": make([]interface{}, 16)},
+		}},
+	} {
+		decoder := NewMsgpackDecoder(c.buf)
+
+		for i, e := range c.expected {
+			entry, err := decoder.Decode()
+
+			if err != nil {
+				t.Fatalf("failed to decode %v entry %v, %v", c.name, i, err.Error())
+			}
+
+			if !reflect.DeepEqual(entry, e) {
+				t.Errorf("expected %v entry %v to be %v, but found %v", c.name, i, e, entry)
+			}
+		}
+
+		if _, err := decoder.Decode(); err != io.EOF {
+			t.Errorf("expected '%v' after the last %v entry, but found %v", io.EOF, c.name, err)
+		}
+	}
+}
+
+func TestMsgpackUnixTimestamp(t *testing.T) {
+	var buf bytes.Buffer
+
+	now := time.Date(2018, 6, 1, 12, 30, 0, 0, time.UTC)
+
+	logger := NewLogger(WithConfig(&Config{Level: "info", Format: FormatMsgpack, TimestampFormat: "unixnano"}),
+		WithOutputWriter(&buf), WithTimestampFunc(func() time.Time { return now }))
+
+	logger.Info("key", "val")
+
+	entry, err := NewMsgpackDecoder(&buf).Decode()
+
+	if err != nil {
+		t.Fatalf("failed to decode entry, %v", err.Error())
+	}
+
+	if v := entry["ts"]; v != now.UnixNano() {
+		t.Errorf("expected timestamp %v, but found %v", now.UnixNano(), v)
+	}
+}
+
+func TestMsgpackDecodeInvalid(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := createMsgpackLoggerFactory()(&buf).Log("key", "val"); err != nil {
+		t.Fatalf("failed to log entry, %v", err.Error())
+	}
+
+	data := buf.Bytes()
+
+	if _, err := NewMsgpackDecoder(bytes.NewReader(data[:len(data)-1])).Decode(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected '%v' for a truncated entry, but found %v", io.ErrUnexpectedEOF, err)
+	}
+
+	for _, data := range [][]byte{{0xa1, 'a'}, {0x81, 0xa1, 'a', 0xc1}, {0xdb, 0xff, 0xff, 0xff, 0xff},
+		append(bytes.Repeat([]byte{0x91}, maxMsgpackDepth+1), 0xc0)} {
+		if _, err := NewMsgpackDecoder(bytes.NewReader(data)).Decode(); !errors.Is(err, ErrInvalidMsgpack) {
+			t.Errorf("expected entry %v to be invalid, but found %v", data, err)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+const (
+	// these are the bounds of the delay between reconnection attempts.
+	minReconnectBackoff = 100 * time.Millisecond
+	maxReconnectBackoff = 30 * time.Second
+	// this is the timeout of each connection attempt.
+	dialTimeout = 5 * time.Second
+)
+
+// this is a log entry waiting to be written, along
+// with its level label to count it if it's dropped.
+type pendingEntry struct {
+	data  []byte
+	label string
+}
+
+// this is a writer that writes to a network connection, while disconnected the
+// entries are buffered up to a bounded size dropping the oldest ones when it's full,
+// and a background goroutine reconnects with an exponential backoff.
+// If required, the entries written to each connection are compressed using gzip.
+type networkWriter struct {
+	network, addr string
+	capacity      int
+	dropCounter   metrics.Counter
+	compression   *compression
+	mtx           sync.Mutex
+	conn          net.Conn
+	gz            *gzipWriter
+	pending       []pendingEntry
+	closed        bool
+	wake          chan struct{}
+	done          chan struct{}
+}
+
+// returns a new network writer connected to the specified address, it returns an error
+// if the first connection attempt fails. It starts the background goroutine which keeps
+// running until the returned writer is closed.
+// The entries are compressed if the specified compression isn't nil.
+func newNetworkWriter(network, addr string, capacity int, dropCounter metrics.Counter, c *compression) (*networkWriter, error) {
+	conn, err := net.DialTimeout(network, addr, dialTimeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if capacity <= 0 {
+		capacity = DefaultBufferSize
+	}
+
+	w := &networkWriter{network: network, addr: addr, capacity: capacity, dropCounter: dropCounter,
+		compression: c, wake: make(chan struct{}, 1), done: make(chan struct{})}
+
+	// the compression level is checked with the first connection.
+	if err := w.connect(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+// writes the specified entry of the specified level label, it never fails
+// unless the writer is closed since the entry is buffered if it can't be written.
+func (w *networkWriter) write(p []byte, label string) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	// entries are written in order, so the pending ones must be written first.
+	if w.conn != nil && len(w.pending) == 0 {
+		if _, err := w.sink().Write(p); err == nil {
+			return len(p), nil
+		}
+
+		w.disconnect()
+	}
+
+	// the caller may reuse the slice once we return, so we keep a copy.
+	w.pending = append(w.pending, pendingEntry{data: append([]byte(nil), p...), label: label})
+
+	if len(w.pending) > w.capacity {
+		if w.dropCounter != nil {
+			w.dropCounter.With("level", w.pending[0].label).Add(1)
+		}
+		w.pending = w.pending[1:]
+	}
+
+	// let the background goroutine know there's something to write.
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+
+	return len(p), nil
+}
+
+// sets the current connection, starting a new gzip stream if the entries are compressed,
+// it must be called while holding the lock.
+func (w *networkWriter) connect(conn net.Conn) error {
+	if w.compression != nil {
+		gz, err := newGzipWriter(conn, w.compression)
+
+		if err != nil {
+			return err
+		}
+
+		w.gz = gz
+	}
+
+	w.conn = conn
+	return nil
+}
+
+// returns the writer of the current connection, it must be called while holding the lock.
+func (w *networkWriter) sink() io.Writer {
+	if w.gz != nil {
+		return w.gz
+	}
+	return w.conn
+}
+
+// closes the current connection, it must be called while holding the lock.
+// The compressed entries that weren't flushed yet are lost.
+func (w *networkWriter) disconnect() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	// the connection is closed first, so finalizing the stream fails right away.
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz = nil
+	}
+}
+
+// writes the pending entries while connected and returns whether they were all written,
+// it must be called while holding the lock.
+func (w *networkWriter) drain() bool {
+	for w.conn != nil && len(w.pending) > 0 {
+		if _, err := w.sink().Write(w.pending[0].data); err != nil {
+			w.disconnect()
+			return false
+		}
+		w.pending = w.pending[1:]
+	}
+
+	return len(w.pending) == 0
+}
+
+// reconnects and writes the pending entries whenever there are any, until the writer is closed.
+func (w *networkWriter) run() {
+	backoff := minReconnectBackoff
+
+	for {
+		select {
+		case <-w.wake:
+		case <-w.done:
+			return
+		}
+
+		for {
+			w.mtx.Lock()
+			connected := w.conn != nil
+			w.mtx.Unlock()
+
+			if !connected {
+				// the connection is established without holding the lock so logging never waits for it.
+				conn, err := net.DialTimeout(w.network, w.addr, dialTimeout)
+
+				if err != nil {
+					select {
+					case <-time.After(backoff):
+					case <-w.done:
+						return
+					}
+
+					if backoff *= 2; backoff > maxReconnectBackoff {
+						backoff = maxReconnectBackoff
+					}
+					continue
+				}
+
+				w.mtx.Lock()
+
+				// the writer may have been closed while connecting.
+				if w.closed {
+					w.mtx.Unlock()
+					conn.Close()
+					return
+				}
+
+				// the compression level was checked with the first connection, so it can't fail.
+				w.connect(conn)
+				w.mtx.Unlock()
+
+				backoff = minReconnectBackoff
+			}
+
+			w.mtx.Lock()
+			drained := w.closed || w.drain()
+			w.mtx.Unlock()
+
+			if drained {
+				break
+			}
+		}
+	}
+}
+
+// Flush writes the pending entries if connected, along with the compressed ones.
+func (w *networkWriter) Flush() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.drain() && w.gz != nil {
+		if err := w.gz.Flush(); err != nil {
+			w.disconnect()
+		}
+	}
+
+	return nil
+}
+
+// Close writes the pending entries if connected, closes the connection and stops the
+// background goroutine, the entries that couldn't be written are lost.
+func (w *networkWriter) Close() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	close(w.done)
+
+	w.drain()
+
+	// the gzip stream is finalized before the connection is closed.
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz = nil
+	}
+
+	w.disconnect()
+
+	return nil
+}
+
+// this is implemented by writers that need the level label of each entry they write.
+type labeledWriter interface {
+	write(p []byte, label string) (int, error)
+}
+
+// this is a writer of the entries of a specific severity level to a labeled writer.
+type levelLabelWriter struct {
+	w     labeledWriter
+	label string
+}
+
+func (w *levelLabelWriter) Write(p []byte) (int, error) {
+	return w.w.write(p, w.label)
+}
+
+// CreateNetworkLogger returns an instance of instrumented logger that writes newline-delimited
+// log entries to the specified address on the specified network, e.g. "tcp" or "udp".
+// If the connection is lost, it reconnects in the background with an exponential backoff while
+// the entries are buffered up to the configured buffer size, dropping the oldest ones when the
+// buffer is full, which are counted by the drop counter if one is set by the specified options.
+// If the logger is compressed by WithCompression, every connection is a gzip stream of its own,
+// and the compressed entries that weren't flushed when the connection is lost are lost too.
+// It returns an error if the first connection attempt fails.
+// The logger should be closed when it's no longer needed to close the connection.
+// If configuration level is set to 'none' then neither
+// logs nor monitoring will take place.
+func CreateNetworkLogger(loggerName string, counter metrics.Counter, config *Config, network, addr string, opts ...Option) (Logger, error) {
+
+	opts = append([]Option{WithName(loggerName), WithCounter(counter), WithConfig(config)}, opts...)
+
+	// the writer needs the resolved configuration and drop counter.
+	o := resolveOptions(opts)
+
+	w, err := newNetworkWriter(network, addr, o.config.BufferSize, o.dropCounter, o.compression)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for r := rankTrace; r <= rankError; r++ {
+		o.apply(withLevelWriter(r, &levelLabelWriter{w: w, label: levelNames[r]}))
+	}
+
+	return newLogger(o.apply(withCloser(w))), nil
+}
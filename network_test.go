@@ -0,0 +1,301 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// accepts connections on the specified listener and sends the received lines to the returned channel,
+// the returned function closes the listener along with the accepted connections.
+func acceptLines(listener net.Listener) (<-chan map[string]interface{}, func()) {
+	lines := make(chan map[string]interface{}, 1024)
+
+	var mtx sync.Mutex
+	var conns []net.Conn
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			mtx.Lock()
+			conns = append(conns, conn)
+			mtx.Unlock()
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					record := make(map[string]interface{})
+					if json.Unmarshal(scanner.Bytes(), &record) == nil {
+						lines <- record
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return lines, func() {
+		listener.Close()
+
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+}
+
+// returns the next received line or fails if none is received in time.
+func nextLine(t *testing.T, lines <-chan map[string]interface{}) map[string]interface{} {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a log entry to be received, but found none")
+		return nil
+	}
+}
+
+func TestNetworkLogger(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("failed to listen, %v", err.Error())
+	}
+
+	lines, closeListener := acceptLines(listener)
+	defer closeListener()
+
+	counter := newFakeCounter()
+
+	logger, err := CreateNetworkLogger(loggerName, counter, &Config{Level: "debug"}, "tcp", listener.Addr().String())
+
+	if err != nil {
+		t.Fatalf("failed to create network logger, %v", err.Error())
+	}
+
+	level.Info(logger).Log("msg", "info")
+	level.Error(logger).Log("msg", "error")
+	level.Debug(logger).Log("msg", "debug")
+
+	for _, expected := range []string{"info", "error", "debug"} {
+		if line := nextLine(t, lines); line["msg"] != expected || line["level"] != expected || line["logger"] != loggerName {
+			t.Errorf("expected %v entry, but found %v", expected, line)
+		}
+
+		if c := counter.value("level", expected); c != 1 {
+			t.Errorf("expected %v counter to be 1, but found %v", expected, c)
+		}
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Errorf("failed to close network logger, %v", err.Error())
+	}
+
+	if err := level.Info(logger).Log("msg", "closed"); err != ErrClosed {
+		t.Errorf("expected error '%v' after closing, but found '%v'", ErrClosed, err)
+	}
+}
+
+func TestNetworkLoggerReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("failed to listen, %v", err.Error())
+	}
+
+	addr := listener.Addr().String()
+	lines, closeListener := acceptLines(listener)
+
+	logger, err := CreateNetworkLogger(loggerName, nil, nil, "tcp", addr)
+
+	if err != nil {
+		t.Fatalf("failed to create network logger, %v", err.Error())
+	}
+
+	defer logger.Close()
+
+	level.Info(logger).Log("msg", "before restart")
+
+	if line := nextLine(t, lines); line["msg"] != "before restart" {
+		t.Errorf("expected entry before restart, but found %v", line)
+	}
+
+	// restart the listener, the logger reconnects once it finds out the connection is lost.
+	closeListener()
+
+	if listener, err = net.Listen("tcp", addr); err != nil {
+		t.Fatalf("failed to restart listener, %v", err.Error())
+	}
+
+	lines, closeListener = acceptLines(listener)
+	defer closeListener()
+
+	deadline := time.Now().Add(10 * time.Second)
+
+	for i := 0; time.Now().Before(deadline); i++ {
+		level.Info(logger).Log("msg", "after restart", "i", i)
+
+		select {
+		case line := <-lines:
+			if line["msg"] != "after restart" {
+				t.Errorf("expected entry after restart, but found %v", line)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	t.Errorf("expected entries to be received after restart, but found none")
+}
+
+func TestNetworkWriterDropOldest(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("failed to listen, %v", err.Error())
+	}
+
+	dropCounter := newFakeCounter()
+
+	w, err := newNetworkWriter("tcp", listener.Addr().String(), 3, dropCounter, nil)
+
+	if err != nil {
+		t.Fatalf("failed to create network writer, %v", err.Error())
+	}
+
+	defer w.Close()
+
+	// lose the connection and make sure reconnecting fails.
+	listener.Close()
+
+	w.mtx.Lock()
+	w.disconnect()
+	w.mtx.Unlock()
+
+	for i := 0; i < 5; i++ {
+		if _, err := w.write([]byte(fmt.Sprintf("entry %v\n", i)), "warn"); err != nil {
+			t.Errorf("expected writing while disconnected to succeed, but found %v", err.Error())
+		}
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if len(w.pending) != 3 || string(w.pending[0].data) != "entry 2\n" || string(w.pending[2].data) != "entry 4\n" {
+		t.Errorf("expected the 3 newest entries to be pending, but found %v", w.pending)
+	}
+
+	if c := dropCounter.value("level", "warn"); c != 2 {
+		t.Errorf("expected 2 dropped warn entries, but found %v", c)
+	}
+}
+
+func TestNetworkLoggerCompression(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("failed to listen, %v", err.Error())
+	}
+
+	defer listener.Close()
+
+	lines := make(chan map[string]interface{}, 16)
+	done := make(chan error, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+
+		if err != nil {
+			done <- err
+			return
+		}
+
+		defer conn.Close()
+
+		gz, err := gzip.NewReader(conn)
+
+		if err != nil {
+			done <- err
+			return
+		}
+
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			record := make(map[string]interface{})
+			if json.Unmarshal(scanner.Bytes(), &record) == nil {
+				lines <- record
+			}
+		}
+
+		// the stream must be finalized once the logger is closed.
+		if err := scanner.Err(); err != nil {
+			done <- err
+			return
+		}
+
+		done <- gz.Close()
+	}()
+
+	logger, err := CreateNetworkLogger(loggerName, nil, &Config{Level: "debug"}, "tcp", listener.Addr().String(),
+		WithCompression(gzip.BestSpeed, 10*time.Millisecond))
+
+	if err != nil {
+		t.Fatalf("failed to create network logger, %v", err.Error())
+	}
+
+	// the entries are flushed periodically, so they're received before closing.
+	level.Info(logger).Log("msg", "info")
+
+	if line := nextLine(t, lines); line["msg"] != "info" {
+		t.Errorf("expected info entry, but found %v", line)
+	}
+
+	level.Error(logger).Log("msg", "error")
+
+	if err := logger.Close(); err != nil {
+		t.Errorf("failed to close network logger, %v", err.Error())
+	}
+
+	if line := nextLine(t, lines); line["msg"] != "error" {
+		t.Errorf("expected error entry, but found %v", line)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("failed to decompress the received entries, %v", err.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected the gzip stream to be finalized, but found it's not")
+	}
+}
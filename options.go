@@ -0,0 +1,581 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+)
+
+// this carries everything needed to construct a logger,
+// it's populated by the options passed to NewLogger.
+type options struct {
+	name             string
+	counter          metrics.Counter
+	dropCounter      metrics.Counter
+	levelGauge       metrics.Gauge
+	sizeHistogram    metrics.Histogram
+	config           *Config
+	out              io.Writer
+	err              io.Writer
+	levelWriters     map[int]io.Writer
+	samplers         []sampler
+	transforms       []transform
+	middlewares      []Middleware
+	closers          []io.Closer
+	errorHandler     func(error)
+	exitCode         int
+	exit             func(int)
+	fields           []interface{}
+	dedupWindow      time.Duration
+	now              func() time.Time
+	userPrefix       string
+	counterLabels    []string
+	writeTimeout     time.Duration
+	latencyHistogram metrics.Histogram
+	compression      *compression
+	outLogger        log.Logger
+	errLogger        log.Logger
+	sortKeys         bool
+	schema           *Schema
+	schemaHandler    func(error)
+}
+
+const (
+	// HostKey is the key of the host name added by WithHostInfo.
+	HostKey = "host"
+	// PIDKey is the key of the process ID added by WithHostInfo.
+	PIDKey = "pid"
+	// SeverityKey is the default key of the numeric severity added by WithNumericLevel.
+	SeverityKey = "severity"
+	// SequenceKey is the default key of the sequence number added by WithSequence.
+	SequenceKey = "seq"
+)
+
+// this resolves the host name, it's replaced by tests.
+var hostname = os.Hostname
+
+// Option configures the logger created by NewLogger.
+type Option func(*options)
+
+// WithName sets the logger name that is added to every log entry.
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithCounter sets the metrics counter used to count emitted log entries per severity level.
+func WithCounter(counter metrics.Counter) Option {
+	return func(o *options) {
+		o.counter = counter
+	}
+}
+
+// WithCounters adds the specified metrics counters to the one set by WithCounter, so the emitted
+// log entries are counted by all of them with the same labels, the nil counters are skipped.
+func WithCounters(counters ...metrics.Counter) Option {
+	return func(o *options) {
+		o.counter = MultiCounter(append([]metrics.Counter{o.counter}, counters...)...)
+	}
+}
+
+// WithCounterLabels adds the specified constant label name-value pairs, e.g. the tenant, to
+// every increment of the counter set by WithCounter alongside the "level" label, so the
+// counter must declare these label names as well.
+func WithCounterLabels(labelValues ...string) Option {
+	return func(o *options) {
+		o.counterLabels = append(o.counterLabels, labelValues...)
+	}
+}
+
+// WithDropCounter sets the metrics counter used to count log entries per severity level
+// that were dropped for being below the configured level, together with the counter
+// set by WithCounter they add up to the total number of leveled log entries.
+func WithDropCounter(counter metrics.Counter) Option {
+	return func(o *options) {
+		o.dropCounter = counter
+	}
+}
+
+// WithLevelGauge sets the metrics gauge that is set to the numeric value of the logging
+// severity level allowed whenever it's set, where 'none' is 0, 'error' is 1, 'warn' is 2,
+// 'info' is 3, 'debug' is 4 and 'trace' is 5.
+func WithLevelGauge(gauge metrics.Gauge) Option {
+	return func(o *options) {
+		o.levelGauge = gauge
+	}
+}
+
+// WithSizeHistogram sets the metrics histogram that observes the size in bytes
+// of each log entry written per severity level, entries with no level are
+// observed as info entries.
+func WithSizeHistogram(histogram metrics.Histogram) Option {
+	return func(o *options) {
+		o.sizeHistogram = histogram
+	}
+}
+
+// WithLatencyHistogram sets the metrics histogram used to observe the seconds taken to log
+// each written log entry per severity level, including writing it unless the logger is async.
+func WithLatencyHistogram(histogram metrics.Histogram) Option {
+	return func(o *options) {
+		o.latencyHistogram = histogram
+	}
+}
+
+// WithConfig sets the logging configuration, if nil the default configuration is used.
+func WithConfig(config *Config) Option {
+	return func(o *options) {
+		if config != nil {
+			o.config = config
+		}
+	}
+}
+
+// WithOutputWriter sets the writer that non-error logs are written to, it defaults to os.Stdout.
+func WithOutputWriter(w io.Writer) Option {
+	return func(o *options) {
+		if w != nil {
+			o.out = w
+		}
+	}
+}
+
+// WithErrorWriter sets the writer that error logs are written to, it defaults to os.Stderr.
+func WithErrorWriter(w io.Writer) Option {
+	return func(o *options) {
+		if w != nil {
+			o.err = w
+		}
+	}
+}
+
+// WithFields adds the specified key-value pairs to every log entry, e.g. the service name
+// and version, they're added by the appenders so they never affect the entry level
+// and a level key among them is ignored.
+func WithFields(keyvals ...interface{}) Option {
+	return func(o *options) {
+		if len(keyvals)%2 != 0 {
+			keyvals = append(keyvals, log.ErrMissingValue)
+		}
+
+		for i := 0; i < len(keyvals); i += 2 {
+			if keyvals[i] != level.Key() {
+				o.fields = append(o.fields, keyvals[i], keyvals[i+1])
+			}
+		}
+	}
+}
+
+// WithHostInfo adds the host name and the process ID to every log entry under the 'host' and
+// 'pid' keys, the host name is resolved once when the logger is created and if it fails to be
+// resolved then 'unknown' is used instead.
+func WithHostInfo() Option {
+	return func(o *options) {
+		host, err := hostname()
+
+		if err != nil || host == "" {
+			host = "unknown"
+		}
+
+		o.fields = append(o.fields, HostKey, host, PIDKey, os.Getpid())
+	}
+}
+
+// WithDynamicField adds the specified key to every log entry with the value returned by the
+// specified valuer, e.g. the number of goroutines, the valuer is called by the appenders for
+// each entry written so it's never called for the filtered or sampled out entries.
+func WithDynamicField(key string, valuer log.Valuer) Option {
+	return func(o *options) {
+		if valuer != nil && key != level.Key() {
+			o.fields = append(o.fields, key, valuer)
+		}
+	}
+}
+
+// WithSequence adds the sequence number of every log entry written by the logger under the
+// specified key, or 'seq' if it's empty, starting at one, e.g. to detect dropped or reordered
+// entries downstream. Each logger has a sequence of its own shared by its child loggers, and
+// since the number is resolved by the appenders, the filtered and sampled out entries don't
+// take numbers. The entries are never deduplicated by WithDedup since their numbers differ.
+func WithSequence(key string) Option {
+	if key == "" {
+		key = SequenceKey
+	}
+
+	return func(o *options) {
+		var seq uint64
+
+		o.fields = append(o.fields, key, log.Valuer(func() interface{} {
+			return atomic.AddUint64(&seq, 1)
+		}))
+	}
+}
+
+// WithDedup collapses identical consecutive log entries written within the specified
+// time window into a single entry with the number of repetitions under the 'repeated' key,
+// the timestamps of the entries are ignored and the first one is kept.
+// The entries are held until the window closes or a different entry is written,
+// or the logger is flushed or closed. If zero or less then entries are never collapsed.
+func WithDedup(window time.Duration) Option {
+	return func(o *options) {
+		o.dedupWindow = window
+	}
+}
+
+// WithTimestampFunc sets the clock used to resolve the timestamp of every log entry,
+// e.g. to pin the time in tests, it defaults to time.Now and the time is always
+// converted to UTC. If nil the default clock is used.
+func WithTimestampFunc(now func() time.Time) Option {
+	return func(o *options) {
+		if now != nil {
+			o.now = now
+		}
+	}
+}
+
+// WithWriteTimeout limits the time writing a log entry can take to the specified duration,
+// e.g. for slow file or network writers, once it elapses the entry is dropped, counted by the
+// drop counter and ErrWriteTimeout is returned, so a slow writer never blocks the caller for
+// longer. Async loggers apply the timeout on their background goroutines, where the dropped
+// entries are still counted by the drop counter and ErrWriteTimeout is passed to the error
+// handler instead since the caller has already returned.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = timeout
+	}
+}
+
+// WithCompression compresses the log entries written by the file and network loggers using gzip
+// of the specified level, from gzip.BestSpeed to gzip.BestCompression, or gzip.HuffmanOnly, and
+// if zero then gzip.DefaultCompression is used. The compressed entries are flushed at the specified
+// interval, or DefaultCompressionFlushInterval if it's zero, and if negative they're only flushed
+// once the compressor fills its buffer or the logger is flushed. Every log file is a gzip stream
+// finalized when it's rotated or closed, and every connection is a gzip stream of its own.
+// It's ignored by the other loggers.
+func WithCompression(level int, flushInterval time.Duration) Option {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	if flushInterval == 0 {
+		flushInterval = DefaultCompressionFlushInterval
+	}
+
+	return func(o *options) {
+		o.compression = &compression{level: level, flushInterval: flushInterval}
+	}
+}
+
+// WithSampler limits the number of log entries of each severity level to the specified number
+// of entries per second, the excess entries are dropped and counted by the drop counter.
+func WithSampler(eventsPerSecond float64) Option {
+	return func(o *options) {
+		o.samplers = append(o.samplers, newRateSampler(eventsPerSecond))
+	}
+}
+
+// WithPredicate drops the log entries the specified function returns false for, e.g. to only
+// log the requests that failed, the dropped entries are counted by the drop counter.
+// The function is given the keyvals of each entry as they're logged and it must not modify them.
+func WithPredicate(predicate func(keyvals []interface{}) bool) Option {
+	return func(o *options) {
+		if predicate != nil {
+			o.samplers = append(o.samplers, func(_ int, keyvals []interface{}) bool { return predicate(keyvals) })
+		}
+	}
+}
+
+// WithUserKeyPrefix sets the prefix the log entry keys colliding with the keys added by
+// the logger are renamed with, i.e. the logger name, timestamp and caller keys, so the
+// values added by the logger always win and the user values are still kept. The keys
+// only collide on the entries the logger adds them to, e.g. the caller of error entries.
+// If empty, the colliding keys are dropped instead and a warning is logged the first time.
+func WithUserKeyPrefix(prefix string) Option {
+	return func(o *options) {
+		o.userPrefix = prefix
+	}
+}
+
+// WithKeySampler keeps only the specified fraction, between 0 and 1, of the log entries having
+// the specified key, the entries are sampled based on a hash of the key value so the entries
+// having the same value are consistently kept or dropped. Error entries and the entries not
+// having the key are always kept, the dropped entries are counted by the drop counter.
+func WithKeySampler(key string, rate float64) Option {
+	return func(o *options) {
+		o.samplers = append(o.samplers, newKeySampler(key, rate))
+	}
+}
+
+// WithFirstNThenSample keeps the first n log entries having the specified key for each of its
+// values, e.g. an event name, and then keeps the specified fraction of the rest of them evenly,
+// e.g. one of every ten entries if it's 0.1, so the start of a storm is fully logged. The values
+// are compared by their string form, and if more than 10000 values are counted the counts are
+// reset. Errors and the entries not having the key are always kept, and if the fraction is one
+// or more, all the entries are kept.
+func WithFirstNThenSample(key string, n int, rate float64) Option {
+	return func(o *options) {
+		if rate < 1 {
+			o.samplers = append(o.samplers, newFirstNSampler(key, n, rate))
+		}
+	}
+}
+
+// WithRedaction replaces the values of the specified keys with "[REDACTED]",
+// keys are matched case-insensitively and the level key is never redacted.
+func WithRedaction(keys ...string) Option {
+	return WithRedactionFunc(func(key string) bool {
+		for _, k := range keys {
+			if strings.EqualFold(k, key) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithRedactionFunc replaces the values of the keys matched by the specified
+// function with "[REDACTED]", the level key is never redacted.
+func WithRedactionFunc(match func(key string) bool) Option {
+	return func(o *options) {
+		o.transforms = append(o.transforms, newRedactionTransform(match))
+	}
+}
+
+// WithAllowedKeys removes all the keys of the log entries but the specified ones, unlike
+// redaction the unknown keys never make it to the writers. Keys are matched exactly and
+// the level key and the ones added by the logger itself, i.e. the logger name, timestamp,
+// caller and static fields, are always kept. If used more than once, only the keys allowed
+// by all of them are kept.
+func WithAllowedKeys(keys ...string) Option {
+	return func(o *options) {
+		o.transforms = append(o.transforms, newAllowListTransform(keys))
+	}
+}
+
+// WithNumericKeys parses the string values of the specified keys into numbers, e.g. "200"
+// becomes 200, so they're always written as JSON numbers, the values that aren't valid numbers
+// are left as they are. Keys are matched exactly and the level key is never parsed.
+func WithNumericKeys(keys ...string) Option {
+	return func(o *options) {
+		o.transforms = append(o.transforms, newNumericTransform(keys))
+	}
+}
+
+// WithNumericLevel adds the syslog numeric severity of the log entries level, as returned by
+// LevelSeverity, under the specified key, or 'severity' if it's empty, the entries having no
+// level get the severity of info. If the key is the level key, the level value is replaced
+// by its numeric severity instead.
+func WithNumericLevel(key string) Option {
+	if key == "" {
+		key = SeverityKey
+	}
+
+	return func(o *options) {
+		o.transforms = append(o.transforms, newNumericLevelTransform(key))
+	}
+}
+
+// WithDurationFormat formats the time.Duration values of the log entries using the specified
+// format, it can be 'string', 'millis', 'seconds' or 'nanos', any other value will fall back
+// to 'string' which is how they're formatted by default, e.g. "1.5s".
+func WithDurationFormat(format string) Option {
+	return func(o *options) {
+		if t := newDurationTransform(format); t != nil {
+			o.transforms = append(o.transforms, t)
+		}
+	}
+}
+
+// WithMarshaler replaces every value of the log entries that isn't nil, a string, a bool or
+// a number by the one returned by the specified marshaler before it's written, e.g. to convert
+// structs to maps that are marshaled as expected. If the marshaler returns an error, the value
+// is replaced by its fmt.Sprint form instead, and the level value is never replaced.
+func WithMarshaler(marshal func(interface{}) (interface{}, error)) Option {
+	return func(o *options) {
+		if marshal != nil {
+			o.transforms = append(o.transforms, newMarshalerTransform(marshal))
+		}
+	}
+}
+
+// WithFlatten replaces the map values of the log entries, i.e. map[string]interface{} and
+// map[string]string, by their entries keyed by the map key and the entry key joined by the
+// specified separator, or "." if it's empty, e.g. "user.id", and the nested maps are flattened
+// the same way. The flattened keys never replace the other keys of the entry and if they
+// collide with each other only the first one is kept, so no key is written twice.
+func WithFlatten(separator string) Option {
+	if separator == "" {
+		separator = "."
+	}
+
+	return func(o *options) {
+		o.transforms = append(o.transforms, newFlattenTransform(separator))
+	}
+}
+
+// WithSortedKeys sorts the keys of the log entries alphabetically before they're written,
+// so the output is stable whatever the order the keys are logged in, e.g. for golden files.
+// The timestamp and the level keys come first, and the duplicate keys keep their order.
+// It's meant for the formats that keep the order of the keys, e.g. 'logfmt' and 'console',
+// since the JSON formats sort them anyway, and it allocates for every entry.
+func WithSortedKeys() Option {
+	return func(o *options) {
+		o.sortKeys = true
+	}
+}
+
+// WithTestMode validates every written log entry against the specified schema, including the keys
+// added by the logger like the timestamp, and calls the specified handler with each violation, e.g.
+// to fail the tests on contract regressions, the entries are still written anyway. The handler must
+// be safe for concurrent use and must not log to the same logger, if nil the error handler is used.
+// It's meant for tests since it's expensive, and if the schema or both handlers are nil it has no effect.
+func WithTestMode(schema *Schema, handler func(error)) Option {
+	return func(o *options) {
+		o.schema, o.schemaHandler = schema, handler
+	}
+}
+
+// WithMaxFields keeps only the first n keys of the log entries, dropping the rest and adding the
+// 'fields_truncated' key with true instead, e.g. to protect the ingestion from buggy callers. The
+// fields of the child loggers come first, while the level key, and the keys added by the logger
+// like the timestamp and the name, don't count and are never dropped. If zero or less then the
+// keys are never dropped.
+func WithMaxFields(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.transforms = append(o.transforms, newMaxFieldsTransform(n))
+		}
+	}
+}
+
+// WithMiddleware appends the specified middlewares to the chain the log entries go through
+// before they're routed by their level, they run in order, each getting the keyvals returned
+// by the previous one, and the nil ones are ignored.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(o *options) {
+		for _, m := range middlewares {
+			if m != nil {
+				o.middlewares = append(o.middlewares, m)
+			}
+		}
+	}
+}
+
+// WithMaxValueLength truncates the string and fmt.Stringer values longer than the specified
+// number of bytes, appending their original length to them, the level value is never truncated.
+// If zero or less then values are never truncated.
+func WithMaxValueLength(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.transforms = append(o.transforms, newTruncationTransform(n))
+		}
+	}
+}
+
+// WithLevelWriters sets dedicated writers for the log entries of the specified severity levels,
+// e.g. to write debug logs to a file, they take precedence over both the output and error
+// writers which are still used by the rest of the levels. The levels are matched like the
+// configured level, and the invalid ones are ignored along with 'none' and the nil writers.
+// The entries are formatted using the output or the error format based on whether their
+// level is written to stderr, and a writer shared by more than one level is synchronized
+// once for all of them.
+func WithLevelWriters(writers map[string]io.Writer) Option {
+	return func(o *options) {
+		for l, w := range writers {
+			if r, ok := lookupLevel(l); ok && r != rankNone && w != nil {
+				withLevelWriter(r, w)(o)
+			}
+		}
+	}
+}
+
+// sets a dedicated writer for the log entries of the specified severity level rank,
+// it takes precedence over both the output and error writers.
+func withLevelWriter(r int, w io.Writer) Option {
+	return func(o *options) {
+		if o.levelWriters == nil {
+			o.levelWriters = make(map[int]io.Writer)
+		}
+		o.levelWriters[r] = w
+	}
+}
+
+// WithErrorHandler sets a function that is called with the error of every failed
+// log entry write, including the ones written in the background by async loggers.
+// It must be safe for concurrent use and must not log to the same logger.
+func WithErrorHandler(handler func(error)) Option {
+	return func(o *options) {
+		o.errorHandler = handler
+	}
+}
+
+// WithExitCode sets the code that the process exits with when
+// the logger's Fatal method is called, it defaults to 1.
+func WithExitCode(code int) Option {
+	return func(o *options) {
+		o.exitCode = code
+	}
+}
+
+// sets the function called to exit the process when the logger's Fatal method is called.
+func withExitFunc(exit func(int)) Option {
+	return func(o *options) {
+		o.exit = exit
+	}
+}
+
+// adds a resource owned by the logger that is closed when the logger is closed.
+func withCloser(c io.Closer) Option {
+	return func(o *options) {
+		o.closers = append(o.closers, c)
+	}
+}
+
+// returns the options resolved from the defaults and the specified options.
+func resolveOptions(opts []Option) *options {
+	o := &options{
+		config:     Configuration(),
+		out:        os.Stdout,
+		err:        os.Stderr,
+		exitCode:   1,
+		exit:       os.Exit,
+		now:        time.Now,
+		userPrefix: DefaultUserKeyPrefix,
+	}
+
+	return o.apply(opts...)
+}
+
+// applies the specified options to the already resolved options, e.g. for the sinks which
+// need the resolved options to create their writers, so no option is ever applied twice.
+func (o *options) apply(opts ...Option) *options {
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
@@ -0,0 +1,675 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestNewLoggerDefaults(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := NewLogger(WithName(loggerName), WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))
+
+	level.Debug(logger).Log("key_10", "val_10")
+	level.Info(logger).Log("key_11", "val_11")
+	level.Error(logger).Log("key_12", "val_12")
+
+	if err := validateLogs(bufOut.String(), [][]int{{1, 1}}); err != nil {
+		t.Errorf("failed to validate out writer, %v", err.Error())
+	}
+
+	if err := validateLogs(bufErr.String(), [][]int{{1, 2}}); err != nil {
+		t.Errorf("failed to validate err writer, %v", err.Error())
+	}
+}
+
+func TestNewLoggerOptions(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := NewLogger(
+		WithName(loggerName),
+		WithConfig(&Config{Level: "debug", Format: FormatJSON}),
+		WithOutputWriter(&bufOut),
+		WithErrorWriter(&bufErr),
+	)
+
+	level.Debug(logger).Log("key_20", "val_20")
+	level.Warn(logger).Log("key_21", "val_21")
+	level.Error(logger).Log("key_22", "val_22")
+
+	if err := validateLogs(bufOut.String(), [][]int{{2, 0}, {2, 1}}); err != nil {
+		t.Errorf("failed to validate out writer, %v", err.Error())
+	}
+
+	if err := validateLogs(bufErr.String(), [][]int{{2, 2}}); err != nil {
+		t.Errorf("failed to validate err writer, %v", err.Error())
+	}
+}
+
+func TestNewLoggerNone(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := NewLogger(WithConfig(&Config{Level: "none"}), WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))
+
+	level.Info(logger).Log("key", "val")
+	level.Error(logger).Log("key", "val")
+
+	if bufOut.Len() != 0 || bufErr.Len() != 0 {
+		t.Errorf("expected no logs, but found ('%v', '%v')", bufOut.String(), bufErr.String())
+	}
+}
+
+func TestDropCounter(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	counter, dropCounter := newFakeCounter(), newFakeCounter()
+
+	logger := NewLogger(
+		WithName(loggerName),
+		WithCounter(counter),
+		WithDropCounter(dropCounter),
+		WithConfig(&Config{Level: "warn", Format: FormatJSON}),
+		WithOutputWriter(&bufOut),
+		WithErrorWriter(&bufErr),
+	)
+
+	for i := 0; i < 3; i++ {
+		level.Debug(logger).Log("key", "val")
+		level.Info(logger).Log("key", "val")
+		level.Warn(logger).Log("key", "val")
+		level.Error(logger).Log("key", "val")
+	}
+
+	for _, c := range []struct {
+		level            string
+		emitted, dropped float64
+	}{
+		{"debug", 0, 3},
+		{"info", 0, 3},
+		{"warn", 3, 0},
+		{"error", 3, 0},
+	} {
+		if v := counter.value("level", c.level); v != c.emitted {
+			t.Errorf("expected counter value %v for level '%v', but found %v", c.emitted, c.level, v)
+		}
+
+		if v := dropCounter.value("level", c.level); v != c.dropped {
+			t.Errorf("expected drop counter value %v for level '%v', but found %v", c.dropped, c.level, v)
+		}
+	}
+}
+
+func TestTimestampKeyAndFormat(t *testing.T) {
+	type parser func(v interface{}) (time.Time, error)
+
+	parseLayout := func(layout string) parser {
+		return func(v interface{}) (time.Time, error) {
+			s, ok := v.(string)
+			if !ok {
+				return time.Time{}, fmt.Errorf("unexpected timestamp type %T", v)
+			}
+			return time.Parse(layout, s)
+		}
+	}
+
+	parseUnix := func(unit time.Duration) parser {
+		return func(v interface{}) (time.Time, error) {
+			n, ok := v.(json.Number)
+			if !ok {
+				return time.Time{}, fmt.Errorf("unexpected timestamp type %T", v)
+			}
+			i, err := n.Int64()
+			return time.Unix(0, i*int64(unit)), err
+		}
+	}
+
+	for _, c := range []struct {
+		key, format string
+		expectedKey string
+		parse       parser
+		precision   time.Duration
+	}{
+		{"", "", "ts", parseLayout(time.RFC3339Nano), time.Nanosecond},
+		{"@timestamp", "rfc3339", "@timestamp", parseLayout(time.RFC3339), time.Second},
+		{"time", "unixmilli", "time", parseUnix(time.Millisecond), time.Millisecond},
+		{" time ", "UnixNano", "time", parseUnix(time.Nanosecond), time.Nanosecond},
+	} {
+		for _, lvl := range []level.Value{level.InfoValue(), level.ErrorValue()} {
+			var bufOut, bufErr bytes.Buffer
+
+			logger := NewLogger(WithConfig(&Config{TimestampKey: c.key, TimestampFormat: c.format}),
+				WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))
+
+			before := time.Now().Truncate(c.precision)
+			logger.Log(level.Key(), lvl)
+			after := time.Now()
+
+			buf := &bufOut
+
+			if lvl == level.ErrorValue() {
+				buf = &bufErr
+			}
+
+			record := make(map[string]interface{})
+			decoder := json.NewDecoder(buf)
+			decoder.UseNumber()
+
+			if err := decoder.Decode(&record); err != nil {
+				t.Errorf("failed to parse log entry, %v", err.Error())
+				continue
+			}
+
+			ts, ok := record[c.expectedKey]
+
+			if !ok {
+				t.Errorf("expected timestamp key '%v', but found %v", c.expectedKey, record)
+				continue
+			}
+
+			parsed, err := c.parse(ts)
+
+			if err != nil {
+				t.Errorf("failed to parse timestamp '%v' of format '%v', %v", ts, c.format, err.Error())
+				continue
+			}
+
+			if parsed.Before(before) || parsed.After(after) {
+				t.Errorf("expected timestamp between %v and %v, but found %v", before, after, parsed)
+			}
+		}
+	}
+}
+
+func TestNameKey(t *testing.T) {
+	for _, c := range []struct {
+		key, expected string
+	}{
+		{"", "logger"},
+		{" ", "logger"},
+		{"logger_name", "logger_name"},
+	} {
+		logger, logs := CaptureLogger(WithName(loggerName), WithConfig(&Config{NameKey: c.key}))
+
+		level.Info(logger).Log("msg", "info")
+		level.Error(logger).Log("msg", "error")
+
+		lines := logs.Lines()
+
+		if len(lines) != 2 {
+			t.Errorf("expected 2 log entries, but found %v", lines)
+			continue
+		}
+
+		for _, line := range lines {
+			if line[c.expected] != loggerName {
+				t.Errorf("expected logger name under key '%v', but found %v", c.expected, line)
+			}
+
+			if c.expected != "logger" && line["logger"] != nil {
+				t.Errorf("expected no 'logger' key, but found %v", line)
+			}
+		}
+	}
+}
+
+// this is a writer that always fails.
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestErrorHandler(t *testing.T) {
+	errWrite := errors.New("broken pipe")
+
+	for _, async := range []bool{false, true} {
+		var bufOut bytes.Buffer
+		var mtx sync.Mutex
+		var handled []error
+
+		logger := NewLogger(WithConfig(&Config{Async: async}), WithOutputWriter(&bufOut),
+			WithErrorWriter(failingWriter{errWrite}), WithErrorHandler(func(err error) {
+				mtx.Lock()
+				defer mtx.Unlock()
+				handled = append(handled, err)
+			}))
+
+		level.Info(logger).Log("msg", "info")
+		err := level.Error(logger).Log("msg", "error")
+
+		if !async && !errors.Is(err, errWrite) {
+			t.Errorf("expected the write error to be returned, but found %v", err)
+		}
+
+		if err := logger.Close(); err != nil {
+			t.Errorf("failed to close logger, %v", err.Error())
+		}
+
+		mtx.Lock()
+
+		if len(handled) != 1 || !errors.Is(handled[0], errWrite) {
+			t.Errorf("expected the handler to be called once with the write error when async is %v, but found %v", async, handled)
+		}
+
+		mtx.Unlock()
+
+		if !strings.Contains(bufOut.String(), `"msg":"info"`) {
+			t.Errorf("expected the info entry to be written, but found '%v'", bufOut.String())
+		}
+	}
+}
+
+func TestFatal(t *testing.T) {
+	for _, c := range []struct {
+		opts     []Option
+		expected int
+	}{
+		{nil, 1},
+		{[]Option{WithExitCode(3)}, 3},
+	} {
+		var bufOut, bufErr bytes.Buffer
+
+		code, written := -1, ""
+
+		opts := append([]Option{WithConfig(&Config{Async: true}), WithOutputWriter(&bufOut), WithErrorWriter(&bufErr),
+			withExitFunc(func(c int) {
+				code, written = c, bufErr.String()
+			})}, c.opts...)
+
+		logger := NewLogger(opts...)
+
+		logger.Fatal("msg", "fatal")
+
+		if code != c.expected {
+			t.Errorf("expected exit code %v, but found %v", c.expected, code)
+		}
+
+		if !strings.Contains(written, `"msg":"fatal"`) || !strings.Contains(written, `"level":"error"`) {
+			t.Errorf("expected the fatal entry to be written to err before exiting, but found '%v'", written)
+		}
+
+		logger.Close()
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	logger, logs := CaptureLogger(WithName(loggerName), WithFields("service", "api", "version", "1.2.3", level.Key(), "debug"),
+		WithFields("env"))
+
+	level.Info(logger).Log("msg", "info")
+	level.Error(logger).Log("msg", "error")
+	level.Debug(logger).Log("msg", "filtered")
+
+	for name, lines := range map[string][]map[string]interface{}{"out": logs.OutputLines(), "err": logs.ErrorLines()} {
+		if len(lines) != 1 {
+			t.Errorf("expected 1 %v entry, but found %v", name, lines)
+			continue
+		}
+
+		for k, v := range map[string]interface{}{"service": "api", "version": "1.2.3", "env": log.ErrMissingValue.Error()} {
+			if lines[0][k] != v {
+				t.Errorf("expected %v entry key-value (%v, %v), but found %v", name, k, v, lines[0])
+			}
+		}
+	}
+
+	if lines := logs.Lines(); len(lines) == 2 && (lines[0]["level"] != "info" || lines[1]["level"] != "error") {
+		t.Errorf("expected the static fields to leave the levels intact, but found %v", lines)
+	}
+}
+
+func TestTimestampFunc(t *testing.T) {
+	fixed := time.Date(2018, 10, 1, 12, 30, 45, 123456789, time.FixedZone("EET", 2*60*60))
+
+	for _, c := range []struct {
+		format, expected string
+	}{
+		{"", "2018-10-01T10:30:45.123456789Z"},
+		{TimestampRFC3339, "2018-10-01T10:30:45Z"},
+		{TimestampUnixMilli, "1538389845123"},
+		{TimestampUnixNano, "1538389845123456789"},
+	} {
+		var bufOut, bufErr bytes.Buffer
+
+		logger := NewLogger(WithConfig(&Config{TimestampFormat: c.format}), WithOutputWriter(&bufOut),
+			WithErrorWriter(&bufErr), WithTimestampFunc(func() time.Time { return fixed }), WithTimestampFunc(nil))
+
+		level.Info(logger).Log("msg", "info")
+		level.Error(logger).Log("msg", "error")
+
+		for name, buf := range map[string]*bytes.Buffer{"out": &bufOut, "err": &bufErr} {
+			record := make(map[string]interface{})
+			decoder := json.NewDecoder(buf)
+			decoder.UseNumber()
+
+			if err := decoder.Decode(&record); err != nil {
+				t.Errorf("failed to parse %v log entry, %v", name, err.Error())
+				continue
+			}
+
+			if ts := fmt.Sprint(record["ts"]); ts != c.expected {
+				t.Errorf("expected %v timestamp '%v' of format '%v', but found '%v'", name, c.expected, c.format, ts)
+			}
+		}
+	}
+}
+
+func TestDynamicField(t *testing.T) {
+	calls := 0
+
+	logger, logs := CaptureLogger(WithName(loggerName), WithDynamicField("seq", func() interface{} {
+		calls++
+		return calls
+	}))
+
+	for i := 0; i < 3; i++ {
+		level.Info(logger).Log("msg", "info")
+		level.Debug(logger).Log("msg", "filtered")
+	}
+
+	level.Error(logger).Log("msg", "error")
+
+	lines := logs.Lines()
+
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 log entries, but found %v", lines)
+	}
+
+	for i, line := range lines {
+		if line["seq"] != float64(i+1) {
+			t.Errorf("expected entry %v to have the dynamic value %v, but found %v", i, i+1, line)
+		}
+	}
+
+	if calls != 4 {
+		t.Errorf("expected the valuer to be called for the written entries only, but it was called %v times", calls)
+	}
+}
+
+func TestUserKeyPrefix(t *testing.T) {
+	logger, logs := CaptureLogger(WithName(loggerName))
+
+	level.Info(logger).Log("logger", "user", "ts", "now", "caller", "me", "key", "val")
+
+	lines := logs.Lines()
+
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 log entry, but found %v", lines)
+	}
+
+	// the info entries have no caller, so the user's caller is kept as is.
+	for k, v := range map[string]interface{}{"logger": loggerName, "user.logger": "user",
+		"user.ts": "now", "caller": "me", "key": "val"} {
+		if lines[0][k] != v {
+			t.Errorf("expected key '%v' to have value '%v', but found %v", k, v, lines[0])
+		}
+	}
+
+	if ts, _ := lines[0]["ts"].(string); ts == "now" || ts == "" {
+		t.Errorf("expected the logger timestamp, but found %v", lines[0])
+	}
+
+	level.Error(logger).Log("caller", "me")
+
+	if errs := logs.ErrorLines(); len(errs) != 1 || errs[0]["user.caller"] != "me" || errs[0]["caller"] == "me" {
+		t.Errorf("expected the user's caller to be prefixed on error entries, but found %v", logs.Lines())
+	}
+
+	// the caller isn't reserved at all if it's never added.
+	logger, logs = CaptureLogger(WithName(loggerName), WithConfig(&Config{IncludeCaller: new(bool)}))
+
+	level.Error(logger).Log("caller", "me")
+
+	if errs := logs.ErrorLines(); len(errs) != 1 || errs[0]["caller"] != "me" || errs[0]["user.caller"] != nil {
+		t.Errorf("expected the user's caller to be kept as is, but found %v", logs.Lines())
+	}
+
+	logger, logs = CaptureLogger(WithName(loggerName), WithUserKeyPrefix(""))
+
+	for i := 0; i < 2; i++ {
+		level.Info(logger).Log("logger", "user", "key", "val")
+	}
+
+	lines = logs.Lines()
+
+	// the dropped key is only warned about once.
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 log entries, but found %v", lines)
+	}
+
+	for _, i := range []int{0, 2} {
+		if lines[i]["logger"] != loggerName || lines[i]["key"] != "val" || lines[i]["user.logger"] != nil {
+			t.Errorf("expected the colliding key to be dropped, but found %v", lines[i])
+		}
+	}
+
+	if lines[1]["level"] != "warn" || lines[1]["key"] != "logger" {
+		t.Errorf("expected a warning about the dropped key, but found %v", lines[1])
+	}
+}
+
+func TestHostInfo(t *testing.T) {
+	defer func(f func() (string, error)) { hostname = f }(hostname)
+
+	for _, c := range []struct {
+		host     string
+		err      error
+		expected string
+	}{
+		{"node-1", nil, "node-1"},
+		{"", errors.New("no host name"), "unknown"},
+	} {
+		calls := 0
+
+		hostname = func() (string, error) {
+			calls++
+			return c.host, c.err
+		}
+
+		logger, logs := CaptureLogger(WithName(loggerName), WithHostInfo())
+
+		for i := 0; i < 10; i++ {
+			level.Info(logger).Log("msg", "info")
+			level.Error(logger).Log("msg", "error")
+		}
+
+		if calls != 1 {
+			t.Errorf("expected the host name to be resolved once, but it was resolved %v times", calls)
+		}
+
+		lines := logs.Lines()
+
+		if len(lines) != 20 {
+			t.Fatalf("expected 20 log entries, but found %v", len(lines))
+		}
+
+		for _, line := range lines {
+			if line[HostKey] != c.expected || line[PIDKey] != float64(os.Getpid()) {
+				t.Errorf("expected host '%v' and pid %v, but found %v", c.expected, os.Getpid(), line)
+				break
+			}
+		}
+	}
+}
+
+func TestSequence(t *testing.T) {
+	const goroutines, entries = 8, 100
+
+	opt := WithSequence("")
+
+	logger, logs := CaptureLogger(WithConfig(&Config{Level: "info"}), opt)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < entries; j++ {
+				// the errors are written to another appender yet they share the sequence.
+				if j%10 == 0 {
+					logger.Error("msg", "error")
+				} else {
+					logger.With("goroutine", i).Info("msg", "info")
+				}
+				logger.Debug("msg", "filtered")
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	seen := make(map[float64]bool)
+	var max float64
+
+	for _, l := range logs.Lines() {
+		seq, _ := l[SequenceKey].(float64)
+
+		if seen[seq] {
+			t.Errorf("expected unique sequence numbers, but found %v twice", seq)
+		}
+
+		seen[seq] = true
+
+		if seq > max {
+			max = seq
+		}
+	}
+
+	if len(seen) != goroutines*entries || max != goroutines*entries {
+		t.Errorf("expected %v sequence numbers up to %v, but found %v up to %v", goroutines*entries, goroutines*entries, len(seen), max)
+	}
+
+	// every logger has a sequence of its own even if it's created by the same option.
+	other, otherLogs := CaptureLogger(opt)
+	other.Info("msg", "info")
+
+	if seq := otherLogs.Lines()[0][SequenceKey]; seq != float64(1) {
+		t.Errorf("expected another logger to start its own sequence, but found %v", seq)
+	}
+}
+
+func TestLevelWriters(t *testing.T) {
+	var bufOut, bufErr, bufDebug bytes.Buffer
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Level: "trace"}), WithOutputWriter(&bufOut),
+		WithErrorWriter(&bufErr), WithLevelWriters(map[string]io.Writer{"debug": &bufDebug, "Trace": &bufDebug,
+			"none": &bufDebug, "invalid": &bufDebug, "warn": nil}))
+
+	var wg sync.WaitGroup
+
+	// the writer shared by debug and trace must be synchronized once for both.
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				logger.Debug("msg", "debug")
+				logger.Trace("msg", "trace")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	logger.Info("msg", "info")
+	logger.Warn("msg", "warn")
+	logger.Error("msg", "error")
+
+	for _, c := range []struct {
+		name     string
+		buf      *bytes.Buffer
+		expected map[string]int
+	}{
+		{"debug", &bufDebug, map[string]int{"debug": 100, "trace": 100}},
+		{"out", &bufOut, map[string]int{"info": 1, "warn": 1}},
+		{"err", &bufErr, map[string]int{"error": 1}},
+	} {
+		counts := make(map[string]int)
+
+		for _, line := range strings.Split(strings.TrimSpace(c.buf.String()), "\n") {
+			var entry map[string]interface{}
+
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("failed to parse %v entry '%v', %v", c.name, line, err.Error())
+			}
+
+			counts[fmt.Sprint(entry["level"])]++
+		}
+
+		if fmt.Sprint(counts) != fmt.Sprint(c.expected) {
+			t.Errorf("expected %v entries %v, but found %v", c.name, c.expected, counts)
+		}
+	}
+}
+
+func TestSinkOptionsAppliedOnce(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("failed to listen, %v", err.Error())
+	}
+
+	_, closeListener := acceptLines(listener)
+	defer closeListener()
+
+	create := map[string]func(opt Option) (Logger, error){
+		"file": func(opt Option) (Logger, error) {
+			return CreateFileLogger(loggerName, nil, Configuration(),
+				RotationConfig{Path: filepath.Join(t.TempDir(), "test.log")}, opt)
+		},
+		"network": func(opt Option) (Logger, error) {
+			return CreateNetworkLogger(loggerName, nil, Configuration(), "tcp", listener.Addr().String(), opt)
+		},
+		"http": func(opt Option) (Logger, error) {
+			return CreateHTTPLogger(loggerName, nil, Configuration(), "http://127.0.0.1/logs", 10, time.Second, opt)
+		},
+	}
+
+	for sink, fn := range create {
+		applied := 0
+
+		logger, err := fn(func(o *options) { applied++ })
+
+		if err != nil {
+			t.Fatalf("failed to create %v logger, %v", sink, err.Error())
+		}
+
+		logger.Close()
+
+		if applied != 1 {
+			t.Errorf("expected the %v logger option to be applied once, but it was applied %v times", sink, applied)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package otel adds OpenTelemetry trace correlation to the loggers of github.com/adzr/logging.
+
+It's a separate module so the core logging package doesn't depend on OpenTelemetry.
+
+Example
+
+  otel.Register()
+
+  // then in request-scoped code, trace_id and span_id of the span in ctx
+  // are added to every log entry.
+  level.Info(logging.WithContext(ctx, logger)).Log("msg", "handled")
+
+*/
+package otel
+
+import (
+	"context"
+
+	"github.com/adzr/logging"
+	"github.com/go-kit/kit/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TraceIDKey is the log entry key of the trace id.
+	TraceIDKey = "trace_id"
+	// SpanIDKey is the log entry key of the span id.
+	SpanIDKey = "span_id"
+)
+
+// Extractor is a logging.ContextExtractor that returns the trace and span ids
+// of the span carried by the specified context, if there's a valid one.
+func Extractor(ctx context.Context) []interface{} {
+	sc := trace.SpanContextFromContext(ctx)
+
+	if !sc.IsValid() {
+		return nil
+	}
+
+	return []interface{}{TraceIDKey, sc.TraceID().String(), SpanIDKey, sc.SpanID().String()}
+}
+
+// Register registers Extractor so the trace and span ids are added
+// to the log entries of the loggers returned by logging.WithContext.
+func Register() {
+	logging.RegisterContextExtractor(Extractor)
+}
+
+// WithTracing returns a logger that adds the trace and span ids of the span
+// carried by the specified context to every log entry, without registering
+// anything globally.
+func WithTracing(ctx context.Context, logger log.Logger) log.Logger {
+	return log.With(logger, Extractor(ctx)...)
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/adzr/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// returns the single log entry written to the specified buffer.
+func parseEntry(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	record := make(map[string]interface{})
+
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("failed to parse log entry, %v", err.Error())
+	}
+
+	return record
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "operation")
+	defer span.End()
+
+	if !span.IsRecording() {
+		t.Fatalf("expected a recording span")
+	}
+
+	Register()
+
+	for name, wrap := range map[string]func(context.Context, log.Logger) log.Logger{
+		"WithTracing": WithTracing,
+		"WithContext": logging.WithContext,
+	} {
+		var bufOut, bufErr bytes.Buffer
+
+		logger := logging.CreateSyncLogger("fake", nil, &logging.Config{Level: "debug", Format: "json"}, &bufOut, &bufErr)
+
+		level.Error(wrap(ctx, logger)).Log("key", "val")
+
+		record := parseEntry(t, &bufErr)
+
+		if id := record[TraceIDKey]; id != span.SpanContext().TraceID().String() {
+			t.Errorf("expected %v trace id '%v', but found '%v'", name, span.SpanContext().TraceID(), id)
+		}
+
+		if id := record[SpanIDKey]; id != span.SpanContext().SpanID().String() {
+			t.Errorf("expected %v span id '%v', but found '%v'", name, span.SpanContext().SpanID(), id)
+		}
+	}
+}
+
+func TestNoSpan(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := logging.CreateSyncLogger("fake", nil, &logging.Config{Level: "debug", Format: "json"}, &bufOut, &bufErr)
+
+	level.Info(WithTracing(context.Background(), logger)).Log("key", "val")
+
+	record := parseEntry(t, &bufOut)
+
+	if _, ok := record[TraceIDKey]; ok {
+		t.Errorf("expected no trace id without a span, but found '%v'", record[TraceIDKey])
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// this is a writer that indents each JSON log entry before writing it,
+// entries that aren't valid JSON are written as they are.
+type prettyWriter struct {
+	w io.Writer
+}
+
+func (w *prettyWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+
+	if err := json.Indent(&buf, bytes.TrimSpace(p), "", "  "); err != nil {
+		return w.w.Write(p)
+	}
+
+	buf.WriteByte('\n')
+
+	// the whole entry is written at once so entries never interleave.
+	if _, err := w.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
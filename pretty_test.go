@@ -0,0 +1,77 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestPretty(t *testing.T) {
+	for _, c := range []struct {
+		format string
+		pretty bool
+	}{
+		{FormatJSON, true},
+		{FormatJSON, false},
+		{FormatLogfmt, true},
+	} {
+		var bufOut, bufErr bytes.Buffer
+
+		logger := CreateSyncLogger(loggerName, nil, &Config{Format: c.format, Pretty: c.pretty}, &bufOut, &bufErr)
+
+		level.Info(logger).Log("msg", "first")
+		level.Info(logger).Log("msg", "second")
+		level.Error(logger).Log("msg", "error")
+
+		for name, logs := range map[string]string{"out": bufOut.String(), "err": bufErr.String()} {
+			indented := strings.Contains(logs, "\n  \"")
+
+			if expected := c.pretty && c.format == FormatJSON; indented != expected {
+				t.Errorf("expected %v %v entries to be indented to be %v, but found '%v'", c.format, name, expected, logs)
+			}
+
+			if c.format != FormatJSON {
+				continue
+			}
+
+			// every entry must still be valid JSON.
+			decoder := json.NewDecoder(strings.NewReader(logs))
+
+			for decoder.More() {
+				record := make(map[string]interface{})
+
+				if err := decoder.Decode(&record); err != nil {
+					t.Errorf("failed to parse %v entry, %v", name, err.Error())
+					break
+				}
+
+				if record["logger"] != loggerName {
+					t.Errorf("expected %v entry logger name, but found %v", name, record)
+				}
+			}
+		}
+
+		if c.pretty && c.format == FormatJSON && strings.Count(bufOut.String(), "\n}\n") != 2 {
+			t.Errorf("expected 2 indented out entries, but found '%v'", bufOut.String())
+		}
+	}
+}
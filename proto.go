@@ -0,0 +1,248 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// ErrInvalidProto is the error wrapped by all the errors returned for malformed 'proto' log entries.
+var ErrInvalidProto = errors.New("invalid protobuf log entry")
+
+// MaxProtoEntrySize is the maximum size in bytes of a 'proto' log entry accepted by the decoder,
+// it matches the default maximum message size of gRPC.
+const MaxProtoEntrySize = 4 << 20
+
+// these are the field numbers and wire types of the protobuf message of the 'proto' format:
+//
+//	message Entry {
+//	  int64 timestamp = 1; // unix time in nanoseconds
+//	  string level = 2;
+//	  string logger = 3;
+//	  map<string, string> fields = 4;
+//	}
+const (
+	protoTimestampField = 1
+	protoLevelField     = 2
+	protoLoggerField    = 3
+	protoFieldsField    = 4
+	protoMapKeyField    = 1
+	protoMapValueField  = 2
+
+	protoVarint = 0
+	protoBytes  = 2
+)
+
+// ProtoEntry is a log entry decoded from the 'proto' format, all the values are
+// decoded as strings, e.g. the caller, and the zero timestamp means it had none.
+type ProtoEntry struct {
+	Timestamp time.Time
+	Level     string
+	Logger    string
+	Fields    map[string]string
+}
+
+// this is a logger that serializes the log entries into protobuf
+// messages and writes each one prefixed with its varint encoded size.
+type protoLogger struct {
+	w            io.Writer
+	timestampKey string
+	nameKey      string
+}
+
+// returns a factory that creates proto loggers that resolve
+// the timestamp and logger name using the specified keys.
+func createProtoLoggerFactory(timestampKey, nameKey string) func(io.Writer) log.Logger {
+	return func(w io.Writer) log.Logger {
+		return &protoLogger{w: w, timestampKey: timestampKey, nameKey: nameKey}
+	}
+}
+
+func (l *protoLogger) Log(keyvals ...interface{}) error {
+	var msg []byte
+
+	for i := 0; i < len(keyvals); i += 2 {
+		k := keyvals[i]
+
+		var v interface{} = log.ErrMissingValue
+
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+
+		switch k {
+		case l.timestampKey:
+			if t := getEMFTimestamp(v, time.Time{}); !t.IsZero() {
+				msg = appendProtoVarint(msg, protoTimestampField, uint64(t.UnixNano()))
+				continue
+			}
+		case level.Key():
+			msg = appendProtoBytes(msg, protoLevelField, []byte(fmt.Sprint(v)))
+			continue
+		case l.nameKey:
+			msg = appendProtoBytes(msg, protoLoggerField, []byte(fmt.Sprint(v)))
+			continue
+		}
+
+		// every map entry is an embedded message of its key and value.
+		var field []byte
+		field = appendProtoBytes(field, protoMapKeyField, []byte(fmt.Sprint(k)))
+		field = appendProtoBytes(field, protoMapValueField, []byte(fmt.Sprint(v)))
+		msg = appendProtoBytes(msg, protoFieldsField, field)
+	}
+
+	// the size and the message are written at once, so concurrent entries aren't interleaved.
+	p := binary.AppendUvarint(make([]byte, 0, len(msg)+binary.MaxVarintLen64), uint64(len(msg)))
+	_, err := l.w.Write(append(p, msg...))
+	return err
+}
+
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3|protoVarint))
+	return binary.AppendUvarint(b, v)
+}
+
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3|protoBytes))
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// ProtoDecoder reads the size prefixed log entries written in the 'proto' format.
+type ProtoDecoder struct {
+	r *bufio.Reader
+}
+
+// NewProtoDecoder returns a decoder reading the 'proto' log entries from the specified reader.
+func NewProtoDecoder(r io.Reader) *ProtoDecoder {
+	return &ProtoDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads and decodes the next log entry, it returns io.EOF if there are no more
+// entries, or io.ErrUnexpectedEOF if the reader ends in the middle of an entry.
+func (d *ProtoDecoder) Decode() (*ProtoEntry, error) {
+	size, err := binary.ReadUvarint(d.r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if size > MaxProtoEntrySize {
+		return nil, fmt.Errorf("%w, size %v exceeds the maximum size", ErrInvalidProto, size)
+	}
+
+	msg := make([]byte, size)
+
+	if _, err := io.ReadFull(d.r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return DecodeProtoEntry(msg)
+}
+
+// DecodeProtoEntry decodes the specified protobuf message of a 'proto' log entry without its size prefix,
+// e.g. a message received over a gRPC stream.
+func DecodeProtoEntry(msg []byte) (*ProtoEntry, error) {
+	entry := &ProtoEntry{Fields: make(map[string]string)}
+
+	err := readProtoFields(msg, func(field int, v uint64, b []byte) error {
+		switch field {
+		case protoTimestampField:
+			entry.Timestamp = time.Unix(0, int64(v))
+		case protoLevelField:
+			entry.Level = string(b)
+		case protoLoggerField:
+			entry.Logger = string(b)
+		case protoFieldsField:
+			var key, value string
+
+			if err := readProtoFields(b, func(field int, _ uint64, b []byte) error {
+				switch field {
+				case protoMapKeyField:
+					key = string(b)
+				case protoMapValueField:
+					value = string(b)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			entry.Fields[key] = value
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// reads the fields of the specified protobuf message calling the specified function with the
+// number and the value of every field, unknown fields are passed as well to be ignored.
+func readProtoFields(msg []byte, f func(field int, v uint64, b []byte) error) error {
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+
+		if n <= 0 {
+			return fmt.Errorf("%w, malformed field tag", ErrInvalidProto)
+		}
+
+		msg = msg[n:]
+
+		v, n := binary.Uvarint(msg)
+
+		if n <= 0 {
+			return fmt.Errorf("%w, malformed field %v", ErrInvalidProto, tag>>3)
+		}
+
+		msg = msg[n:]
+
+		var b []byte
+
+		switch tag & 7 {
+		case protoVarint:
+		case protoBytes:
+			if v > uint64(len(msg)) {
+				return fmt.Errorf("%w, truncated field %v", ErrInvalidProto, tag>>3)
+			}
+
+			b, msg = msg[:v], msg[v:]
+		default:
+			return fmt.Errorf("%w, unsupported wire type %v", ErrInvalidProto, tag&7)
+		}
+
+		if err := f(int(tag>>3), v, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	now := time.Date(2018, 6, 1, 12, 30, 0, 123456789, time.UTC)
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Level: "debug", Format: FormatProto}),
+		WithOutputWriter(&bufOut), WithErrorWriter(&bufErr), WithTimestampFunc(func() time.Time { return now }))
+
+	expected := []*ProtoEntry{
+		{Timestamp: now, Level: "info", Logger: loggerName, Fields: map[string]string{"msg": "started", "port": "8080"}},
+		{Timestamp: now, Level: "debug", Logger: loggerName, Fields: map[string]string{"msg": "multi\nline", "empty": ""}},
+		{Timestamp: now, Level: "warn", Logger: loggerName, Fields: map[string]string{"err": "timeout", "elapsed": "1.5s"}},
+	}
+
+	level.Info(logger).Log("msg", "started", "port", 8080)
+	level.Debug(logger).Log("msg", "multi\nline", "empty", "")
+	level.Warn(logger).Log("err", errors.New("timeout"), "elapsed", 1500*time.Millisecond)
+
+	decoder := NewProtoDecoder(&bufOut)
+
+	for i, e := range expected {
+		entry, err := decoder.Decode()
+
+		if err != nil {
+			t.Fatalf("failed to decode entry %v, %v", i, err.Error())
+		}
+
+		if !entry.Timestamp.Equal(e.Timestamp) || entry.Level != e.Level || entry.Logger != e.Logger ||
+			!reflect.DeepEqual(entry.Fields, e.Fields) {
+			t.Errorf("expected entry %v to be %+v, but found %+v", i, e, entry)
+		}
+	}
+
+	if _, err := decoder.Decode(); err != io.EOF {
+		t.Errorf("expected '%v' after the last entry, but found %v", io.EOF, err)
+	}
+}
+
+func TestProtoDecodeInvalid(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := createProtoLoggerFactory("ts", "logger")(&buf).Log("key", "val"); err != nil {
+		t.Fatalf("failed to log entry, %v", err.Error())
+	}
+
+	data := buf.Bytes()
+
+	if _, err := NewProtoDecoder(bytes.NewReader(data[:len(data)-1])).Decode(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected '%v' for a truncated entry, but found %v", io.ErrUnexpectedEOF, err)
+	}
+
+	for _, msg := range [][]byte{{0x80}, {0x22, 0x05, 0x0a}, {0x0b}} {
+		if _, err := DecodeProtoEntry(msg); !errors.Is(err, ErrInvalidProto) {
+			t.Errorf("expected message %v to be invalid, but found %v", msg, err)
+		}
+	}
+
+	if _, err := NewProtoDecoder(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0x0f})).Decode(); !errors.Is(err, ErrInvalidProto) {
+		t.Errorf("expected an oversized entry to be invalid, but found %v", err)
+	}
+}
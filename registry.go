@@ -0,0 +1,90 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// Registry creates and caches named loggers that share the same configuration and metrics counter,
+// so that the loggers of all the subsystems are configured in one place and the counter is
+// registered only once. It's safe for concurrent use.
+type Registry struct {
+	counter metrics.Counter
+	opts    []Option
+	mtx     sync.Mutex
+	loggers map[string]Logger
+}
+
+// NewRegistry returns a new registry of loggers configured by the specified configuration and options,
+// they all count their log entries using the specified counter labeled by the logger name under the
+// 'logger' label in addition to the 'level' label, so the counter must have both labels if not nil.
+func NewRegistry(counter metrics.Counter, config *Config, opts ...Option) *Registry {
+	opts = append([]Option{WithConfig(config)}, opts...)
+
+	// the loggers share the same writers, so they must be synchronized once for all of them.
+	o := resolveOptions(opts)
+	opts = append(opts, WithOutputWriter(createSyncWriter(o.out)), WithErrorWriter(createSyncWriter(o.err)))
+
+	return &Registry{
+		counter: counter,
+		opts:    opts,
+		loggers: make(map[string]Logger),
+	}
+}
+
+// GetLogger returns the logger of the specified name, it's created on the first call
+// and the same logger is returned afterwards.
+func (r *Registry) GetLogger(name string) Logger {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if l, ok := r.loggers[name]; ok {
+		return l
+	}
+
+	opts := append(append([]Option{}, r.opts...), WithName(name))
+
+	if r.counter != nil {
+		opts = append(opts, WithCounter(r.counter.With("logger", name)))
+	}
+
+	l := NewLogger(opts...)
+	r.loggers[name] = l
+
+	return l
+}
+
+// Close closes all the loggers created by the registry and returns the first error if any,
+// the loggers must not be used afterwards.
+func (r *Registry) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var err error
+
+	for name, l := range r.loggers {
+		if e := l.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(r.loggers, name)
+	}
+
+	return err
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestRegistry(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	counter := newFakeCounter()
+
+	registry := NewRegistry(counter, &Config{Level: "debug"}, WithOutputWriter(&bufOut), WithErrorWriter(&bufErr))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			level.Info(registry.GetLogger("http")).Log("msg", "request")
+			level.Error(registry.GetLogger("db")).Log("msg", "query failed")
+			level.Debug(registry.GetLogger("db")).Log("msg", "query")
+		}()
+	}
+
+	wg.Wait()
+
+	if registry.GetLogger("http") != registry.GetLogger("http") {
+		t.Errorf("expected the same logger to be returned for the same name")
+	}
+
+	for _, c := range []struct {
+		labels   []string
+		expected float64
+	}{
+		{[]string{"logger", "http", "level", "info"}, 10},
+		{[]string{"logger", "db", "level", "error"}, 10},
+		{[]string{"logger", "db", "level", "debug"}, 10},
+		{[]string{"logger", "http", "level", "error"}, 0},
+	} {
+		if v := counter.value(c.labels...); v != c.expected {
+			t.Errorf("expected counter %v to be %v, but found %v", c.labels, c.expected, v)
+		}
+	}
+
+	if n := strings.Count(bufOut.String(), `"logger":"http"`); n != 10 {
+		t.Errorf("expected 10 http entries, but found %v", n)
+	}
+
+	if n := strings.Count(bufErr.String(), `"logger":"db"`); n != 10 {
+		t.Errorf("expected 10 db error entries, but found %v", n)
+	}
+
+	if err := registry.Close(); err != nil {
+		t.Errorf("failed to close registry, %v", err.Error())
+	}
+}
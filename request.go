@@ -0,0 +1,79 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+)
+
+// RequestIDKey is the key of the request ID added by NewRequestLogger.
+const RequestIDKey = "request_id"
+
+var (
+	// this generates the request IDs added by NewRequestLogger.
+	requestIDGenerator = newUUID
+	// and this guards it.
+	requestIDGeneratorMtx sync.RWMutex
+)
+
+// SetRequestIDGenerator sets the function generating the request IDs added by NewRequestLogger,
+// e.g. to generate predictable IDs in tests, if nil the default generator of random UUIDs is used.
+func SetRequestIDGenerator(generate func() string) {
+	if generate == nil {
+		generate = newUUID
+	}
+
+	requestIDGeneratorMtx.Lock()
+	defer requestIDGeneratorMtx.Unlock()
+
+	requestIDGenerator = generate
+}
+
+// NewRequestLogger returns a logger that adds a newly generated request ID to every log entry
+// under the 'request_id' key, so all the entries logged while serving a request are correlated
+// whichever writer they go to. The ID is generated once by the generator set by
+// SetRequestIDGenerator, by default a random UUID.
+// If the parent is an instrumented logger of this package, a child of it is returned,
+// so the caller is resolved just like logging with the parent.
+func NewRequestLogger(parent log.Logger) log.Logger {
+	requestIDGeneratorMtx.RLock()
+	id := requestIDGenerator()
+	requestIDGeneratorMtx.RUnlock()
+
+	if l, ok := parent.(Logger); ok {
+		return l.With(RequestIDKey, id)
+	}
+
+	return log.With(parent, RequestIDKey, id)
+}
+
+// returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+
+	// it never fails, it crashes the program if the system's random source fails instead.
+	rand.Read(b[:])
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestRequestLogger(t *testing.T) {
+	ids := []string{"first", "second"}
+
+	SetRequestIDGenerator(func() string {
+		id := ids[0]
+		ids = ids[1:]
+		return id
+	})
+
+	defer SetRequestIDGenerator(nil)
+
+	logger, logs := CaptureLogger(WithConfig(&Config{Level: "info"}))
+
+	request := NewRequestLogger(logger)
+
+	level.Info(request).Log("msg", "info")
+	level.Error(request).Log("msg", "error")
+	expectedCaller := callerLine(-1)
+
+	level.Info(NewRequestLogger(logger)).Log("msg", "other")
+
+	out, errs := logs.OutputLines(), logs.ErrorLines()
+
+	if len(out) != 2 || len(errs) != 1 {
+		t.Fatalf("expected 2 stdout entries and 1 stderr entry, but found %v and %v", out, errs)
+	}
+
+	if out[0][RequestIDKey] != "first" || errs[0][RequestIDKey] != "first" {
+		t.Errorf("expected both streams entries to have the same request ID, but found %v and %v", out[0], errs[0])
+	}
+
+	if errs[0]["caller"] != expectedCaller {
+		t.Errorf("expected the caller to be '%v', but found %v", expectedCaller, errs[0])
+	}
+
+	if out[1][RequestIDKey] != "second" {
+		t.Errorf("expected another request to have its own ID, but found %v", out[1])
+	}
+}
+
+func TestRequestLoggerDefaultID(t *testing.T) {
+	var buf bytes.Buffer
+
+	parent := log.NewJSONLogger(&buf)
+
+	NewRequestLogger(parent).Log("msg", "first")
+	NewRequestLogger(parent).Log("msg", "second")
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := make(map[string]bool)
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("failed to parse log entry '%v', %v", line, err.Error())
+		}
+
+		id, _ := entry[RequestIDKey].(string)
+
+		if !uuid.MatchString(id) || seen[id] {
+			t.Errorf("expected a unique random UUID, but found '%v'", id)
+		}
+
+		seen[id] = true
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+)
+
+// RingBuffer is a logger keeping the most recent log entries in memory as single-line JSON,
+// e.g. to serve them by a debug endpoint, the oldest entries are overwritten once it's full.
+// It keeps the entries as they're logged to it, so to keep the timestamps, the logger name and
+// so on, it should be given the full entries, e.g. using log.With, and combined with the other
+// loggers using Tee. It's safe for concurrent use.
+type RingBuffer struct {
+	mtx     sync.Mutex
+	entries []string
+	next    int
+	full    bool
+}
+
+// RingBufferLogger returns a logger keeping the specified number of the most recent log entries,
+// the entries are allocated once so the capacity should be kept small, if it's less than one
+// then only the last entry is kept.
+func RingBufferLogger(capacity int) *RingBuffer {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &RingBuffer{entries: make([]string, capacity)}
+}
+
+// Log formats the specified log entry and keeps it, overwriting the oldest one if it's full.
+func (r *RingBuffer) Log(keyvals ...interface{}) error {
+	var buf bytes.Buffer
+
+	// the entry is formatted before locking, so concurrent entries are formatted in parallel.
+	if err := log.NewJSONLogger(&buf).Log(keyvals...); err != nil {
+		return err
+	}
+
+	entry := strings.TrimSuffix(buf.String(), "\n")
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+
+	if r.next == 0 {
+		r.full = true
+	}
+
+	return nil
+}
+
+// Snapshot returns a copy of the kept log entries from the oldest to the most recent one.
+func (r *RingBuffer) Snapshot() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if !r.full {
+		return append([]string(nil), r.entries[:r.next]...)
+	}
+
+	return append(append(make([]string, 0, len(r.entries)), r.entries[r.next:]...), r.entries[:r.next]...)
+}
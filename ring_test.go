@@ -0,0 +1,83 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestRingBuffer(t *testing.T) {
+	ring := RingBufferLogger(3)
+
+	if s := ring.Snapshot(); len(s) != 0 {
+		t.Errorf("expected an empty snapshot, but found %v", s)
+	}
+
+	for i := 0; i < 2; i++ {
+		ring.Log("key", i)
+	}
+
+	if s, expected := ring.Snapshot(), []string{`{"key":0}`, `{"key":1}`}; !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected snapshot %v before filling the buffer, but found %v", expected, s)
+	}
+
+	for i := 2; i < 7; i++ {
+		ring.Log("key", i)
+	}
+
+	if s, expected := ring.Snapshot(), []string{`{"key":4}`, `{"key":5}`, `{"key":6}`}; !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected snapshot %v after filling the buffer, but found %v", expected, s)
+	}
+
+	if s := RingBufferLogger(0); s.Log("key", 1) != nil || s.Log("key", 2) != nil || len(s.Snapshot()) != 1 {
+		t.Errorf("expected a buffer of zero capacity to keep the last entry, but found %v", s.Snapshot())
+	}
+}
+
+func TestRingBufferTee(t *testing.T) {
+	ring := RingBufferLogger(10)
+	logger, logs := CaptureLogger(WithConfig(&Config{Level: "info"}))
+
+	tee := Tee(logger, ring)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				level.Info(tee).Log("key", fmt.Sprintf("%v_%v", i, j))
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if n := len(logs.Lines()); n != 20 {
+		t.Errorf("expected the tee'd logger to get 20 entries, but found %v", n)
+	}
+
+	if s := ring.Snapshot(); len(s) != 10 {
+		t.Errorf("expected the buffer to keep 10 entries, but found %v", s)
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+// this decides whether a log entry of the specified severity
+// level rank is kept, if not then the entry is dropped.
+type sampler func(r int, keyvals []interface{}) bool
+
+// this is a token bucket that is refilled at a fixed rate
+// up to its capacity, each allowed event takes one token.
+type tokenBucket struct {
+	mtx      sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	capacity := rate
+
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &tokenBucket{rate: rate, capacity: capacity, tokens: capacity, last: time.Now()}
+}
+
+// takes a token if there's any left and reports whether it did.
+func (b *tokenBucket) allow() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+
+	if b.tokens += now.Sub(b.last).Seconds() * b.rate; b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// returns a sampler that allows up to the specified events per
+// second for each severity level using a token bucket per level.
+func newRateSampler(eventsPerSecond float64) sampler {
+	buckets := make(map[int]*tokenBucket)
+
+	for r := range levelNames {
+		buckets[r] = newTokenBucket(eventsPerSecond)
+	}
+
+	return func(r int, _ []interface{}) bool {
+		return buckets[r].allow()
+	}
+}
+
+// returns a sampler that keeps the specified fraction of the log entries having the specified
+// key based on a hash of its value, so entries with the same value are either all kept or
+// all dropped. Errors and the entries not having the key are always kept.
+func newKeySampler(key string, rate float64) sampler {
+	return func(r int, keyvals []interface{}) bool {
+		if r == rankError {
+			return true
+		}
+
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			if fmt.Sprint(keyvals[i]) == key {
+				return float64(hashValue(keyvals[i+1])) < rate*math.MaxUint64
+			}
+		}
+
+		return true
+	}
+}
+
+// this is the maximum number of values a first n sampler counts the entries of, once it's
+// exceeded the counts are reset, so rare values can't make it grow indefinitely.
+const maxFirstNValues = 10000
+
+// returns a sampler that keeps the first n log entries having the specified key for each of its
+// values, and then keeps the specified fraction of the rest of them evenly, e.g. every other
+// entry if it's 0.5. Errors and the entries not having the key are always kept.
+func newFirstNSampler(key string, n int, rate float64) sampler {
+	var mtx sync.Mutex
+	counts := make(map[string]uint64)
+
+	keep := func(v string) bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		if _, ok := counts[v]; !ok && len(counts) >= maxFirstNValues {
+			counts = make(map[string]uint64)
+		}
+
+		counts[v]++
+		c := counts[v]
+
+		if c <= uint64(n) {
+			return true
+		}
+
+		// the entry is kept if it makes the number of the kept entries after the first n
+		// reach the next integer, so exactly the fraction of them is kept.
+		c -= uint64(n)
+		return math.Floor(float64(c)*rate) > math.Floor(float64(c-1)*rate)
+	}
+
+	return func(r int, keyvals []interface{}) bool {
+		if r == rankError {
+			return true
+		}
+
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			if keyString(keyvals[i]) == key {
+				return keep(fmt.Sprint(keyvals[i+1]))
+			}
+		}
+
+		return true
+	}
+}
+
+// returns a well distributed hash of the string representation of the specified value,
+// FNV alone barely changes the high bits of short similar values like sequential ids,
+// so its result is mixed by the MurmurHash3 finalizer.
+func hashValue(v interface{}) uint64 {
+	h := fnv.New64a()
+	fmt.Fprint(h, v)
+
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// returns the number of lines in the specified logs.
+func countLines(logs string) int {
+	n := 0
+
+	for scanner := bufio.NewScanner(strings.NewReader(logs)); scanner.Scan(); {
+		n++
+	}
+
+	return n
+}
+
+func TestSampler(t *testing.T) {
+	const rate, burst = 100, 1000
+
+	var bufOut, bufErr bytes.Buffer
+
+	counter, dropCounter := newFakeCounter(), newFakeCounter()
+
+	logger := NewLogger(
+		WithName(loggerName),
+		WithCounter(counter),
+		WithDropCounter(dropCounter),
+		WithConfig(&Config{Level: "debug", Format: FormatJSON}),
+		WithOutputWriter(&bufOut),
+		WithErrorWriter(&bufErr),
+		WithSampler(rate),
+	)
+
+	start := time.Now()
+
+	for i := 0; i < burst; i++ {
+		level.Info(logger).Log("key", "val")
+		level.Error(logger).Log("key", "val")
+	}
+
+	// the bucket starts full and is refilled at the configured rate while we're logging.
+	limit := int(rate * (1 + time.Since(start).Seconds()))
+
+	for _, c := range []struct {
+		level string
+		logs  string
+	}{
+		{"info", bufOut.String()},
+		{"error", bufErr.String()},
+	} {
+		n := countLines(c.logs)
+
+		if n == 0 || n > limit {
+			t.Errorf("expected between 1 and %v %v entries, but found %v", limit, c.level, n)
+		}
+
+		if v := counter.value("level", c.level); int(v) != n {
+			t.Errorf("expected counter value %v for level '%v', but found %v", n, c.level, v)
+		}
+
+		if v := counter.value("level", c.level) + dropCounter.value("level", c.level); v != burst {
+			t.Errorf("expected total counter value %v for level '%v', but found %v", burst, c.level, v)
+		}
+	}
+}
+
+func TestKeySampler(t *testing.T) {
+	const rate, total = 0.25, 4000
+
+	var bufOut, bufErr bytes.Buffer
+
+	logger := NewLogger(
+		WithName(loggerName),
+		WithConfig(&Config{Level: "debug", Format: FormatJSON}),
+		WithOutputWriter(&bufOut),
+		WithErrorWriter(&bufErr),
+		WithKeySampler("request_id", rate),
+	)
+
+	for i := 0; i < total; i++ {
+		level.Info(logger).Log("request_id", i)
+		level.Error(logger).Log("request_id", i)
+	}
+
+	if n := countLines(bufOut.String()); n < total*rate*0.8 || n > total*rate*1.2 {
+		t.Errorf("expected about %v info entries, but found %v", total*rate, n)
+	}
+
+	if n := countLines(bufErr.String()); n != total {
+		t.Errorf("expected all the %v error entries, but found %v", total, n)
+	}
+
+	// the same value must be sampled the same way every time.
+	bufOut.Reset()
+
+	for i := 0; i < 10; i++ {
+		level.Info(logger).Log("request_id", 7)
+	}
+
+	if n := countLines(bufOut.String()); n != 0 && n != 10 {
+		t.Errorf("expected the entries of the same value to be all kept or dropped, but found %v kept", n)
+	}
+
+	// entries without the key aren't sampled.
+	bufOut.Reset()
+
+	for i := 0; i < 10; i++ {
+		level.Info(logger).Log("key", "val")
+	}
+
+	if n := countLines(bufOut.String()); n != 10 {
+		t.Errorf("expected all the 10 entries without the key, but found %v", n)
+	}
+}
+
+func TestFirstNThenSample(t *testing.T) {
+	logger, logs := CaptureLogger(WithConfig(&Config{Level: "debug"}), WithFirstNThenSample("event", 5, 0.1))
+
+	for i := 0; i < 105; i++ {
+		level.Info(logger).Log("event", "connect", "i", i)
+		level.Info(logger).Log("event", "disconnect", "i", i)
+		level.Error(logger).Log("event", "connect", "i", i)
+		level.Info(logger).Log("key", "val")
+	}
+
+	kept := map[string][]float64{}
+	errs, unkeyed := 0, 0
+
+	for _, l := range logs.Lines() {
+		switch {
+		case l["level"] == "error":
+			errs++
+		case l["event"] == nil:
+			unkeyed++
+		default:
+			event := l["event"].(string)
+			kept[event] = append(kept[event], l["i"].(float64))
+		}
+	}
+
+	// each value is counted on its own, the first 5 are kept and then every tenth one.
+	expected := []float64{0, 1, 2, 3, 4, 14, 24, 34, 44, 54, 64, 74, 84, 94, 104}
+
+	for _, event := range []string{"connect", "disconnect"} {
+		if !reflect.DeepEqual(kept[event], expected) {
+			t.Errorf("expected the kept '%v' entries to be %v, but found %v", event, expected, kept[event])
+		}
+	}
+
+	if errs != 105 || unkeyed != 105 {
+		t.Errorf("expected all the 105 error and unkeyed entries, but found %v and %v", errs, unkeyed)
+	}
+}
+
+func TestPredicate(t *testing.T) {
+	counter, dropCounter := newFakeCounter(), newFakeCounter()
+
+	logger, logs := CaptureLogger(
+		WithName(loggerName),
+		WithCounter(counter),
+		WithDropCounter(dropCounter),
+		WithPredicate(func(keyvals []interface{}) bool {
+			for i := 0; i < len(keyvals)-1; i += 2 {
+				if keyvals[i] == "status" {
+					status, ok := keyvals[i+1].(int)
+					return ok && status >= 400
+				}
+			}
+			return true
+		}),
+	)
+
+	for _, status := range []int{200, 404, 301, 500} {
+		level.Info(logger).Log("status", status)
+	}
+
+	level.Info(logger).Log("key", "val")
+
+	lines := logs.Lines()
+
+	if len(lines) != 3 || lines[0]["status"] != float64(404) || lines[1]["status"] != float64(500) || lines[2]["key"] != "val" {
+		t.Errorf("expected the failed requests and the entry without status only, but found %v", lines)
+	}
+
+	if v := counter.value("level", "info"); v != 3 {
+		t.Errorf("expected counter value 3 for level 'info', but found %v", v)
+	}
+
+	if v := dropCounter.value("level", "info"); v != 2 {
+		t.Errorf("expected drop counter value 2 for level 'info', but found %v", v)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/go-kit/kit/log"
+)
+
+// ErrSchemaViolation is the error wrapped by all the errors passed to the WithTestMode handler.
+var ErrSchemaViolation = errors.New("log entry schema violation")
+
+// ErrInvalidSchema is the error wrapped by all the errors returned by ParseSchema.
+var ErrInvalidSchema = errors.New("invalid log entry schema")
+
+// Schema is the contract of the log entries validated by WithTestMode.
+type Schema struct {
+	// Required are the keys every log entry must have.
+	Required []string
+	// Types are the JSON types of the values of the specified keys when they're present, the types
+	// are the JSON Schema ones, i.e. 'string', 'number', 'integer', 'boolean', 'object', 'array' and
+	// 'null', the values are typed as they're serialized by the JSON format.
+	Types map[string]string
+}
+
+// these are the JSON Schema types supported by Schema.
+var schemaTypes = map[string]bool{
+	"string": true, "number": true, "integer": true, "boolean": true, "object": true, "array": true, "null": true,
+}
+
+// ParseSchema returns the schema of the log entries described by the specified JSON Schema document,
+// only the top level 'required' keys and the 'type' of the top level 'properties' are supported and
+// the rest of the document is ignored, e.g. {"required": ["msg"], "properties": {"msg": {"type": "string"}}}.
+func ParseSchema(data []byte) (*Schema, error) {
+	var doc struct {
+		Required   []string `json:"required"`
+		Properties map[string]struct {
+			Type string `json:"type"`
+		} `json:"properties"`
+	}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrInvalidSchema, err.Error())
+	}
+
+	schema := &Schema{Required: doc.Required, Types: make(map[string]string, len(doc.Properties))}
+
+	for k, p := range doc.Properties {
+		if p.Type == "" {
+			continue
+		}
+
+		if !schemaTypes[p.Type] {
+			return nil, fmt.Errorf("%w, unsupported type '%v' of key '%v'", ErrInvalidSchema, p.Type, k)
+		}
+
+		schema.Types[k] = p.Type
+	}
+
+	return schema, nil
+}
+
+// this is a logger that validates the log entries against a schema before passing them to the
+// next logger, calling the handler with each violation while the entries are still written.
+// It's used below the appenders contexts, so the keys added by the logger are validated too.
+type schemaLogger struct {
+	next    log.Logger
+	schema  *Schema
+	handler func(error)
+}
+
+func (l *schemaLogger) Log(keyvals ...interface{}) error {
+	// the last value of a duplicate key wins just like it does in JSON.
+	values := make(map[string]interface{}, len(keyvals)/2)
+
+	for i := 0; i < len(keyvals)-1; i += 2 {
+		values[keyString(keyvals[i])] = keyvals[i+1]
+	}
+
+	for _, k := range l.schema.Required {
+		if _, ok := values[k]; !ok {
+			l.handler(fmt.Errorf("%w, missing required key '%v' in %v", ErrSchemaViolation, k, keyvals))
+		}
+	}
+
+	for k, expected := range l.schema.Types {
+		if v, ok := values[k]; ok && !hasSchemaType(v, expected) {
+			l.handler(fmt.Errorf("%w, key '%v' is %v instead of %v in %v", ErrSchemaViolation, k, getSchemaType(v), expected, keyvals))
+		}
+	}
+
+	return l.next.Log(keyvals...)
+}
+
+// returns the handler of the schema violations, falling back to the error handler.
+func getSchemaHandler(o *options) func(error) {
+	if o.schemaHandler != nil {
+		return o.schemaHandler
+	}
+	return o.errorHandler
+}
+
+// checks if the specified value has the specified JSON Schema type, where integers are numbers too.
+func hasSchemaType(v interface{}, expected string) bool {
+	t := getSchemaType(v)
+	return t == expected || (t == "integer" && expected == "number")
+}
+
+// returns the JSON Schema type of the specified value as it's serialized by the JSON format, the
+// numbers having no fraction nor exponent are integers, and the values that can't be serialized
+// have an empty type.
+func getSchemaType(v interface{}) string {
+	// these are handled by the JSON format before marshaling just like go-kit does,
+	// which writes nil pointer errors as null and nil pointer stringers as "NULL".
+	switch x := v.(type) {
+	case json.Marshaler:
+	case encoding.TextMarshaler:
+	case error:
+		if isNilPointer(v) {
+			return "null"
+		}
+		v = x.Error()
+	case fmt.Stringer:
+		if isNilPointer(v) {
+			return "string"
+		}
+		v = x.String()
+	}
+
+	data, err := json.Marshal(v)
+
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+
+	switch data[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	}
+
+	if bytes.ContainsAny(data, ".eE") {
+		return "number"
+	}
+	return "integer"
+}
+
+// checks if the specified value is a nil pointer.
+func isNilPointer(v interface{}) bool {
+	r := reflect.ValueOf(v)
+	return r.Kind() == reflect.Ptr && r.IsNil()
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// returns a violation handler collecting the errors it's called with.
+func collectViolations() (func(error), func() []error) {
+	var mtx sync.Mutex
+	var violations []error
+
+	return func(err error) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			violations = append(violations, err)
+		}, func() []error {
+			mtx.Lock()
+			defer mtx.Unlock()
+			return append([]error(nil), violations...)
+		}
+}
+
+func TestTestMode(t *testing.T) {
+	schema := &Schema{
+		Required: []string{"ts", "level", "logger", "msg", "request_id"},
+		Types:    map[string]string{"level": "string", "msg": "string", "status": "integer", "latency": "number"},
+	}
+
+	handler, violations := collectViolations()
+
+	logger, logs := CaptureLogger(WithName(loggerName), WithTestMode(schema, handler))
+
+	level.Info(logger).Log("msg", "served", "request_id", "abc", "status", 200, "latency", 1.5)
+
+	if v := violations(); len(v) != 0 {
+		t.Errorf("expected no violations of a valid entry, but found %v", v)
+	}
+
+	level.Info(logger).Log("msg", "served", "status", 200)
+
+	v := violations()
+
+	if len(v) != 1 || !errors.Is(v[0], ErrSchemaViolation) || !strings.Contains(v[0].Error(), "'request_id'") {
+		t.Fatalf("expected a violation of the missing required key, but found %v", v)
+	}
+
+	level.Error(logger).Log("msg", "failed", "request_id", "abc", "status", "500", "latency", time.Second)
+
+	if v := violations()[1:]; len(v) != 2 || !errors.Is(v[0], ErrSchemaViolation) || !errors.Is(v[1], ErrSchemaViolation) {
+		t.Errorf("expected violations of the status and latency types, but found %v", v)
+	}
+
+	// the entries are still written anyway.
+	if lines := logs.Lines(); len(lines) != 3 {
+		t.Errorf("expected 3 log entries to be written, but found %v", lines)
+	}
+
+	// the error handler is used if there's no violations handler.
+	handler, violations = collectViolations()
+
+	logger, _ = CaptureLogger(WithName(loggerName), WithErrorHandler(handler), WithTestMode(schema, nil))
+
+	logger.Log("msg", "no level")
+
+	if v := violations(); len(v) != 2 {
+		t.Errorf("expected violations of the missing level and request_id keys, but found %v", v)
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object",
+		"required": ["msg"], "properties": {"msg": {"type": "string"}, "status": {"type": "integer"}, "data": {}}}`))
+
+	if err != nil {
+		t.Fatalf("failed to parse schema, %v", err.Error())
+	}
+
+	if len(schema.Required) != 1 || schema.Required[0] != "msg" || len(schema.Types) != 2 ||
+		schema.Types["msg"] != "string" || schema.Types["status"] != "integer" {
+		t.Errorf("expected the required keys and the types to be parsed, but found %+v", schema)
+	}
+
+	for _, data := range []string{`{"required": "msg"}`, `{"properties": {"msg": {"type": "text"}}}`, `[`} {
+		if _, err := ParseSchema([]byte(data)); !errors.Is(err, ErrInvalidSchema) {
+			t.Errorf("expected schema '%v' to be invalid, but found %v", data, err)
+		}
+	}
+}
+
+func TestSchemaTypes(t *testing.T) {
+	var nilErr *stackError
+
+	for _, c := range []struct {
+		value    interface{}
+		expected string
+	}{
+		{"text", "string"},
+		{level.InfoValue(), "string"},
+		{errors.New("failed"), "string"},
+		{nilErr, "null"},
+		{time.Now(), "string"},
+		{42, "integer"},
+		{1.5, "number"},
+		{true, "boolean"},
+		{nil, "null"},
+		{map[string]interface{}{"a": 1}, "object"},
+		{[]int{1}, "array"},
+		{func() {}, ""},
+	} {
+		if actual := getSchemaType(c.value); actual != c.expected {
+			t.Errorf("expected the type of %v to be '%v', but found '%v'", c.value, c.expected, actual)
+		}
+	}
+}
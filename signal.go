@@ -0,0 +1,86 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// FlushOnSignal flushes and closes the specified logger once any of the specified signals
+// is received, e.g. syscall.SIGTERM, so the buffered log entries aren't lost when the process
+// is stopped. The handler is then uninstalled and the signal is sent again to the process,
+// so it's handled as it would have been without the handler, e.g. the process is terminated,
+// unless other handlers are installed by signal.Notify which then receive it twice.
+// It returns a function that uninstalls the handler if it's called before any signal
+// is received, and if no signals are specified it does nothing.
+func FlushOnSignal(logger io.Closer, sigs ...os.Signal) func() {
+	if len(sigs) == 0 {
+		return func() {}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	return handleSignals(logger, ch, func(sig os.Signal) {
+		signal.Stop(ch)
+
+		if sig != nil {
+			raise(sig)
+		}
+	})
+}
+
+// sends the specified signal to the current process.
+var raise = func(sig os.Signal) error {
+	p, err := os.FindProcess(os.Getpid())
+
+	if err != nil {
+		return err
+	}
+
+	return p.Signal(sig)
+}
+
+// flushes and closes the specified logger once a signal is received from the specified channel
+// on a background goroutine, then calls the specified stop function with the received signal,
+// or nil if it stopped waiting. It returns a function that stops waiting for the signal,
+// it's safe to be called more than once.
+func handleSignals(logger io.Closer, ch <-chan os.Signal, stop func(os.Signal)) func() {
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case sig := <-ch:
+			if f, ok := logger.(flusher); ok {
+				f.Flush()
+			}
+			logger.Close()
+			stop(sig)
+		case <-done:
+			stop(nil)
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// this is a logger closer that records whether it was flushed and closed.
+type fakeFlushCloser struct {
+	mtx             sync.Mutex
+	flushed, closed bool
+}
+
+func (c *fakeFlushCloser) Flush() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.flushed = true
+	return nil
+}
+
+func (c *fakeFlushCloser) Close() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.closed = true
+	return nil
+}
+
+func (c *fakeFlushCloser) state() (bool, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.flushed, c.closed
+}
+
+func TestFlushOnSignal(t *testing.T) {
+	logger, ch, stopped := &fakeFlushCloser{}, make(chan os.Signal, 1), make(chan os.Signal, 1)
+
+	cancel := handleSignals(logger, ch, func(sig os.Signal) { stopped <- sig })
+	defer cancel()
+
+	ch <- os.Interrupt
+
+	select {
+	case sig := <-stopped:
+		if sig != os.Interrupt {
+			t.Errorf("expected the handler to be stopped with the received signal, but found %v", sig)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the signal to be handled, but it wasn't")
+	}
+
+	if flushed, closed := logger.state(); !flushed || !closed {
+		t.Errorf("expected the logger to be flushed and closed, but found flushed %v and closed %v", flushed, closed)
+	}
+}
+
+func TestFlushOnSignalCancel(t *testing.T) {
+	logger, ch, stopped := &fakeFlushCloser{}, make(chan os.Signal, 1), make(chan os.Signal, 1)
+
+	cancel := handleSignals(logger, ch, func(sig os.Signal) { stopped <- sig })
+	cancel()
+	cancel()
+
+	select {
+	case sig := <-stopped:
+		if sig != nil {
+			t.Errorf("expected the handler to be stopped with no signal, but found %v", sig)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the handler to be stopped, but it wasn't")
+	}
+
+	if flushed, closed := logger.state(); flushed || closed {
+		t.Errorf("expected the logger to be left intact, but found flushed %v and closed %v", flushed, closed)
+	}
+
+	// the real handler is uninstalled as well.
+	FlushOnSignal(logger, os.Interrupt)()
+	FlushOnSignal(logger)()
+}
+
+// this is a logger closer that reports being closed to stdout, it's used by the signaled test process.
+type stdoutCloser struct{}
+
+func (stdoutCloser) Close() error {
+	_, err := os.Stdout.WriteString("closed\n")
+	return err
+}
+
+func TestFlushOnSignalRaise(t *testing.T) {
+	// the test process signals itself and is expected to be terminated by the signal.
+	if os.Getenv("LOGGING_SIGNAL_PROCESS") == "1" {
+		FlushOnSignal(stdoutCloser{}, syscall.SIGTERM)
+		raise(syscall.SIGTERM)
+		time.Sleep(5 * time.Second)
+		os.Exit(0)
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("signals can't be sent to the process on windows")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFlushOnSignalRaise$")
+	cmd.Env = append(os.Environ(), "LOGGING_SIGNAL_PROCESS=1")
+
+	out, err := cmd.Output()
+
+	if string(out) != "closed\n" {
+		t.Errorf("expected the logger to be closed before terminating, but found '%v'", string(out))
+	}
+
+	var exitErr *exec.ExitError
+
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected the process to be terminated, but found %v", err)
+	}
+
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); !ok || !status.Signaled() || status.Signal() != syscall.SIGTERM {
+		t.Errorf("expected the process to be terminated by %v, but found %v", syscall.SIGTERM, exitErr)
+	}
+}
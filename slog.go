@@ -0,0 +1,169 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+)
+
+// SlogCallerDepth is the caller depth of the loggers wrapped by slog handlers,
+// since slog adds a couple of stack frames between the caller and the handler.
+const SlogCallerDepth = DefaultCallerDepth + 2
+
+// this is a slog handler that writes the records to a logger.
+type slogHandler struct {
+	logger log.Logger
+	attrs  []interface{}
+	group  string
+}
+
+// SlogHandler returns a slog handler that writes the records to the specified logger
+// with the matching severity level, slog levels below debug are mapped to trace.
+// The attributes are added as key-value pairs where the keys of the grouped
+// attributes are prefixed by their groups joined by dots.
+// The logger should be configured with SlogCallerDepth to resolve the right caller.
+func SlogHandler(logger log.Logger) slog.Handler {
+	return &slogHandler{logger: logger}
+}
+
+// NewSlogHandler returns a slog handler that writes the records to a new instance of
+// stdout & stderr instrumented logger, if the caller depth isn't configured or
+// it's the default one then SlogCallerDepth is used.
+func NewSlogHandler(loggerName string, counter metrics.Counter, config *Config) slog.Handler {
+	if config == nil {
+		config = Configuration()
+	}
+
+	c := *config
+
+	if c.CallerDepth <= 0 || c.CallerDepth == DefaultCallerDepth {
+		c.CallerDepth = SlogCallerDepth
+	}
+
+	return SlogHandler(CreateStdSyncLogger(loggerName, counter, &c))
+}
+
+// returns the severity level rank matching the specified slog level.
+func getSlogLevelRank(l slog.Level) int {
+	switch {
+	case l >= slog.LevelError:
+		return rankError
+	case l >= slog.LevelWarn:
+		return rankWarn
+	case l >= slog.LevelInfo:
+		return rankInfo
+	case l >= slog.LevelDebug:
+		return rankDebug
+	default:
+		return rankTrace
+	}
+}
+
+// this is implemented by the loggers of this package that resolve the allowed levels
+// themselves, e.g. when the entries written to the error writer have their own level.
+type levelEnabler interface {
+	enabled(r int) bool
+}
+
+// Enabled checks if the records of the specified level are allowed by the logger's level, or
+// its error level for the ones written to the error writer, they're always allowed if the
+// logger isn't one of this package's.
+func (h *slogHandler) Enabled(_ context.Context, l slog.Level) bool {
+	if logger, ok := h.logger.(levelEnabler); ok {
+		return logger.enabled(getSlogLevelRank(l))
+	}
+
+	if logger, ok := h.logger.(Logger); ok {
+		threshold := getValidLevel(logger.Level())
+		return threshold != rankNone && getSlogLevelRank(l) >= threshold
+	}
+
+	return true
+}
+
+// Handle writes the specified record to the logger, the record time is dropped
+// since the logger adds its own timestamp.
+func (h *slogHandler) Handle(_ context.Context, record slog.Record) error {
+	keyvals := make([]interface{}, 0, 4+len(h.attrs)+2*record.NumAttrs())
+	keyvals = append(keyvals, level.Key(), getLevelValue(getSlogLevelRank(record.Level)), "msg", record.Message)
+	keyvals = append(keyvals, h.attrs...)
+
+	record.Attrs(func(a slog.Attr) bool {
+		keyvals = appendSlogAttr(keyvals, h.group, a)
+		return true
+	})
+
+	return h.logger.Log(keyvals...)
+}
+
+// WithAttrs returns a handler that adds the specified attributes to every record.
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := &slogHandler{logger: h.logger, group: h.group, attrs: append([]interface{}{}, h.attrs...)}
+
+	for _, a := range attrs {
+		c.attrs = appendSlogAttr(c.attrs, h.group, a)
+	}
+
+	return c
+}
+
+// WithGroup returns a handler that groups the attributes of every record under the specified group.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &slogHandler{logger: h.logger, attrs: h.attrs, group: joinSlogGroup(h.group, name)}
+}
+
+// appends the specified attribute to the specified keyvals prefixing its key by the specified group,
+// group attributes are flattened and empty attributes are ignored just like slog handlers do.
+func appendSlogAttr(keyvals []interface{}, group string, a slog.Attr) []interface{} {
+	a.Value = a.Value.Resolve()
+
+	if a.Equal(slog.Attr{}) {
+		return keyvals
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		// inline groups have no key.
+		if a.Key != "" {
+			group = joinSlogGroup(group, a.Key)
+		}
+
+		for _, ga := range a.Value.Group() {
+			keyvals = appendSlogAttr(keyvals, group, ga)
+		}
+
+		return keyvals
+	}
+
+	return append(keyvals, joinSlogGroup(group, a.Key), a.Value.Any())
+}
+
+// returns the specified key prefixed by the specified group if any.
+func joinSlogGroup(group, key string) string {
+	if group == "" {
+		return key
+	}
+	return group + "." + key
+}
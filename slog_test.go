@@ -0,0 +1,129 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	counter := newFakeCounter()
+
+	logger, logs := CaptureLogger(WithName(loggerName), WithCounter(counter),
+		WithConfig(&Config{Level: "debug", CallerDepth: SlogCallerDepth}))
+
+	sl := slog.New(SlogHandler(logger))
+
+	sl.Debug("debug message", "count", 1)
+	sl.Info("info message", slog.Group("request", "method", "GET", "path", "/"))
+	sl.With("component", "db").WithGroup("query").Warn("warn message", "rows", 3)
+	sl.Error("error message", "err", errors.New("refused"))
+	sl.Log(context.Background(), slog.LevelDebug-4, "trace message")
+
+	for _, c := range []struct {
+		lines    []map[string]interface{}
+		expected []map[string]interface{}
+	}{
+		{logs.OutputLines(), []map[string]interface{}{
+			{"level": "debug", "msg": "debug message", "count": 1.0},
+			{"level": "info", "msg": "info message", "request.method": "GET", "request.path": "/"},
+			{"level": "warn", "msg": "warn message", "component": "db", "query.rows": 3.0},
+		}},
+		{logs.ErrorLines(), []map[string]interface{}{
+			{"level": "error", "msg": "error message", "err": "refused"},
+		}},
+	} {
+		if len(c.lines) != len(c.expected) {
+			t.Errorf("expected %v entries, but found %v", len(c.expected), c.lines)
+			continue
+		}
+
+		for i, expected := range c.expected {
+			for k, v := range expected {
+				if c.lines[i][k] != v {
+					t.Errorf("expected key-value (%v, %v), but found %v", k, v, c.lines[i])
+				}
+			}
+		}
+	}
+
+	// the trace record is filtered by the logger level.
+	if logs.Contains("msg", "trace message") {
+		t.Errorf("expected trace records to be filtered, but found %v", logs.Lines())
+	}
+
+	for _, l := range []string{"debug", "info", "warn", "error"} {
+		if v := counter.value("level", l); v != 1 {
+			t.Errorf("expected %v counter to be 1, but found %v", l, v)
+		}
+	}
+
+	if lines := logs.ErrorLines(); len(lines) == 1 {
+		if caller, _ := lines[0]["caller"].(string); !strings.HasPrefix(caller, "slog_test.go:") {
+			t.Errorf("expected the caller to be this file, but found '%v'", caller)
+		}
+	}
+}
+
+func TestSlogHandlerEnabled(t *testing.T) {
+	logger, _ := CaptureLogger(WithConfig(&Config{Level: "warn"}))
+	handler := SlogHandler(logger)
+
+	for l, expected := range map[slog.Level]bool{
+		slog.LevelDebug: false,
+		slog.LevelInfo:  false,
+		slog.LevelWarn:  true,
+		slog.LevelError: true,
+	} {
+		if enabled := handler.Enabled(context.Background(), l); enabled != expected {
+			t.Errorf("expected level %v enabled to be %v, but found %v", l, expected, enabled)
+		}
+	}
+
+	logger.SetLevel("none")
+
+	if handler.Enabled(context.Background(), slog.LevelError) {
+		t.Errorf("expected no level to be enabled when the level is none")
+	}
+}
+
+func TestSlogHandlerErrorLevel(t *testing.T) {
+	logger, logs := CaptureLogger(WithConfig(&Config{Level: "error", ErrorLevel: "debug", StderrLevels: []string{"debug", "error"}}))
+	handler := SlogHandler(logger)
+
+	// the debug records are written to the error writer, so they're allowed by the error level.
+	for l, expected := range map[slog.Level]bool{
+		slog.LevelDebug: true,
+		slog.LevelInfo:  false,
+		slog.LevelWarn:  false,
+		slog.LevelError: true,
+	} {
+		if enabled := handler.Enabled(context.Background(), l); enabled != expected {
+			t.Errorf("expected level %v enabled to be %v, but found %v", l, expected, enabled)
+		}
+	}
+
+	slog.New(handler).Debug("connected", "host", "db")
+
+	if lines := logs.ErrorLines(); len(lines) != 1 || lines[0]["msg"] != "connected" || lines[0]["level"] != "debug" {
+		t.Errorf("expected the debug record to be written to the error writer, but found %v", logs.Lines())
+	}
+}
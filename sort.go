@@ -0,0 +1,72 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"sort"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// this is a logger that sorts the keys of the log entries before passing them to the next
+// logger, so the output is the same whatever the order the keys were logged in. The timestamp
+// and the level keys come first and the rest are sorted alphabetically, keeping the order of
+// the duplicate keys. It's used below the appenders contexts, so all the keys are sorted.
+type sortedKeysLogger struct {
+	next         log.Logger
+	timestampKey string
+}
+
+func (l *sortedKeysLogger) Log(keyvals ...interface{}) error {
+	pairs := make([][2]interface{}, 0, len(keyvals)/2)
+
+	for i := 0; i < len(keyvals)-1; i += 2 {
+		pairs = append(pairs, [2]interface{}{keyvals[i], keyvals[i+1]})
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		ri, rj := l.rank(pairs[i][0]), l.rank(pairs[j][0])
+
+		if ri != rj {
+			return ri < rj
+		}
+
+		return ri == 2 && keyString(pairs[i][0]) < keyString(pairs[j][0])
+	})
+
+	entry := make([]interface{}, 0, len(keyvals))
+
+	for _, p := range pairs {
+		entry = append(entry, p[0], p[1])
+	}
+
+	return l.next.Log(entry...)
+}
+
+// returns the position of the specified key, the timestamp key comes first,
+// then the level key and finally the rest of the keys.
+func (l *sortedKeysLogger) rank(k interface{}) int {
+	switch {
+	case k == level.Key():
+		return 1
+	case keyString(k) == l.timestampKey:
+		return 0
+	default:
+		return 2
+	}
+}
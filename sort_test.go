@@ -0,0 +1,55 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestSortedKeys(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Level: "info", Format: FormatLogfmt}),
+		WithOutputWriter(&bufOut), WithErrorWriter(&bufErr), WithSortedKeys(), WithFields("service", "api"),
+		WithTimestampFunc(func() time.Time { return time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC) }))
+
+	level.Info(logger).Log("zeta", 1, "alpha", 2, "msg", "first", "beta", 3)
+	logger.With("gamma", 4).Info("beta", 3, "msg", "second", "alpha", 2, "zeta", 1)
+	level.Error(logger).Log("b", 1, "a", 2, "a", 3)
+	expectedCaller := callerLine(-1)
+
+	expected := []string{
+		"ts=2018-06-01T00:00:00Z level=info alpha=2 beta=3 logger=" + loggerName + " msg=first service=api zeta=1",
+		"ts=2018-06-01T00:00:00Z level=info alpha=2 beta=3 gamma=4 logger=" + loggerName + " msg=second service=api zeta=1",
+	}
+
+	if lines := strings.Split(strings.TrimSpace(bufOut.String()), "\n"); strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected sorted entries:\n%v\nbut found:\n%v", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+
+	// the duplicate keys keep their order.
+	expectedErr := "ts=2018-06-01T00:00:00Z level=error a=2 a=3 b=1 caller=" + expectedCaller + " logger=" + loggerName + " service=api"
+
+	if line := strings.TrimSpace(bufErr.String()); line != expectedErr {
+		t.Errorf("expected sorted error entry '%v', but found '%v'", expectedErr, line)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"log/syslog"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// this is a writer that writes to syslog with a fixed severity.
+type syslogSeverityWriter func(string) error
+
+func (w syslogSeverityWriter) Write(p []byte) (int, error) {
+	if err := w(string(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// CreateSyslogLogger returns an instance of instrumented logger that writes to syslog
+// using the specified tag, entries are formatted using the configured format and sent
+// with the severity matching their level, where 'error' is LOG_ERR, 'warn' is LOG_WARNING,
+// 'info' and entries with no level are LOG_INFO, 'debug' and 'trace' are LOG_DEBUG.
+// If network and addr are empty it connects to the local syslog server, otherwise
+// it connects to addr on the specified network, e.g. "tcp" or "udp".
+// The logger is further configured by the specified options.
+// The logger should be closed when it's no longer needed to close the connection.
+// If configuration level is set to 'none' then neither
+// logs nor monitoring will take place.
+func CreateSyslogLogger(loggerName string, counter metrics.Counter, config *Config, network, addr, tag string,
+	opts ...Option) (Logger, error) {
+
+	w, err := syslog.Dial(network, addr, syslog.LOG_USER|syslog.LOG_INFO, tag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append([]Option{WithName(loggerName), WithCounter(counter), WithConfig(config)}, opts...)
+
+	return NewLogger(append(opts,
+		withLevelWriter(rankTrace, syslogSeverityWriter(w.Debug)),
+		withLevelWriter(rankDebug, syslogSeverityWriter(w.Debug)),
+		withLevelWriter(rankInfo, syslogSeverityWriter(w.Info)),
+		withLevelWriter(rankWarn, syslogSeverityWriter(w.Warning)),
+		withLevelWriter(rankError, syslogSeverityWriter(w.Err)),
+		withCloser(w))...), nil
+}
@@ -0,0 +1,160 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// this matches a syslog frame capturing its priority, tag and message.
+var syslogFramePattern = regexp.MustCompile(`^<(\d+)>\S+ \S+ (\S+)\[\d+\]: (.*)$`)
+
+// listens on a local address of the specified network and sends the received syslog frames to the returned channel.
+func listenSyslog(t *testing.T, network string) (string, <-chan string, func()) {
+	frames := make(chan string, 16)
+
+	if network == "udp" {
+		conn, err := net.ListenPacket(network, "127.0.0.1:0")
+
+		if err != nil {
+			t.Fatalf("failed to listen on %v, %v", network, err.Error())
+		}
+
+		go func() {
+			buf := make([]byte, 64*1024)
+			for {
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				frames <- strings.TrimSuffix(string(buf[:n]), "\n")
+			}
+		}()
+
+		return conn.LocalAddr().String(), frames, func() { conn.Close() }
+	}
+
+	listener, err := net.Listen(network, "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("failed to listen on %v, %v", network, err.Error())
+	}
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			frames <- scanner.Text()
+		}
+	}()
+
+	return listener.Addr().String(), frames, func() { listener.Close() }
+}
+
+func TestSyslogLogger(t *testing.T) {
+	for _, network := range []string{"udp", "tcp"} {
+		addr, frames, closeListener := listenSyslog(t, network)
+
+		counter := newFakeCounter()
+
+		logger, err := CreateSyslogLogger(loggerName, counter, &Config{Level: "trace", Format: FormatJSON}, network, addr, "test",
+			WithFields("service", "test"))
+
+		if err != nil {
+			closeListener()
+			t.Fatalf("failed to create syslog logger over %v, %v", network, err.Error())
+		}
+
+		entries := []struct {
+			log      func() error
+			level    string
+			priority int
+		}{
+			{func() error { return level.Error(logger).Log("msg", "error") }, "error", 8 | 3},
+			{func() error { return level.Warn(logger).Log("msg", "warn") }, "warn", 8 | 4},
+			{func() error { return level.Info(logger).Log("msg", "info") }, "info", 8 | 6},
+			{func() error { return level.Debug(logger).Log("msg", "debug") }, "debug", 8 | 7},
+			{func() error { return Trace(logger).Log("msg", "trace") }, "trace", 8 | 7},
+		}
+
+		for _, e := range entries {
+			if err := e.log(); err != nil {
+				t.Errorf("failed to log %v entry over %v, %v", e.level, network, err.Error())
+				continue
+			}
+
+			var frame string
+
+			select {
+			case frame = <-frames:
+			case <-time.After(5 * time.Second):
+				t.Errorf("expected a syslog frame for %v entry over %v, but found none", e.level, network)
+				continue
+			}
+
+			matches := syslogFramePattern.FindStringSubmatch(frame)
+
+			if matches == nil {
+				t.Errorf("expected a valid syslog frame over %v, but found '%v'", network, frame)
+				continue
+			}
+
+			if priority, _ := strconv.Atoi(matches[1]); priority != e.priority {
+				t.Errorf("expected %v entry priority to be %v over %v, but found %v", e.level, e.priority, network, priority)
+			}
+
+			if matches[2] != "test" {
+				t.Errorf("expected syslog tag to be 'test', but found '%v'", matches[2])
+			}
+
+			record := make(map[string]interface{})
+
+			if err := json.Unmarshal([]byte(matches[3]), &record); err != nil {
+				t.Errorf("failed to parse syslog message '%v', %v", matches[3], err.Error())
+			} else if record["msg"] != e.level || record["logger"] != loggerName || record["service"] != "test" {
+				t.Errorf("expected %v entry message, but found %v", e.level, record)
+			}
+
+			if c := counter.value("level", e.level); c != 1 {
+				t.Errorf("expected %v counter to be 1, but found %v", e.level, c)
+			}
+		}
+
+		if err := logger.Close(); err != nil {
+			t.Errorf("failed to close syslog logger over %v, %v", network, err.Error())
+		}
+
+		closeListener()
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"errors"
+
+	"github.com/go-kit/kit/log"
+)
+
+// this is a logger that forwards every log entry to all of its loggers.
+type teeLogger []log.Logger
+
+func (t teeLogger) Log(keyvals ...interface{}) error {
+	var errs []error
+
+	// every logger gets the entry even if a previous one failed.
+	for _, l := range t {
+		if err := l.Log(keyvals...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Tee returns a logger that forwards every log entry to all the specified loggers,
+// each of them still filters and routes the entries on its own, and the errors
+// they return are joined together. It's safe for concurrent use as long as
+// the specified loggers are.
+// Since it adds a stack frame, the caller depth of the wrapped loggers of
+// this package should be increased by one to resolve the right caller.
+func Tee(loggers ...log.Logger) log.Logger {
+	t := make(teeLogger, 0, len(loggers))
+
+	for _, l := range loggers {
+		if l != nil {
+			t = append(t, l)
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestTee(t *testing.T) {
+	first, firstLogs := CaptureLogger(WithName("first"), WithConfig(&Config{Level: "debug"}))
+	second, secondLogs := CaptureLogger(WithName("second"), WithConfig(&Config{Level: "warn"}))
+
+	logger := Tee(first, nil, second)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			level.Debug(logger).Log("msg", "debug", "i", i)
+			level.Error(logger).Log("msg", "error", "i", i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	if lines := firstLogs.OutputLines(); len(lines) != 10 {
+		t.Errorf("expected 10 debug entries in first logger, but found %v", lines)
+	}
+
+	if lines := secondLogs.OutputLines(); len(lines) != 0 {
+		t.Errorf("expected debug entries to be filtered by second logger, but found %v", lines)
+	}
+
+	strip := func(lines []map[string]interface{}) map[float64]bool {
+		values := make(map[float64]bool)
+		for _, line := range lines {
+			values[line["i"].(float64)] = true
+		}
+		return values
+	}
+
+	firstErrors, secondErrors := firstLogs.ErrorLines(), secondLogs.ErrorLines()
+
+	if len(firstErrors) != 10 || !reflect.DeepEqual(strip(firstErrors), strip(secondErrors)) {
+		t.Errorf("expected both loggers to receive the same error entries, but found %v and %v", firstErrors, secondErrors)
+	}
+
+	for _, line := range secondErrors {
+		if line["logger"] != "second" {
+			t.Errorf("expected entries to be written by the second logger, but found %v", line)
+		}
+	}
+}
+
+func TestTeeErrors(t *testing.T) {
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+
+	capture, logs := CaptureLogger()
+
+	logger := Tee(log.LoggerFunc(func(...interface{}) error { return errFirst }), capture,
+		log.LoggerFunc(func(...interface{}) error { return errSecond }))
+
+	err := logger.Log("msg", "failing")
+
+	if !errors.Is(err, errFirst) || !errors.Is(err, errSecond) {
+		t.Errorf("expected both errors to be returned, but found %v", err)
+	}
+
+	if !logs.Contains("msg", "failing") {
+		t.Errorf("expected the entry to be logged despite the errors, but found %v", logs.Lines())
+	}
+
+	if err := Tee(capture).Log("msg", "succeeding"); err != nil {
+		t.Errorf("failed to log to tee logger, %v", err.Error())
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrWriteTimeout is returned when writing a log entry takes longer than the write timeout,
+// the entry is then dropped and counted by the drop counter.
+var ErrWriteTimeout = errors.New("logging write timed out, log entry dropped")
+
+// this is a writer that gives up on writing to the underlying writer once the timeout
+// elapses, so a slow writer never blocks logging for longer than the timeout.
+// The entries are written one at a time on a background goroutine, so a timed out write
+// keeps on in the background and the following entries wait for it within their own timeout.
+type timeoutWriter struct {
+	w       io.Writer
+	timeout time.Duration
+	sem     chan struct{}
+}
+
+func newTimeoutWriter(w io.Writer, timeout time.Duration) *timeoutWriter {
+	return &timeoutWriter{w: w, timeout: timeout, sem: make(chan struct{}, 1)}
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	timer := time.NewTimer(w.timeout)
+	defer timer.Stop()
+
+	select {
+	case w.sem <- struct{}{}:
+	case <-timer.C:
+		return 0, ErrWriteTimeout
+	}
+
+	// the entry is copied since the loggers may reuse it once we return.
+	data := append([]byte(nil), p...)
+	done := make(chan error, 1)
+
+	go func() {
+		defer func() { <-w.sem }()
+		_, err := w.w.Write(data)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	case <-timer.C:
+		return 0, ErrWriteTimeout
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// this is a writer that takes the specified delay to write each entry.
+type slowWriter struct {
+	mtx   sync.Mutex
+	delay time.Duration
+	buf   bytes.Buffer
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	w.mtx.Lock()
+	delay := w.delay
+	w.mtx.Unlock()
+
+	time.Sleep(delay)
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return w.buf.Write(p)
+}
+
+func (w *slowWriter) setDelay(delay time.Duration) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.delay = delay
+}
+
+func (w *slowWriter) String() string {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return w.buf.String()
+}
+
+func TestWriteTimeout(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+
+	out, errs := &slowWriter{delay: 4 * timeout}, 0
+	counter, dropCounter := newFakeCounter(), newFakeCounter()
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Level: "debug"}), WithCounter(counter),
+		WithDropCounter(dropCounter), WithOutputWriter(out), WithErrorWriter(&bytes.Buffer{}),
+		WithWriteTimeout(timeout), WithErrorHandler(func(err error) { errs++ }))
+
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+
+		if err := level.Info(logger).Log("key_10", "val_10"); !errors.Is(err, ErrWriteTimeout) {
+			t.Errorf("expected error '%v', but found '%v'", ErrWriteTimeout, err)
+		}
+
+		if d := time.Since(start); d > 2*timeout {
+			t.Errorf("expected logging to return within %v, but it took %v", timeout, d)
+		}
+	}
+
+	if v := dropCounter.value("level", "info"); v != 2 {
+		t.Errorf("expected drop counter value 2 for level 'info', but found %v", v)
+	}
+
+	if errs != 2 {
+		t.Errorf("expected the error handler to be called 2 times, but it was called %v times", errs)
+	}
+
+	// once the slow writes are done, the fast ones succeed.
+	time.Sleep(8 * timeout)
+	out.setDelay(0)
+
+	if err := level.Info(logger).Log("key_11", "val_11"); err != nil {
+		t.Errorf("failed to log within the timeout, %v", err.Error())
+	}
+
+	// the first timed out entry is still written in the background.
+	if err := validateLogs(out.String(), [][]int{{1, 0}, {1, 1}}); err != nil {
+		t.Errorf("failed to validate out writer, %v", err.Error())
+	}
+}
+
+func TestAsyncWriteTimeout(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+
+	var mtx sync.Mutex
+	out, errs := &slowWriter{delay: 4 * timeout}, 0
+	dropCounter := newFakeCounter()
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Level: "debug", Async: true}),
+		WithDropCounter(dropCounter), WithOutputWriter(out), WithErrorWriter(out), WithWriteTimeout(timeout),
+		WithErrorHandler(func(err error) {
+			if errors.Is(err, ErrWriteTimeout) {
+				mtx.Lock()
+				defer mtx.Unlock()
+				errs++
+			}
+		}))
+
+	defer logger.Close()
+
+	// the caller returns right away, the timeouts happen in the background.
+	for i := 0; i < 2; i++ {
+		if err := level.Info(logger).Log("key_10", "val_10"); err != nil {
+			t.Errorf("failed to queue log entry, %v", err.Error())
+		}
+	}
+
+	if err := level.Error(logger).Log("key_10", "val_10"); err != nil {
+		t.Errorf("failed to queue log entry, %v", err.Error())
+	}
+
+	if err := logger.Flush(); err != nil {
+		t.Errorf("failed to flush logger, %v", err.Error())
+	}
+
+	if v := dropCounter.value("level", "info"); v != 2 {
+		t.Errorf("expected drop counter value 2 for level 'info', but found %v", v)
+	}
+
+	if v := dropCounter.value("level", "error"); v != 1 {
+		t.Errorf("expected drop counter value 1 for level 'error', but found %v", v)
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	if errs != 3 {
+		t.Errorf("expected the error handler to be called 3 times, but it was called %v times", errs)
+	}
+}
@@ -0,0 +1,377 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// Redacted is the value that replaces the values of redacted keys.
+const Redacted = "[REDACTED]"
+
+// FieldsTruncatedKey is the key of the marker added to the log entries truncated by WithMaxFields.
+const FieldsTruncatedKey = "fields_truncated"
+
+const (
+	// DurationString is the duration format of Go duration strings, e.g. "1.5s", it's the default.
+	DurationString = "string"
+	// DurationMillis is the duration format of the number of milliseconds with a fraction.
+	DurationMillis = "millis"
+	// DurationSeconds is the duration format of the number of seconds with a fraction.
+	DurationSeconds = "seconds"
+	// DurationNanos is the duration format of the integer number of nanoseconds.
+	DurationNanos = "nanos"
+)
+
+// this transforms the keyvals of a log entry before it's written,
+// it may modify the keyvals in place since it's always given a copy.
+type transform func(keyvals []interface{}) []interface{}
+
+// Middleware transforms the keyvals of a log entry before it's routed, so unlike the other
+// transforms it may change the level of the entry, e.g. to escalate it, or remove it, in which
+// case the entry is handled as having no level. It may modify the keyvals in place since it's
+// always given a copy, and it never gets the logger name nor the fields of the child loggers.
+type Middleware func(keyvals []interface{}) []interface{}
+
+// returns the string form of the specified log entry key.
+func keyString(k interface{}) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	return fmt.Sprint(k)
+}
+
+// checks if the specified log entry has the specified key.
+func hasKey(keyvals []interface{}, key string) bool {
+	for i := 0; i < len(keyvals)-1; i += 2 {
+		if keyString(keyvals[i]) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// returns a transform that redacts the values of the keys matched by the specified function.
+func newRedactionTransform(match func(key string) bool) transform {
+	return func(keyvals []interface{}) []interface{} {
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			if k := keyvals[i]; k != level.Key() && match(keyString(k)) {
+				keyvals[i+1] = Redacted
+			}
+		}
+		return keyvals
+	}
+}
+
+// returns a transform that removes all the keys but the level key and the specified ones.
+func newAllowListTransform(keys []string) transform {
+	allowed := make(map[string]bool, len(keys))
+
+	for _, k := range keys {
+		allowed[k] = true
+	}
+
+	return func(keyvals []interface{}) []interface{} {
+		entry := keyvals[:0]
+
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			if k := keyvals[i]; k == level.Key() || allowed[keyString(k)] {
+				entry = append(entry, k, keyvals[i+1])
+			}
+		}
+		return entry
+	}
+}
+
+// returns a transform that formats the time.Duration values using the specified format,
+// it returns nil for Go duration strings since that's how they're marshaled anyway.
+func newDurationTransform(format string) transform {
+	var convert func(d time.Duration) interface{}
+
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case DurationMillis:
+		convert = func(d time.Duration) interface{} { return float64(d) / float64(time.Millisecond) }
+	case DurationSeconds:
+		convert = func(d time.Duration) interface{} { return d.Seconds() }
+	case DurationNanos:
+		convert = func(d time.Duration) interface{} { return d.Nanoseconds() }
+	default:
+		return nil
+	}
+
+	return func(keyvals []interface{}) []interface{} {
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			if d, ok := keyvals[i+1].(time.Duration); ok && keyvals[i] != level.Key() {
+				keyvals[i+1] = convert(d)
+			}
+		}
+		return keyvals
+	}
+}
+
+// returns a transform that replaces the non-primitive values by the ones returned by the specified
+// marshaler, or by their fmt.Sprint form if it fails, the level value is never replaced.
+func newMarshalerTransform(marshal func(interface{}) (interface{}, error)) transform {
+	return func(keyvals []interface{}) []interface{} {
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			v := keyvals[i+1]
+
+			if keyvals[i] == level.Key() || isPrimitive(v) {
+				continue
+			}
+
+			if m, err := marshal(v); err == nil {
+				keyvals[i+1] = m
+			} else {
+				keyvals[i+1] = fmt.Sprint(v)
+			}
+		}
+		return keyvals
+	}
+}
+
+// checks if the specified value is nil, a string, a bool or a number.
+func isPrimitive(v interface{}) bool {
+	switch v.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr,
+		float32, float64, complex64, complex128:
+		return true
+	default:
+		return false
+	}
+}
+
+// returns a transform that replaces the map values by their entries under the map key joined
+// with the entry key by the specified separator, recursively, e.g. "user.id". The flattened
+// keys never replace the other keys of the entry, and if they collide, the first one is kept.
+func newFlattenTransform(separator string) transform {
+	return func(keyvals []interface{}) []interface{} {
+		if !hasMapValue(keyvals) {
+			return keyvals
+		}
+
+		// the keys that aren't flattened are kept as they are, so they're reserved first.
+		seen := make(map[string]bool, len(keyvals)/2)
+
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			if !isMap(keyvals[i+1]) {
+				seen[keyString(keyvals[i])] = true
+			}
+		}
+
+		entry := make([]interface{}, 0, len(keyvals))
+
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			if isMap(keyvals[i+1]) {
+				entry = appendFlattened(entry, keyString(keyvals[i]), keyvals[i+1], separator, seen)
+			} else {
+				entry = append(entry, keyvals[i], keyvals[i+1])
+			}
+		}
+		return entry
+	}
+}
+
+// checks if any of the values of the specified log entry is a map.
+func hasMapValue(keyvals []interface{}) bool {
+	for i := 1; i < len(keyvals); i += 2 {
+		if isMap(keyvals[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMap(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, map[string]string:
+		return true
+	default:
+		return false
+	}
+}
+
+// appends the specified value under the specified key, or its entries flattened in the order of
+// their keys if it's a map, the keys that are already seen are skipped.
+func appendFlattened(entry []interface{}, key string, v interface{}, separator string, seen map[string]bool) []interface{} {
+	var m map[string]interface{}
+
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m = x
+	case map[string]string:
+		m = make(map[string]interface{}, len(x))
+
+		for k, e := range x {
+			m[k] = e
+		}
+	default:
+		if seen[key] {
+			return entry
+		}
+
+		seen[key] = true
+		return append(entry, key, v)
+	}
+
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		entry = appendFlattened(entry, key+separator+k, m[k], separator, seen)
+	}
+
+	return entry
+}
+
+// returns a transform that keeps the level key and the first n of the other keys, and if
+// there are more, they're dropped and the truncation marker is added instead.
+func newMaxFieldsTransform(n int) transform {
+	return func(keyvals []interface{}) []interface{} {
+		if len(keyvals)/2 <= n {
+			return keyvals
+		}
+
+		entry, fields, truncated := keyvals[:0], 0, false
+
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			switch {
+			case keyvals[i] == level.Key():
+			case fields < n:
+				fields++
+			default:
+				truncated = true
+				continue
+			}
+
+			entry = append(entry, keyvals[i], keyvals[i+1])
+		}
+
+		if truncated {
+			entry = append(entry, FieldsTruncatedKey, true)
+		}
+		return entry
+	}
+}
+
+// returns a transform that parses the string values of the specified keys into numbers,
+// the values that aren't valid finite numbers are left as they are.
+func newNumericTransform(keys []string) transform {
+	numeric := make(map[string]bool, len(keys))
+
+	for _, k := range keys {
+		numeric[k] = true
+	}
+
+	return func(keyvals []interface{}) []interface{} {
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			if s, ok := keyvals[i+1].(string); ok && keyvals[i] != level.Key() && numeric[keyString(keyvals[i])] {
+				keyvals[i+1] = parseNumber(s)
+			}
+		}
+		return keyvals
+	}
+}
+
+// returns the specified string parsed as an integer or a finite float, if it's neither then it's returned as it is.
+func parseNumber(s string) interface{} {
+	t := strings.TrimSpace(s)
+
+	if n, err := strconv.ParseInt(t, 10, 64); err == nil {
+		return n
+	}
+
+	if f, err := strconv.ParseFloat(t, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f
+	}
+
+	return s
+}
+
+// returns a transform that adds the numeric severity of the entry level under the specified key,
+// if it's the level key then the level value itself is replaced by its numeric severity.
+func newNumericLevelTransform(key string) transform {
+	return func(keyvals []interface{}) []interface{} {
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			if keyvals[i] == level.Key() {
+				if keyString(keyvals[i]) == key {
+					keyvals[i+1] = LevelSeverity(keyvals[i+1])
+					return keyvals
+				}
+				return append(keyvals, key, LevelSeverity(keyvals[i+1]))
+			}
+		}
+		return append(keyvals, key, LevelSeverity(nil))
+	}
+}
+
+// returns a transform that truncates the string and fmt.Stringer values longer than
+// the specified number of bytes, the level value is never truncated.
+func newTruncationTransform(n int) transform {
+	return func(keyvals []interface{}) []interface{} {
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			if keyvals[i] == level.Key() {
+				continue
+			}
+
+			var s string
+
+			switch v := keyvals[i+1].(type) {
+			case string:
+				s = v
+			case fmt.Stringer:
+				// the nil pointers are left to the formatter which writes them safely.
+				if isNilPointer(v) {
+					continue
+				}
+				s = v.String()
+			default:
+				continue
+			}
+
+			if len(s) > n {
+				keyvals[i+1] = truncate(s, n)
+			}
+		}
+		return keyvals
+	}
+}
+
+// truncates the specified string to at most n bytes without splitting
+// a multi-byte character, appending the original length to it.
+func truncate(s string, n int) string {
+	end := n
+
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+
+	return fmt.Sprintf("%v...(truncated %v bytes)", s[:end], len(s))
+}
@@ -0,0 +1,361 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// logs a single entry using a logger created with the specified options
+// and returns it parsed, along with the writer it was written to.
+func logEntry(t *testing.T, log func(Logger), opts ...Option) (map[string]interface{}, string) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := NewLogger(append([]Option{
+		WithName(loggerName),
+		WithConfig(&Config{Level: "trace", Format: FormatJSON}),
+		WithOutputWriter(&bufOut),
+		WithErrorWriter(&bufErr),
+	}, opts...)...)
+
+	log(logger)
+
+	buf, writer := &bufOut, "out"
+
+	if bufErr.Len() > 0 {
+		buf, writer = &bufErr, "err"
+	}
+
+	record := make(map[string]interface{})
+
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("failed to parse log entry '%v', %v", buf.String(), err.Error())
+	}
+
+	return record, writer
+}
+
+func TestRedaction(t *testing.T) {
+	keyvals := []interface{}{level.Key(), level.InfoValue(), "Password", "secret", "authorization", "token",
+		"user", "bob", "api_key", "key"}
+
+	record, _ := logEntry(t, func(logger Logger) {
+		logger.Log(keyvals...)
+	}, WithRedaction("password", "AUTHORIZATION", "level"), WithRedactionFunc(func(key string) bool {
+		return strings.HasSuffix(key, "_key")
+	}))
+
+	for k, expected := range map[string]interface{}{
+		"Password":      Redacted,
+		"authorization": Redacted,
+		"api_key":       Redacted,
+		"user":          "bob",
+		"level":         "info",
+	} {
+		if v := record[k]; v != expected {
+			t.Errorf("expected key-value (%v, %v), but found (%v, %v)", k, expected, k, v)
+		}
+	}
+
+	if keyvals[3] != "secret" {
+		t.Errorf("expected the caller's keyvals to be left intact, but found %v", keyvals)
+	}
+}
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
+
+func TestMaxValueLength(t *testing.T) {
+	long := strings.Repeat("a", 4096)
+
+	record, _ := logEntry(t, func(logger Logger) {
+		level.Info(logger).Log("long", long, "stringer", stringer(long), "short", "abc", "number", 123456, "utf8", "aé",
+			"nil", (*url.URL)(nil))
+	}, WithMaxValueLength(2))
+
+	for k, expected := range map[string]interface{}{
+		"long":     "aa...(truncated 4096 bytes)",
+		"stringer": "aa...(truncated 4096 bytes)",
+		"short":    "ab...(truncated 3 bytes)",
+		"number":   float64(123456),
+		"utf8":     "a...(truncated 3 bytes)",
+		"nil":      "NULL",
+		"level":    "info",
+	} {
+		if v := record[k]; v != expected {
+			t.Errorf("expected key-value (%v, %v), but found (%v, %v)", k, expected, k, v)
+		}
+	}
+}
+
+func TestAllowedKeys(t *testing.T) {
+	keyvals := []interface{}{level.Key(), level.ErrorValue(), "msg", "failed", "user", "bob",
+		"password", "secret", "request_id", "42"}
+
+	record, _ := logEntry(t, func(logger Logger) {
+		logger.Log(keyvals...)
+	}, WithAllowedKeys("msg", "request_id"))
+
+	for _, k := range []string{"msg", "request_id", "level", "ts", "logger", "caller"} {
+		if _, found := record[k]; !found {
+			t.Errorf("expected key '%v' to be kept, but found %v", k, record)
+		}
+	}
+
+	if len(record) != 6 {
+		t.Errorf("expected only the allowed keys to be kept, but found %v", record)
+	}
+
+	if keyvals[5] != "bob" {
+		t.Errorf("expected the caller's keyvals to be left intact, but found %v", keyvals)
+	}
+}
+
+func TestDurationFormat(t *testing.T) {
+	for _, c := range []struct {
+		format   string
+		expected interface{}
+	}{
+		{"", "1.5023s"},
+		{"string", "1.5023s"},
+		{"invalid", "1.5023s"},
+		{"millis", 1502.3},
+		{" Seconds ", 1.5023},
+		{"nanos", float64(1502300000)},
+	} {
+		record, _ := logEntry(t, func(logger Logger) {
+			level.Info(logger).Log("elapsed", 1502300*time.Microsecond, "count", 3)
+		}, WithDurationFormat(c.format))
+
+		if v := record["elapsed"]; v != c.expected {
+			t.Errorf("expected duration formatted as '%v' to be %v, but found %v", c.format, c.expected, v)
+		}
+
+		if v := record["count"]; v != float64(3) {
+			t.Errorf("expected non-duration values to be left intact, but found %v", v)
+		}
+	}
+}
+
+func TestNumericKeys(t *testing.T) {
+	var bufOut, bufErr bytes.Buffer
+
+	logger := NewLogger(WithName(loggerName), WithOutputWriter(&bufOut), WithErrorWriter(&bufErr),
+		WithNumericKeys("status", "bytes", "ratio", "code", "inf"))
+
+	level.Info(logger).Log("status", "200", "bytes", " 1024 ", "ratio", "0.5", "code", "E42", "inf", "Inf", "other", "7")
+
+	for _, kv := range []string{`"status":200`, `"bytes":1024`, `"ratio":0.5`, `"code":"E42"`, `"inf":"Inf"`, `"other":"7"`} {
+		if !strings.Contains(bufOut.String(), kv) {
+			t.Errorf("expected entry to contain '%v', but found %v", kv, bufOut.String())
+		}
+	}
+}
+
+func TestMarshaler(t *testing.T) {
+	type address struct {
+		City string
+		Zip  int
+	}
+
+	type user struct {
+		Name    string
+		Address address
+		secret  string
+	}
+
+	// flattens the users, and fails for anything else.
+	marshal := func(v interface{}) (interface{}, error) {
+		u, ok := v.(user)
+
+		if !ok {
+			return nil, errors.New("unsupported value")
+		}
+
+		return map[string]interface{}{"name": u.Name, "address.city": u.Address.City, "address.zip": u.Address.Zip}, nil
+	}
+
+	var bufOut, bufErr bytes.Buffer
+
+	logger := NewLogger(WithName(loggerName), WithConfig(&Config{Level: "info", Format: FormatJSON}),
+		WithOutputWriter(&bufOut), WithErrorWriter(&bufErr), WithMarshaler(marshal), WithTimestampFunc(func() time.Time {
+			return time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+		}))
+
+	level.Info(logger).Log("user", user{Name: "ahmed", Address: address{City: "cairo", Zip: 11511}, secret: "x"},
+		"ids", []int{1, 2}, "count", 3)
+
+	expected := `{"count":3,"ids":"[1 2]","level":"info","logger":"` + loggerName + `","ts":"2018-06-01T00:00:00Z",` +
+		`"user":{"address.city":"cairo","address.zip":11511,"name":"ahmed"}}` + "\n"
+
+	if s := bufOut.String(); s != expected {
+		t.Errorf("expected JSON output %v, but found %v", expected, s)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	for _, c := range []struct {
+		separator string
+		expected  map[string]interface{}
+	}{
+		{"", map[string]interface{}{"a.b.c": "deep", "a.b.d": float64(1), "a.e": "shallow", "a.f.g": "string", "x": "kept"}},
+		{"_", map[string]interface{}{"a_b_c": "deep", "a_b_d": float64(1), "a_e": "shallow", "a_f_g": "string", "x": "kept"}},
+	} {
+		record, _ := logEntry(t, func(logger Logger) {
+			level.Info(logger).Log("a", map[string]interface{}{
+				"b": map[string]interface{}{"c": "deep", "d": 1},
+				"e": "shallow",
+				"f": map[string]string{"g": "string"},
+			}, "x", "kept")
+		}, WithFlatten(c.separator))
+
+		for k, v := range c.expected {
+			if record[k] != v {
+				t.Errorf("expected flattened key '%v' to be %v, but found %v", k, v, record)
+			}
+		}
+
+		for k, v := range record {
+			if _, ok := v.(map[string]interface{}); ok {
+				t.Errorf("expected no nested objects, but found key '%v' with %v", k, v)
+			}
+		}
+
+		if _, found := record["a"]; found {
+			t.Errorf("expected the map key to be removed, but found %v", record)
+		}
+	}
+
+	// the flattened keys never replace the other keys.
+	record, _ := logEntry(t, func(logger Logger) {
+		level.Info(logger).Log("a", map[string]interface{}{"b": "flattened"}, "a.b", "explicit")
+	}, WithFlatten(""))
+
+	if record["a.b"] != "explicit" {
+		t.Errorf("expected the explicit key to be kept, but found %v", record)
+	}
+}
+
+func TestChildLoggerTransforms(t *testing.T) {
+	record, _ := logEntry(t, func(logger Logger) {
+		logger.With("password", "hunter2", "secret_field", "x").With("user", "bob").Info("msg", "hi")
+	}, WithRedaction("password"), WithAllowedKeys("msg", "password", "user"))
+
+	if record["password"] != Redacted {
+		t.Errorf("expected the child logger field to be redacted, but found %v", record)
+	}
+
+	if _, found := record["secret_field"]; found || record["user"] != "bob" || record["msg"] != "hi" || record["logger"] != loggerName {
+		t.Errorf("expected only the allowed child logger fields to be kept, but found %v", record)
+	}
+}
+
+func TestMaxFields(t *testing.T) {
+	keyvals := []interface{}{"msg", "many"}
+
+	for i := 0; i < 100; i++ {
+		keyvals = append(keyvals, fmt.Sprintf("key_%v", i), i)
+	}
+
+	record, writer := logEntry(t, func(logger Logger) {
+		logger.With("service", "api").Error(keyvals...)
+	}, WithMaxFields(3))
+
+	// the logger keys are kept on top of the first 3 keys, which include the child logger fields.
+	expected := map[string]interface{}{"service": "api", "msg": "many", "key_0": float64(0), FieldsTruncatedKey: true,
+		"level": "error", "logger": loggerName}
+
+	for k, v := range expected {
+		if record[k] != v {
+			t.Errorf("expected key '%v' to be %v, but found %v", k, v, record)
+		}
+	}
+
+	// the timestamp and the caller are kept as well.
+	if _, found := record["ts"]; !found || record["caller"] == nil || len(record) != len(expected)+2 || writer != "err" {
+		t.Errorf("expected the entry to be capped at 3 fields, but found %v written to %v", record, writer)
+	}
+
+	// the entries that aren't exceeding the maximum are kept intact.
+	record, _ = logEntry(t, func(logger Logger) {
+		level.Info(logger).Log("a", 1, "b", 2, "c", 3)
+	}, WithMaxFields(3))
+
+	if _, found := record[FieldsTruncatedKey]; found || record["c"] != float64(3) {
+		t.Errorf("expected the entry to be kept intact, but found %v", record)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	keyvals := []interface{}{level.Key(), level.InfoValue(), "msg", "hello", "steps", ""}
+
+	// appends the specified step to the steps value of the entry.
+	step := func(name string) Middleware {
+		return func(keyvals []interface{}) []interface{} {
+			for i := 0; i < len(keyvals)-1; i += 2 {
+				if keyvals[i] == "steps" {
+					keyvals[i+1] = fmt.Sprint(keyvals[i+1], name)
+				}
+			}
+			return keyvals
+		}
+	}
+
+	// escalates the entries having an error key to the error level.
+	escalate := func(keyvals []interface{}) []interface{} {
+		if !hasKey(keyvals, "error") {
+			return keyvals
+		}
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			if keyvals[i] == level.Key() {
+				keyvals[i+1] = level.ErrorValue()
+			}
+		}
+		return keyvals
+	}
+
+	record, writer := logEntry(t, func(logger Logger) {
+		logger.Log(keyvals...)
+	}, WithMiddleware(step("a"), nil, step("b")), WithMiddleware(step("c")))
+
+	if record["steps"] != "abc" || record["msg"] != "hello" || record["level"] != "info" || writer != "out" {
+		t.Errorf("expected the middlewares to apply in order, but found %v written to %v", record, writer)
+	}
+
+	if keyvals[5] != "" {
+		t.Errorf("expected the caller's keyvals not to be modified, but found %v", keyvals)
+	}
+
+	// the middlewares run before the entry is routed by its level.
+	record, writer = logEntry(t, func(logger Logger) {
+		logger.With("service", "api").Info("msg", "failed", "error", "timeout")
+	}, WithMiddleware(escalate, step("a")))
+
+	if record["level"] != "error" || record["service"] != "api" || writer != "err" {
+		t.Errorf("expected the entry to be escalated to error, but found %v written to %v", record, writer)
+	}
+}
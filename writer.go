@@ -0,0 +1,81 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// this is a writer logging each line written to it as a log entry.
+type lineWriter struct {
+	logger log.Logger
+	mtx    sync.Mutex
+	buf    []byte
+}
+
+// WriterAt returns a writer that logs each line written to it as the message of a log entry
+// of the specified severity level, which falls back to info if it's not valid, e.g. to
+// capture the output of the standard log package using log.SetOutput. A line split across
+// writes is logged once it's completed, the trailing line breaks are trimmed and empty
+// lines are ignored. It's safe for concurrent use.
+func WriterAt(lvl string, logger log.Logger) io.Writer {
+	r, ok := lookupLevel(lvl)
+
+	if !ok || r == rankAll || r == rankNone {
+		r = rankInfo
+	}
+
+	return &lineWriter{logger: log.WithPrefix(logger, level.Key(), getLevelValue(r))}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+
+		if i < 0 {
+			break
+		}
+
+		line := bytes.TrimRight(w.buf[:i], "\r")
+		w.buf = w.buf[i+1:]
+
+		if len(line) == 0 {
+			continue
+		}
+
+		if err := w.logger.Log("msg", string(line)); err != nil {
+			return len(p), err
+		}
+	}
+
+	// don't hold on to a consumed buffer.
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2018 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"fmt"
+	stdlog "log"
+	"testing"
+)
+
+func TestWriterAt(t *testing.T) {
+	logger, logs := CaptureLogger(WithName(loggerName), WithConfig(&Config{Level: "debug"}))
+
+	w := WriterAt("warn", logger)
+
+	for _, p := range []string{"first line\n", "second ", "line", "\r\n\n", "third line\nfourth", " line\n", "partial"} {
+		if n, err := w.Write([]byte(p)); err != nil || n != len(p) {
+			t.Errorf("failed to write '%v', (%v, %v)", p, n, err)
+		}
+	}
+
+	lines := logs.Lines()
+	expected := []string{"first line", "second line", "third line", "fourth line"}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %v entries, but found %v", len(expected), lines)
+	}
+
+	for i, msg := range expected {
+		if lines[i]["msg"] != msg || lines[i]["level"] != "warn" || lines[i]["logger"] != loggerName {
+			t.Errorf("expected warn entry '%v', but found %v", msg, lines[i])
+		}
+	}
+
+	// the partial line is logged once it's completed.
+	w.Write([]byte(" line\n"))
+
+	if !logs.Contains("msg", "partial line") {
+		t.Errorf("expected the partial line to be logged once completed, but found %v", logs.Lines())
+	}
+}
+
+func TestWriterAtStdLog(t *testing.T) {
+	logger, logs := CaptureLogger(WithConfig(&Config{Level: "debug"}))
+
+	for _, c := range []struct {
+		level, expected string
+	}{
+		{"error", "error"},
+		{"DEBUG", "debug"},
+		{"invalid", "info"},
+		{"none", "info"},
+	} {
+		logs.Reset()
+
+		std := stdlog.New(WriterAt(c.level, logger), "", 0)
+		std.Printf("legacy %v", c.level)
+
+		lines := logs.Lines()
+
+		if len(lines) != 1 || lines[0]["msg"] != fmt.Sprintf("legacy %v", c.level) || lines[0]["level"] != c.expected {
+			t.Errorf("expected a single %v entry, but found %v", c.expected, lines)
+		}
+	}
+}